@@ -0,0 +1,179 @@
+package broker
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/validation"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	heartbeat = 10 * time.Second
+	locale    = "en_US"
+)
+
+var ErrInvalidTLSConfig = errors.New("invalid TLS configuration")
+
+// Config is the connection to RabbitMQ shared by the client and the server.
+type Config struct {
+	// URL is the address of the broker "host:port".
+	URL      string
+	User     string
+	Password string
+	TLS      TLSConfig
+	// ExternalAuth authenticates the connection by the client certificate (EXTERNAL SASL mechanism),
+	// User and Password are not sent. It requires TLS with CertFile and KeyFile.
+	ExternalAuth bool
+}
+
+type TLSConfig struct {
+	// Enabled switches the connection to amqps.
+	Enabled bool
+	// CAFile is the PEM bundle of certificate authorities the broker certificate is verified by,
+	// system roots are used when it is empty.
+	CAFile string
+	// CertFile and KeyFile are the PEM client certificate and its key for mutual TLS.
+	CertFile string
+	KeyFile  string
+	// ServerName is verified in the broker certificate, the host of URL by default.
+	ServerName string
+	// MinVersion is the minimal TLS version "1.2" (default) or "1.3".
+	MinVersion string
+}
+
+// Validate checks the configuration, certificates are loaded when the connection is opened.
+func (c Config) Validate() error {
+	var errs validation.Errors
+	if c.URL == "" {
+		errs.Addf("url", "is required")
+	}
+	if c.ExternalAuth {
+		if !c.TLS.Enabled || c.TLS.CertFile == "" {
+			errs.Addf("externalauth", "requires TLS with client certificate")
+		}
+	} else if c.User == "" {
+		errs.Addf("user", "is required unless externalauth is set")
+	}
+	if c.TLS.Enabled {
+		errs.Add("tls", c.TLS.Validate())
+	}
+	return errs.Err()
+}
+
+// Validate checks the TLS version and that the client certificate has the key.
+func (c TLSConfig) Validate() error {
+	var errs validation.Errors
+	switch c.MinVersion {
+	case "", "1.2", "1.3":
+	default:
+		errs.Addf("minversion", "unsupported version %q", c.MinVersion)
+	}
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		errs.Addf("certfile", "client certificate requires both certfile and keyfile")
+	}
+	return errs.Err()
+}
+
+// Dial opens the connection to the broker.
+func Dial(config Config) (*amqp.Connection, error) {
+	amqpConfig := amqp.Config{
+		Heartbeat: heartbeat,
+		Locale:    locale,
+	}
+
+	if config.TLS.Enabled {
+		tlsConfig, err := NewTLSConfig(config.TLS)
+		if err != nil {
+			return nil, err
+		}
+		amqpConfig.TLSClientConfig = tlsConfig
+	}
+
+	if config.ExternalAuth {
+		if !config.TLS.Enabled || config.TLS.CertFile == "" {
+			return nil, fmt.Errorf("%w: external auth requires TLS with client certificate", ErrInvalidTLSConfig)
+		}
+		amqpConfig.SASL = []amqp.Authentication{&amqp.ExternalAuth{}}
+	}
+
+	return amqp.DialConfig(URL(config), amqpConfig)
+}
+
+// QueueArgs returns arguments of the command queue. The queue is declared by the client and the server, and RabbitMQ
+// rejects declaring it with other arguments, so maxPriority must be the same on both sides.
+func QueueArgs(maxPriority uint8) amqp.Table {
+	if maxPriority == 0 {
+		return nil
+	}
+	return amqp.Table{"x-max-priority": int32(maxPriority)}
+}
+
+// URL returns the AMQP URI of the broker, credentials are escaped, so they can have any characters.
+// The vhost after the host is kept as it is in the AMQP URI, e.g. "%2F" is the vhost "/".
+func URL(config Config) string {
+	host, vhost, hasVhost := strings.Cut(config.URL, "/")
+	u := url.URL{
+		Scheme: "amqp",
+		Host:   host,
+	}
+	if hasVhost {
+		u.Path = "/" + vhost
+		if path, err := url.PathUnescape(vhost); err == nil {
+			u.Path, u.RawPath = "/"+path, "/"+vhost
+		}
+	}
+	if config.TLS.Enabled {
+		u.Scheme = "amqps"
+	}
+	if !config.ExternalAuth {
+		u.User = url.UserPassword(config.User, config.Password)
+	}
+	return u.String()
+}
+
+// NewTLSConfig loads certificates of the configuration.
+func NewTLSConfig(config TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName: config.ServerName,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	switch config.MinVersion {
+	case "", "1.2":
+	case "1.3":
+		tlsConfig.MinVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("%w: unsupported min version %q", ErrInvalidTLSConfig, config.MinVersion)
+	}
+
+	if config.CAFile != "" {
+		data, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read CA bundle: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("%w: no certificates in %s", ErrInvalidTLSConfig, config.CAFile)
+		}
+	}
+
+	if (config.CertFile == "") != (config.KeyFile == "") {
+		return nil, fmt.Errorf("%w: both client certificate and key are required", ErrInvalidTLSConfig)
+	}
+	if config.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
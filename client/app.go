@@ -5,15 +5,23 @@ import (
 	"errors"
 	"fmt"
 	"math/rand"
+	"os"
 	"time"
 
+	"github.com/dliakhov/bloxroutelabs/client-server-app/logging"
 	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/tracing"
 	"github.com/google/uuid"
-	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var log = logging.Logger("client")
+
 const traceIDKey = "X-Trace-ID"
 
+var tracer = otel.Tracer("github.com/dliakhov/bloxroutelabs/client-server-app/client")
+
 type App struct {
 	client *Client
 }
@@ -28,15 +36,23 @@ func (a *App) Start(commandType models.CommandType) error {
 	rand.Seed(time.Now().Unix())
 
 	for {
-		traceID := uuid.New().String()
-		ctx := context.WithValue(context.Background(), traceIDKey, traceID)
-
 		command, err := createRandomCommand(commandType)
 		if err != nil {
 			return err
 		}
+		ctx, span := startTrace(command.Type.String())
+		command.Collection = a.client.config.Collection
+		if command.Type == models.CommandType_AddItem {
+			command.ItemTTLSeconds = a.client.config.ItemTTLSeconds
+		}
 
-		err = a.client.SendCommand(ctx, command)
+		if command.Type == models.CommandType_GetAllItems && a.client.config.ShardingConfig.Shards > 0 {
+			// items of all shards are gathered and merged by the client
+			err = a.queryAllItems(ctx, command)
+		} else {
+			err = a.client.SendCommand(ctx, command)
+		}
+		tracing.End(span, err)
 		if err != nil {
 			log.WithField(traceIDKey, ctx.Value(traceIDKey)).Errorf("Fail send command: %v", err)
 		}
@@ -47,6 +63,60 @@ func (a *App) Start(commandType models.CommandType) error {
 	}
 }
 
+func (a *App) queryAllItems(ctx context.Context, command *models.Command) error {
+	items, err := a.client.QueryItems(ctx, command)
+	if err != nil {
+		return err
+	}
+
+	log.WithField(traceIDKey, ctx.Value(traceIDKey)).Infof("Got %d items of all shards: %v", len(items), items)
+	return nil
+}
+
+// SendFile sends commands and batches from the JSONL file in the same order. See ReadCommands for the format.
+func (a *App) SendFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	messages, err := ReadCommands(f)
+	if err != nil {
+		return fmt.Errorf("cannot read commands from %s: %w", path, err)
+	}
+
+	for _, msg := range messages {
+		switch msg := msg.(type) {
+		case *models.Command:
+			ctx, span := startTrace(msg.Type.String())
+			err = a.client.SendCommand(ctx, msg)
+			tracing.End(span, err)
+		case *models.Batch:
+			ctx, span := startTrace(models.MessageTypeBatch)
+			err = a.client.SendBatch(ctx, msg)
+			tracing.End(span, err)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Infof("All %d messages from %s were sent", len(messages), path)
+	return nil
+}
+
+// startTrace starts the trace of the sent message. Its id is used as the trace id of logs, a random one is used
+// when tracing is disabled.
+func startTrace(name string) (context.Context, trace.Span) {
+	ctx, span := tracer.Start(context.Background(), name)
+	traceID := tracing.TraceID(ctx)
+	if traceID == "" {
+		traceID = uuid.NewString()
+	}
+	return context.WithValue(ctx, traceIDKey, traceID), span
+}
+
 func createRandomCommand(commandType models.CommandType) (*models.Command, error) {
 	switch commandType {
 	case models.CommandType_AddItem:
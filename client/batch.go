@@ -0,0 +1,72 @@
+package client
+
+import "github.com/dliakhov/bloxroutelabs/client-server-app/models"
+
+// BatchBuilder builds batch of commands for one collection. Commands are applied in the order they are added.
+type BatchBuilder struct {
+	collection string
+	commands   []*models.Command
+}
+
+func NewBatchBuilder(collection string) *BatchBuilder {
+	return &BatchBuilder{collection: collection}
+}
+
+func (b *BatchBuilder) AddItem(itemID int64, payload string, ttlSeconds int64) *BatchBuilder {
+	return b.add(&models.Command{
+		Type:           models.CommandType_AddItem,
+		ItemID:         itemID,
+		ItemPayload:    payload,
+		ItemTTLSeconds: ttlSeconds,
+	})
+}
+
+// UpdateItem changes payload of the existing item. When expectedVersion is not zero, the whole batch fails
+// if the item version is different.
+func (b *BatchBuilder) UpdateItem(itemID int64, payload string, expectedVersion uint64) *BatchBuilder {
+	return b.add(&models.Command{
+		Type:            models.CommandType_UpdateItem,
+		ItemID:          itemID,
+		ItemPayload:     payload,
+		ExpectedVersion: expectedVersion,
+	})
+}
+
+// RemoveItemIfVersion removes the item only if it has expectedVersion, otherwise the whole batch fails.
+func (b *BatchBuilder) RemoveItemIfVersion(itemID int64, expectedVersion uint64) *BatchBuilder {
+	return b.add(&models.Command{
+		Type:            models.CommandType_RemoveItem,
+		ItemID:          itemID,
+		ExpectedVersion: expectedVersion,
+	})
+}
+
+func (b *BatchBuilder) RemoveItem(itemID int64) *BatchBuilder {
+	return b.add(&models.Command{
+		Type:   models.CommandType_RemoveItem,
+		ItemID: itemID,
+	})
+}
+
+func (b *BatchBuilder) GetItem(itemID int64) *BatchBuilder {
+	return b.add(&models.Command{
+		Type:   models.CommandType_GetItem,
+		ItemID: itemID,
+	})
+}
+
+func (b *BatchBuilder) GetAllItems() *BatchBuilder {
+	return b.add(&models.Command{
+		Type: models.CommandType_GetAllItems,
+	})
+}
+
+func (b *BatchBuilder) Build() *models.Batch {
+	return &models.Batch{Commands: b.commands}
+}
+
+func (b *BatchBuilder) add(command *models.Command) *BatchBuilder {
+	command.Collection = b.collection
+	b.commands = append(b.commands, command)
+	return b
+}
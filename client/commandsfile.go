@@ -0,0 +1,67 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const maxLineSize = 1024 * 1024
+
+// ReadCommands parses commands in JSONL format: every line is a JSON object of a command, e.g.
+//
+//	{"type": "AddItem", "ItemID": 1, "ItemPayload": "A", "Collection": "sessions"}
+//
+// or of a batch with "Commands" list:
+//
+//	{"Commands": [{"type": "AddItem", "ItemID": 1, "ItemPayload": "A"}, {"type": "RemoveItem", "ItemID": 2}]}
+//
+// Empty lines and lines starting with # are skipped. Returned messages are *models.Command or *models.Batch.
+func ReadCommands(r io.Reader) ([]proto.Message, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	var messages []proto.Message
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		msg, err := parseCommandLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		messages = append(messages, msg)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+func parseCommandLine(line []byte) (proto.Message, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(line, &fields); err != nil {
+		return nil, err
+	}
+
+	var msg proto.Message = new(models.Command)
+	if _, ok := fields["Commands"]; ok {
+		msg = new(models.Batch)
+	}
+
+	if err := protojson.Unmarshal(line, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
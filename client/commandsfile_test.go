@@ -0,0 +1,64 @@
+package client
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestReadCommands(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []proto.Message
+		wantErr bool
+	}{
+		{
+			name: "should read commands and batches in order",
+			input: `# comment
+{"type": "AddItem", "ItemID": 1, "ItemPayload": "A", "ItemTTLSeconds": "10"}
+
+{"Commands": [{"type": "AddItem", "ItemID": 2, "ItemPayload": "B", "Collection": "sessions"}, {"type": "RemoveItem", "ItemID": "3", "Collection": "sessions"}]}
+{"type": "GetAllItems"}
+`,
+			want: []proto.Message{
+				&models.Command{Type: models.CommandType_AddItem, ItemID: 1, ItemPayload: "A", ItemTTLSeconds: 10},
+				NewBatchBuilder("sessions").AddItem(2, "B", 0).RemoveItem(3).Build(),
+				&models.Command{Type: models.CommandType_GetAllItems},
+			},
+		},
+		{
+			name:    "should return error when line is not json",
+			input:   `{"type": "AddItem"}` + "\nAddItem 1 A\n",
+			wantErr: true,
+		},
+		{
+			name:    "should return error when command type is unknown",
+			input:   `{"type": "UpdateEverything"}`,
+			wantErr: true,
+		},
+		{
+			name:    "should return error when field is unknown",
+			input:   `{"type": "AddItem", "Payload": "A"}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadCommands(strings.NewReader(tt.input))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, got, len(tt.want))
+			for i := range tt.want {
+				assert.Truef(t, proto.Equal(tt.want[i], got[i]), "message %d: want %v, got %v", i, tt.want[i], got[i])
+			}
+		})
+	}
+}
@@ -1,13 +1,118 @@
 package client
 
+import (
+	"github.com/dliakhov/bloxroutelabs/client-server-app/broker"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/logging"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/signing"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/tracing"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/validation"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
 type Configurations struct {
 	RabbitMQConfig RabbitMQConfig
 	CommandType    string
+	// ItemTTLSeconds is set to AddItem commands when positive, so added items expire on the server.
+	ItemTTLSeconds int64
+	// Collection is the name of the collection commands are sent to, empty means default collection.
+	Collection string
+	// CommandsFile is a JSONL file with commands and batches. When it is set, client sends commands from the file
+	// instead of random commands of CommandType.
+	CommandsFile string
+	// ShardingConfig routes commands to shards by item id when Shards is set.
+	ShardingConfig ShardingConfig
+	// Envelope makes the client send commands wrapped in the versioned models.Envelope, it should be enabled
+	// when all servers support it. Batches are sent as they are.
+	Envelope bool
+	// ClientID is sent in envelopes, a random id is generated when it is empty. It is required for signing.
+	ClientID string
+	// SigningConfig signs messages with the key of ClientID when its algorithm is set, see signing.Key.
+	SigningConfig signing.Key
+	// TracingConfig exports spans of sent messages, see tracing.Config.
+	TracingConfig tracing.Config
+	// LoggingConfig sets the level, format and outputs of logs, see logging.Config.
+	LoggingConfig logging.Config
+}
+
+// DefaultConfigurations returns the configuration which is used for settings missing in the config file, env and flags.
+func DefaultConfigurations() Configurations {
+	return Configurations{
+		RabbitMQConfig: RabbitMQConfig{
+			URL:         "localhost:5672",
+			User:        "guest",
+			Password:    "guest",
+			QueueName:   "items_queue",
+			ContentType: models.ContentTypeProtobuf,
+		},
+		CommandType:    models.CommandType_AddItem.String(),
+		ShardingConfig: ShardingConfig{Exchange: "items_shards"},
+		TracingConfig:  tracing.Config{Endpoint: "localhost:4317", SampleRatio: 1},
+		LoggingConfig:  logging.Config{Level: "info", Format: logging.FormatText, Outputs: []string{logging.OutputStdout}},
+	}
+}
+
+// Validate checks the configuration and returns all its problems in validation.Error.
+func (c Configurations) Validate() error {
+	var errs validation.Errors
+	errs.Add("rabbitmqconfig", c.RabbitMQConfig.Validate())
+	errs.Add("shardingconfig", c.ShardingConfig.Validate())
+	if c.SigningConfig.Algorithm != "" {
+		if c.ClientID == "" {
+			errs.Addf("clientid", "is required for signing")
+		}
+		errs.Add("signingconfig", c.SigningConfig.Validate())
+	}
+	if c.CommandsFile == "" {
+		if _, ok := models.CommandType_value[c.CommandType]; !ok {
+			errs.Addf("commandtype", "unknown command type %q", c.CommandType)
+		}
+	}
+	if c.ItemTTLSeconds < 0 {
+		errs.Addf("itemttlseconds", "cannot be negative")
+	}
+	errs.Add("tracingconfig", c.TracingConfig.Validate())
+	errs.Add("loggingconfig", c.LoggingConfig.Validate())
+	return errs.Err()
 }
 
 type RabbitMQConfig struct {
 	URL       string
 	User      string
-	Password  string
+	Password  string `secret:"true"`
 	QueueName string
+	// ContentType is the encoding of sent messages: application/protobuf (default) or application/json.
+	ContentType string
+	// TLS and ExternalAuth secure the connection, see broker.Config.
+	TLS          broker.TLSConfig
+	ExternalAuth bool
+	// MaxPriority must be the same as the queue is declared with by the server, see server.RabbitMQConfig.
+	MaxPriority uint8
+}
+
+func (c RabbitMQConfig) Validate() error {
+	var errs validation.Errors
+	errs.Add("", c.brokerConfig().Validate())
+	if c.QueueName == "" {
+		errs.Addf("queuename", "is required")
+	}
+	if _, err := models.ParseContentType(c.ContentType); err != nil {
+		errs.Add("contenttype", err)
+	}
+	return errs.Err()
+}
+
+// Dial opens the connection to RabbitMQ.
+func (c RabbitMQConfig) Dial() (*amqp.Connection, error) {
+	return broker.Dial(c.brokerConfig())
+}
+
+func (c RabbitMQConfig) brokerConfig() broker.Config {
+	return broker.Config{
+		URL:          c.URL,
+		User:         c.User,
+		Password:     c.Password,
+		TLS:          c.TLS,
+		ExternalAuth: c.ExternalAuth,
+	}
 }
@@ -0,0 +1,71 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/signing"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigurations_Validate(t *testing.T) {
+	tests := []struct {
+		name       string
+		configure  func(c *Configurations)
+		wantFields []string
+	}{
+		{
+			name:      "should accept defaults",
+			configure: func(c *Configurations) {},
+		},
+		{
+			name: "should not check command type when commands file is set",
+			configure: func(c *Configurations) {
+				c.CommandType = ""
+				c.CommandsFile = "commands.jsonl"
+			},
+		},
+		{
+			name: "should report all problems",
+			configure: func(c *Configurations) {
+				c.RabbitMQConfig.QueueName = ""
+				c.RabbitMQConfig.ContentType = "text/xml"
+				c.ShardingConfig = ShardingConfig{Shards: 2}
+				c.SigningConfig = signing.Key{Algorithm: signing.AlgorithmHMAC}
+				c.CommandType = "AddItems"
+				c.ItemTTLSeconds = -1
+				c.TracingConfig.SampleRatio = 2
+			},
+			wantFields: []string{
+				"rabbitmqconfig.queuename",
+				"rabbitmqconfig.contenttype",
+				"shardingconfig.exchange",
+				"clientid",
+				"signingconfig",
+				"commandtype",
+				"itemttlseconds",
+				"tracingconfig.sampleratio",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configuration := DefaultConfigurations()
+			tt.configure(&configuration)
+
+			err := configuration.Validate()
+			if tt.wantFields == nil {
+				assert.NoError(t, err)
+				return
+			}
+			var validationErr *validation.Error
+			require.ErrorAs(t, err, &validationErr)
+			fields := make([]string, 0, len(validationErr.Problems))
+			for _, problem := range validationErr.Problems {
+				fields = append(fields, problem.Field)
+			}
+			assert.Equal(t, tt.wantFields, fields)
+		})
+	}
+}
@@ -2,29 +2,57 @@ package client
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
 	"time"
 
+	"github.com/dliakhov/bloxroutelabs/client-server-app/broker"
 	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/signing"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/tracing"
+	"github.com/google/uuid"
 	amqp "github.com/rabbitmq/amqp091-go"
-	log "github.com/sirupsen/logrus"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/protobuf/proto"
 )
 
+const replyTimeout = 10 * time.Second
+
+// ErrCommandFailed is returned when the server replies that the command cannot be processed.
+var ErrCommandFailed = errors.New("command failed")
+
 type Client struct {
 	conn   *amqp.Connection
 	config Configurations
+	// signer signs sent messages when signing is configured
+	signer *signing.Signer
 }
 
 func New(config Configurations) *Client {
+	// signed messages are verified by the key of the client id, so it cannot be random
+	if config.ClientID == "" && config.SigningConfig.Algorithm == "" {
+		config.ClientID = uuid.NewString()
+	}
 	return &Client{
 		config: config,
 	}
 }
 
 func (c *Client) InitClient() error {
+	if _, err := models.ParseContentType(c.config.RabbitMQConfig.ContentType); err != nil {
+		return err
+	}
+
 	var err error
-	c.conn, err = amqp.Dial(fmt.Sprintf("amqp://%s:%s@%s", c.config.RabbitMQConfig.User, c.config.RabbitMQConfig.Password, c.config.RabbitMQConfig.URL))
+	if c.config.SigningConfig.Algorithm != "" {
+		if c.signer, err = signing.NewSigner(c.config.ClientID, c.config.SigningConfig); err != nil {
+			return err
+		}
+	}
+
+	c.conn, err = c.config.RabbitMQConfig.Dial()
 	if err != nil {
 		return err
 	}
@@ -42,49 +70,242 @@ func (c *Client) SendCommand(ctx context.Context, command *models.Command) error
 	log.WithField(traceIDKey, ctx.Value(traceIDKey)).
 		Info("Sending command. Type: ", command.Type.String(), ", Payload: ", command.String())
 
+	return c.publish(ctx, models.MessageTypeCommand, command)
+}
+
+// SendBatch sends commands which are applied by the server atomically.
+func (c *Client) SendBatch(ctx context.Context, batch *models.Batch) error {
+	log.WithField(traceIDKey, ctx.Value(traceIDKey)).
+		Infof("Sending batch of %d commands. Payload: %s", len(batch.Commands), batch.String())
+
+	return c.publish(ctx, models.MessageTypeBatch, batch)
+}
+
+// QueryItems sends GetItem or GetAllItems command and waits for the found items. When sharding is enabled,
+// GetAllItems is sent to all shards and their items are merged, see mergeBySequence.
+func (c *Client) QueryItems(ctx context.Context, command *models.Command) ([]models.Item, error) {
+	log.WithField(traceIDKey, ctx.Value(traceIDKey)).
+		Info("Sending query. Type: ", command.Type.String(), ", Payload: ", command.String())
+
+	return c.request(ctx, command)
+}
+
+// ExecuteCommand sends the command and waits until the server replies that it is processed.
+func (c *Client) ExecuteCommand(ctx context.Context, command *models.Command) error {
+	log.WithField(traceIDKey, ctx.Value(traceIDKey)).
+		Info("Sending command and waiting for reply. Type: ", command.Type.String(), ", Payload: ", command.String())
+
+	_, err := c.request(ctx, command)
+	return err
+}
+
+// request sends the command with ReplyTo queue and returns items of all replies merged by sequences.
+func (c *Client) request(ctx context.Context, command *models.Command) ([]models.Item, error) {
 	ch, err := c.conn.Channel()
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer ch.Close()
 
-	queue, err := ch.QueueDeclare(
-		c.config.RabbitMQConfig.QueueName,
+	// every query has its own reply queue which is deleted when the channel is closed
+	replyQueue, err := ch.QueueDeclare(
+		"",
+		false,
+		true,
 		true,
 		false,
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+	replies, err := ch.Consume(
+		replyQueue.Name,
+		"",
+		true,
+		true,
 		false,
 		false,
 		nil,
 	)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, replyTimeout)
+	defer cancel()
+
+	correlationID := uuid.NewString()
+	sent, err := c.send(ctx, ch, models.MessageTypeCommand, command, amqp.Publishing{
+		ReplyTo:       replyQueue.Name,
+		CorrelationId: correlationID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([][]models.Item, 0, sent)
+	for len(results) < sent {
+		select {
+		case d, ok := <-replies:
+			if !ok {
+				return nil, errors.New("reply queue is closed")
+			}
+			if d.CorrelationId != correlationID {
+				continue
+			}
+
+			reply := new(models.Reply)
+			if err := models.Unmarshal(d.ContentType, d.Body, reply); err != nil {
+				return nil, err
+			}
+			if reply.Error != "" {
+				return nil, fmt.Errorf("%w: %s", ErrCommandFailed, reply.Error)
+			}
+
+			items := make([]models.Item, 0, len(reply.Items))
+			for _, record := range reply.Items {
+				items = append(items, record.Item())
+			}
+			results = append(results, items)
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%d of %d replies are received: %w", len(results), sent, ctx.Err())
+		}
+	}
+	return mergeBySequence(results), nil
+}
+
+func (c *Client) publish(ctx context.Context, messageType string, msg proto.Message) error {
+	ch, err := c.conn.Channel()
 	if err != nil {
 		return err
 	}
+	defer ch.Close()
 
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	body, err := proto.Marshal(command)
+	_, err = c.send(ctx, ch, messageType, msg, amqp.Publishing{})
 	if err != nil {
 		return err
 	}
 
-	err = ch.PublishWithContext(
-		ctx,
-		"",
-		queue.Name,
+	log.WithField(traceIDKey, ctx.Value(traceIDKey)).Info("[x] Sent message")
+	return nil
+}
+
+// send publishes the message to the queue or to the shards of the message and returns the number of sent messages.
+// Properties of publishing are completed by the message.
+func (c *Client) send(ctx context.Context, ch *amqp.Channel, messageType string, msg proto.Message, publishing amqp.Publishing) (sent int, err error) {
+	ctx, span := tracer.Start(ctx, "publish "+messageType, trace.WithSpanKind(trace.SpanKindProducer), trace.WithAttributes(
+		semconv.MessagingSystem("rabbitmq"),
+		semconv.MessagingOperationPublish,
+		semconv.MessagingDestinationName(c.config.RabbitMQConfig.QueueName),
+	))
+	defer func() { tracing.End(span, err) }()
+
+	contentType := c.config.RabbitMQConfig.ContentType
+	if contentType == "" {
+		contentType = models.ContentTypeProtobuf
+	}
+	messageType, wire, err := c.wrap(messageType, msg)
+	if err != nil {
+		return 0, err
+	}
+	body, err := models.Marshal(contentType, wire)
+	if err != nil {
+		return 0, err
+	}
+
+	publishing.Headers = map[string]any{
+		traceIDKey: ctx.Value(traceIDKey),
+	}
+	tracing.Inject(ctx, publishing.Headers)
+	publishing.Type = messageType
+	publishing.DeliveryMode = amqp.Persistent
+	publishing.Priority = priorityOf(msg)
+	publishing.ContentType = contentType
+	publishing.Body = body
+	if c.signer != nil {
+		if err := c.signer.Sign(&publishing); err != nil {
+			return 0, err
+		}
+	}
+
+	sharding := c.config.ShardingConfig
+	if sharding.Shards == 0 {
+		queue, err := ch.QueueDeclare(
+			c.config.RabbitMQConfig.QueueName,
+			true,
+			false,
+			false,
+			false,
+			broker.QueueArgs(c.config.RabbitMQConfig.MaxPriority),
+		)
+		if err != nil {
+			return 0, err
+		}
+
+		return 1, ch.PublishWithContext(ctx, "", queue.Name, false, false, publishing)
+	}
+
+	shards, err := shardsOf(msg, sharding.Shards)
+	if err != nil {
+		return 0, err
+	}
+
+	err = ch.ExchangeDeclare(
+		sharding.Exchange,
+		amqp.ExchangeDirect,
+		true,
+		false,
 		false,
 		false,
-		amqp.Publishing{
-			Headers: map[string]any{
-				traceIDKey: ctx.Value(traceIDKey),
-			},
-			DeliveryMode: amqp.Persistent,
-			ContentType:  "application/protobuf",
-			Body:         body,
-		})
+		nil,
+	)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	log.WithField(traceIDKey, ctx.Value(traceIDKey)).Info("[x] Sent message")
-	return nil
+	for _, shard := range shards {
+		// message is dropped if the server of the shard has never been started and its queue does not exist
+		err = ch.PublishWithContext(ctx, sharding.Exchange, models.ShardRoutingKey(shard), false, false, publishing)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(shards), nil
+}
+
+// priorityOf returns the AMQP priority of the command, batches have the highest priority of their commands.
+func priorityOf(msg proto.Message) uint8 {
+	var priority uint32
+	switch msg := msg.(type) {
+	case *models.Command:
+		priority = msg.Priority
+	case *models.Batch:
+		for _, command := range msg.Commands {
+			if command.Priority > priority {
+				priority = command.Priority
+			}
+		}
+	}
+	if priority > math.MaxUint8 {
+		return math.MaxUint8
+	}
+	return uint8(priority)
+}
+
+// wrap returns the command wrapped in the envelope when envelopes are enabled, other messages are returned as they are.
+func (c *Client) wrap(messageType string, msg proto.Message) (string, proto.Message, error) {
+	command, ok := msg.(*models.Command)
+	if !ok || !c.config.Envelope {
+		return messageType, msg, nil
+	}
+
+	envelope, err := models.NewEnvelope(command, c.config.ClientID, time.Now())
+	if err != nil {
+		return "", nil, err
+	}
+	return models.MessageTypeEnvelope, envelope, nil
 }
@@ -0,0 +1,42 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func Test_priorityOf(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  proto.Message
+		want uint8
+	}{
+		{
+			name: "should send command with its priority",
+			msg:  &models.Command{Type: models.CommandType_RemoveItem, Priority: 9},
+			want: 9,
+		},
+		{
+			name: "should limit priority by AMQP range",
+			msg:  &models.Command{Type: models.CommandType_RemoveItem, Priority: 1000},
+			want: 255,
+		},
+		{
+			name: "should send batch with the highest priority of its commands",
+			msg: &models.Batch{Commands: []*models.Command{
+				{Type: models.CommandType_AddItem, Priority: 1},
+				{Type: models.CommandType_RemoveItem, Priority: 5},
+				{Type: models.CommandType_GetAllItems},
+			}},
+			want: 5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, priorityOf(tt.msg))
+		})
+	}
+}
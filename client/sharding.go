@@ -0,0 +1,90 @@
+package client
+
+import (
+	"errors"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/validation"
+	"google.golang.org/protobuf/proto"
+)
+
+var ErrBatchSpansShards = errors.New("items of the batch belong to different shards")
+
+type ShardingConfig struct {
+	// Exchange routes commands to shard queues, it must be the same as servers use.
+	Exchange string
+	// Shards is the total number of shards, zero disables sharding.
+	Shards int
+}
+
+func (c ShardingConfig) Validate() error {
+	var errs validation.Errors
+	if c.Shards < 0 {
+		errs.Addf("shards", "cannot be negative")
+	}
+	if c.Shards > 0 && c.Exchange == "" {
+		errs.Addf("exchange", "is required for sharding")
+	}
+	return errs.Err()
+}
+
+// shardsOf returns shards the message should be sent to. Commands for items are sent to the shard of the item,
+// other commands are sent to all shards. Batch is sent to the shard of its items, which must be the same.
+func shardsOf(msg proto.Message, shards int) ([]int, error) {
+	switch msg := msg.(type) {
+	case *models.Command:
+		if hasItem(msg) {
+			return []int{models.ShardOf(msg.ItemID, shards)}, nil
+		}
+	case *models.Batch:
+		shard := -1
+		for _, command := range msg.Commands {
+			if !hasItem(command) {
+				continue
+			}
+			commandShard := models.ShardOf(command.ItemID, shards)
+			if shard != -1 && shard != commandShard {
+				return nil, ErrBatchSpansShards
+			}
+			shard = commandShard
+		}
+		if shard != -1 {
+			return []int{shard}, nil
+		}
+	}
+
+	all := make([]int, shards)
+	for shard := range all {
+		all[shard] = shard
+	}
+	return all, nil
+}
+
+func hasItem(command *models.Command) bool {
+	switch command.Type {
+	case models.CommandType_AddItem, models.CommandType_UpdateItem, models.CommandType_RemoveItem, models.CommandType_GetItem:
+		return true
+	default:
+		return false
+	}
+}
+
+// mergeBySequence merges items of shards keeping the order of every shard, which is its insertion order. Items of
+// different shards are interleaved by sequences, which are assigned by clocks of the shards, so their relative order
+// is approximate only.
+func mergeBySequence(results [][]models.Item) []models.Item {
+	var items []models.Item
+	for {
+		next := -1
+		for shard, result := range results {
+			if len(result) > 0 && (next == -1 || result[0].Sequence < results[next][0].Sequence) {
+				next = shard
+			}
+		}
+		if next == -1 {
+			return items
+		}
+		items = append(items, results[next][0])
+		results[next] = results[next][1:]
+	}
+}
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func Test_shardsOf(t *testing.T) {
+	itemShard := models.ShardOf(1, 3)
+	otherItemID := int64(2)
+	for models.ShardOf(otherItemID, 3) == itemShard {
+		otherItemID++
+	}
+
+	tests := []struct {
+		name    string
+		msg     proto.Message
+		want    []int
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "should send item command to the shard of the item",
+			msg:     &models.Command{Type: models.CommandType_RemoveItem, ItemID: 1},
+			want:    []int{itemShard},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "should send collection command to all shards",
+			msg:     &models.Command{Type: models.CommandType_GetAllItems},
+			want:    []int{0, 1, 2},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "should send batch to the shard of its items",
+			msg: &models.Batch{Commands: []*models.Command{
+				{Type: models.CommandType_AddItem, ItemID: 1},
+				{Type: models.CommandType_GetAllItems},
+				{Type: models.CommandType_GetItem, ItemID: 1},
+			}},
+			want:    []int{itemShard},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "should return error when batch items belong to different shards",
+			msg: &models.Batch{Commands: []*models.Command{
+				{Type: models.CommandType_AddItem, ItemID: 1},
+				{Type: models.CommandType_AddItem, ItemID: otherItemID},
+			}},
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := shardsOf(tt.msg, 3)
+			tt.wantErr(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_mergeBySequence(t *testing.T) {
+	got := mergeBySequence([][]models.Item{
+		{{ID: 1, Sequence: 10}, {ID: 4, Sequence: 40}},
+		nil,
+		{{ID: 2, Sequence: 20}, {ID: 3, Sequence: 30}, {ID: 5, Sequence: 50}},
+	})
+
+	assert.Equal(t, []models.Item{
+		{ID: 1, Sequence: 10},
+		{ID: 2, Sequence: 20},
+		{ID: 3, Sequence: 30},
+		{ID: 4, Sequence: 40},
+		{ID: 5, Sequence: 50},
+	}, got)
+
+	// order of the shard is kept even when its sequences are not ascending, e.g. after the clock is set back
+	got = mergeBySequence([][]models.Item{
+		{{ID: 1, Sequence: 10}, {ID: 2, Sequence: 30}, {ID: 3, Sequence: 20}},
+		{{ID: 4, Sequence: 25}},
+	})
+	assert.Equal(t, []models.Item{
+		{ID: 1, Sequence: 10},
+		{ID: 4, Sequence: 25},
+		{ID: 2, Sequence: 30},
+		{ID: 3, Sequence: 20},
+	}, got)
+}
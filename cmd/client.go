@@ -3,15 +3,12 @@ package cmd
 import (
 	"os"
 	"os/signal"
-	"strings"
 	"syscall"
 
 	"github.com/dliakhov/bloxroutelabs/client-server-app/client"
 	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
-	"github.com/iamolegga/enviper"
-	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
+	"github.com/spf13/pflag"
 )
 
 func clientCmd() *cobra.Command {
@@ -19,83 +16,94 @@ func clientCmd() *cobra.Command {
 		Use:   "client",
 		Short: "Client application",
 		Run: func(cmd *cobra.Command, args []string) {
-			log.SetOutput(os.Stdout)
-
-			configuration, err := getClientConfiguration()
+			configuration, err := getClientConfiguration(cmd.Flags())
 			if err != nil {
 				log.Errorf("Cannot read configuration: %v", err)
 				return
 			}
+			if err := configuration.Validate(); err != nil {
+				logInvalidConfiguration(err)
+				return
+			}
+
+			stopLogging, err := initLogging(configuration.LoggingConfig)
+			if err != nil {
+				log.Errorf("Invalid logging configuration: %v", err)
+				return
+			}
+			defer stopLogging()
 
 			startClientApp(configuration)
 		},
 	}
 
+	addConfigFlags(clientCmd.PersistentFlags(), "client", client.DefaultConfigurations())
+	clientCmd.AddCommand(configCmd(func(flags *pflag.FlagSet) (serviceConfig, error) {
+		return getClientConfiguration(flags)
+	}))
+
 	return clientCmd
 }
 
-func getClientConfiguration() (client.Configurations, error) {
-	e := enviper.New(viper.New())
-
-	var pwd string
-	var err error
-	if pwd, err = os.Getwd(); err != nil {
-		log.Fatal("unable to get current working directory: ", err)
-	}
-
-	e.AddConfigPath(pwd)
-	e.SetConfigName(".config.client")
-
-	// enable viper to handle env values for nested structs
-	e.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
-
-	// defaults to ENV variable values
-	e.AutomaticEnv()
-
-	var configuration client.Configurations
-	if err := e.ReadInConfig(); err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
-			log.Fatal("Error reading config file: ", err)
-		}
-	}
-
-	err = e.Unmarshal(&configuration)
-	if err != nil {
-		log.Errorf("Unable to decode into struct, %v", err)
+func getClientConfiguration(flags *pflag.FlagSet) (client.Configurations, error) {
+	configuration := client.DefaultConfigurations()
+	if _, err := loadConfiguration(flags, "client", &configuration); err != nil {
 		return client.Configurations{}, err
 	}
 	return configuration, nil
 }
 
 func startClientApp(configuration client.Configurations) {
+	stopTracing, err := initTracing("client", configuration.TracingConfig)
+	if err != nil {
+		log.Errorf("Cannot init tracing: %v", err)
+		return
+	}
+	defer stopTracing()
+
 	c := client.New(configuration)
 	app := client.NewApp(c)
 
-	terminate := make(chan os.Signal)
+	terminate := make(chan os.Signal, 1)
 	signal.Notify(terminate, syscall.SIGTERM, syscall.SIGINT)
 
-	commandType, ok := models.CommandType_value[configuration.CommandType]
-	if !ok {
-		log.Errorf("Command not found: %s", configuration.CommandType)
-		return
-	}
+	done := make(chan struct{})
 	go func() {
+		defer close(done)
+
 		err := c.InitClient()
 		if err != nil {
 			log.Errorf("Cannot initialize client: %v", err)
 			return
 		}
 
+		if configuration.CommandsFile != "" {
+			err = app.SendFile(configuration.CommandsFile)
+			if err != nil {
+				log.Errorf("Error happened for client: %v", err)
+			}
+			return
+		}
+
+		commandType, ok := models.CommandType_value[configuration.CommandType]
+		if !ok {
+			log.Errorf("Command not found: %s", configuration.CommandType)
+			return
+		}
+
 		err = app.Start(models.CommandType(commandType))
 		if err != nil {
 			log.Errorf("Error happened for client: %v", err)
 		}
 	}()
 
-	<-terminate
+	select {
+	case <-terminate:
+	case <-done:
+	}
 	log.Info("Terminating application")
 
-	err := c.Cleanup()
+	err = c.Cleanup()
 	if err != nil {
 		log.Errorf("Error happened when cleaning up client: %v", err)
 		return
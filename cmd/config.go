@@ -0,0 +1,287 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/validation"
+	"github.com/iamolegga/enviper"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// configFlag is the path to the config file, other flags are named by paths of settings in the config file.
+	configFlag = "config"
+	// settingAnnotation marks flags of settings.
+	settingAnnotation = "setting"
+	redacted          = "<redacted>"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// serviceConfig is the configuration of a service.
+type serviceConfig interface {
+	Validate() error
+}
+
+// addConfigFlags adds the flag of the config file and flags of all settings of the configuration with its values
+// as defaults, e.g. --rabbitmqconfig.url. Lists of structs and maps can be set in the config file only.
+func addConfigFlags(flags *pflag.FlagSet, service string, defaults serviceConfig) {
+	flags.String(configFlag, "", fmt.Sprintf("path to the config file, .config.%s.<ext> in the working directory by default", service))
+
+	walkSettings(reflect.ValueOf(defaults), "", func(key string, value reflect.Value) {
+		usage := "env " + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+		if value.Type() == durationType {
+			flags.Duration(key, time.Duration(value.Int()), usage)
+		} else {
+			switch value.Kind() {
+			case reflect.String:
+				flags.String(key, value.String(), usage)
+			case reflect.Bool:
+				flags.Bool(key, value.Bool(), usage)
+			case reflect.Int:
+				flags.Int(key, int(value.Int()), usage)
+			case reflect.Int64:
+				flags.Int64(key, value.Int(), usage)
+			case reflect.Uint8:
+				flags.Uint8(key, uint8(value.Uint()), usage)
+			case reflect.Float64:
+				flags.Float64(key, value.Float(), usage)
+			case reflect.Slice:
+				if value.Type().Elem().Kind() != reflect.String {
+					return
+				}
+				flags.StringSlice(key, value.Interface().([]string), usage)
+			default:
+				return
+			}
+		}
+		_ = flags.SetAnnotation(key, settingAnnotation, []string{"true"})
+	})
+}
+
+// walkSettings calls fn for settings of the struct which are not structs, key is the path of the setting
+// in the config file.
+func walkSettings(v reflect.Value, prefix string, fn func(key string, value reflect.Value)) {
+	for i := 0; i < v.NumField(); i++ {
+		field, value := v.Type().Field(i), v.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		key := validation.Join(prefix, strings.ToLower(field.Name))
+		switch {
+		case field.Tag.Get("mapstructure") == ",squash":
+			walkSettings(value, prefix, fn)
+		case value.Kind() == reflect.Struct:
+			walkSettings(value, key, fn)
+		default:
+			fn(key, value)
+		}
+	}
+}
+
+// loadConfiguration reads the configuration of the service from the config file, env and flags, in the order of
+// increasing priority. Settings which are not set keep values of the configuration. It returns the path
+// of the config file, which is empty when the file is not found.
+func loadConfiguration(flags *pflag.FlagSet, service string, configuration serviceConfig) (string, error) {
+	e := enviper.New(viper.New())
+
+	path, err := flags.GetString(configFlag)
+	if err != nil {
+		return "", err
+	}
+	if path != "" {
+		e.SetConfigFile(path)
+	} else {
+		pwd, err := os.Getwd()
+		if err != nil {
+			return "", fmt.Errorf("unable to get current working directory: %w", err)
+		}
+		e.AddConfigPath(pwd)
+		e.SetConfigName(".config." + service)
+	}
+
+	// enable viper to handle env values for nested structs
+	e.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+
+	// defaults to ENV variable values
+	e.AutomaticEnv()
+
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if _, ok := flag.Annotations[settingAnnotation]; ok && err == nil {
+			err = e.BindPFlag(flag.Name, flag)
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// the config file is read by Unmarshal, a missing file is fine unless it is set by the flag
+	if err := e.Unmarshal(configuration); err != nil {
+		return "", fmt.Errorf("cannot read configuration: %w", err)
+	}
+	return e.ConfigFileUsed(), nil
+}
+
+// changedSettings returns keys of settings which differ in the configurations, lists and maps are compared
+// as a whole.
+func changedSettings(current, next serviceConfig) []string {
+	nextValues := make(map[string]reflect.Value)
+	walkSettings(reflect.ValueOf(next), "", func(key string, value reflect.Value) {
+		nextValues[key] = value
+	})
+
+	var changed []string
+	walkSettings(reflect.ValueOf(current), "", func(key string, value reflect.Value) {
+		nextValue := nextValues[key]
+		switch {
+		case (value.Kind() == reflect.Slice || value.Kind() == reflect.Map) && value.Len() == 0 && nextValue.Len() == 0:
+		case !reflect.DeepEqual(value.Interface(), nextValue.Interface()):
+			changed = append(changed, key)
+		}
+	})
+	return changed
+}
+
+// logInvalidConfiguration logs every problem of the configuration on its own line.
+func logInvalidConfiguration(err error) {
+	var validationErr *validation.Error
+	if !errors.As(err, &validationErr) {
+		log.Errorf("Invalid configuration: %v", err)
+		return
+	}
+	for _, problem := range validationErr.Problems {
+		log.Errorf("Invalid configuration: %s", problem)
+	}
+}
+
+// configCmd returns the command which prints the effective configuration of the service with secrets redacted.
+func configCmd(load func(flags *pflag.FlagSet) (serviceConfig, error)) *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Configuration of the service",
+	}
+	configCmd.AddCommand(&cobra.Command{
+		Use:   "print",
+		Short: "Print the configuration merged from the config file, env and flags, secrets are redacted",
+		// errors are logged by main
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			configuration, err := load(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			if err := printConfiguration(cmd.OutOrStdout(), configuration); err != nil {
+				return err
+			}
+
+			var validationErr *validation.Error
+			if errors.As(configuration.Validate(), &validationErr) {
+				for _, problem := range validationErr.Problems {
+					cmd.PrintErrf("Invalid configuration: %s\n", problem)
+				}
+			}
+			return nil
+		},
+	})
+	return configCmd
+}
+
+// printConfiguration writes the configuration in YAML with the keys of the config file.
+func printConfiguration(w io.Writer, configuration serviceConfig) error {
+	node, err := settingNode(reflect.ValueOf(configuration))
+	if err != nil {
+		return err
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(node); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+func settingNode(v reflect.Value) (*yaml.Node, error) {
+	switch {
+	case v.Type() == durationType:
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: time.Duration(v.Int()).String()}, nil
+	case v.Kind() == reflect.Struct:
+		node := &yaml.Node{Kind: yaml.MappingNode}
+		if err := addFieldNodes(node, v); err != nil {
+			return nil, err
+		}
+		if len(node.Content) == 0 {
+			node.Style = yaml.FlowStyle
+		}
+		return node, nil
+	case v.Kind() == reflect.Slice:
+		node := &yaml.Node{Kind: yaml.SequenceNode}
+		for i := 0; i < v.Len(); i++ {
+			item, err := settingNode(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, item)
+		}
+		if len(node.Content) == 0 || v.Type().Elem().Kind() != reflect.Struct {
+			node.Style = yaml.FlowStyle
+		}
+		return node, nil
+	case v.Kind() == reflect.Map:
+		node := &yaml.Node{Kind: yaml.MappingNode}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		for _, key := range keys {
+			value, err := settingNode(v.MapIndex(key))
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key.String()}, value)
+		}
+		if len(node.Content) == 0 {
+			node.Style = yaml.FlowStyle
+		}
+		return node, nil
+	default:
+		node := new(yaml.Node)
+		return node, node.Encode(v.Interface())
+	}
+}
+
+// addFieldNodes adds keys and values of fields of the struct to the mapping node, fields squashed by mapstructure
+// are added to the node itself.
+func addFieldNodes(node *yaml.Node, v reflect.Value) error {
+	for i := 0; i < v.NumField(); i++ {
+		field, value := v.Type().Field(i), v.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Tag.Get("mapstructure") == ",squash" {
+			if err := addFieldNodes(node, value); err != nil {
+				return err
+			}
+			continue
+		}
+
+		valueNode, err := settingNode(value)
+		if err != nil {
+			return err
+		}
+		if field.Tag.Get("secret") == "true" && !value.IsZero() {
+			valueNode = &yaml.Node{Kind: yaml.ScalarNode, Value: redacted}
+		}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: strings.ToLower(field.Name)}, valueNode)
+	}
+	return nil
+}
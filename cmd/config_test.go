@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigPrint(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "server.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(`
+rabbitmqconfig:
+  url: file:5672
+  user: file
+  password: secret
+  queuename: file_queue
+adminconfig:
+  listenaddr: ":9093"
+`), 0o600))
+	t.Setenv("RABBITMQCONFIG_URL", "env:5672")
+	t.Setenv("RABBITMQCONFIG_USER", "env")
+
+	var stdout, stderr bytes.Buffer
+	cli := NewCLI()
+	cli.SetOut(&stdout)
+	cli.SetErr(&stderr)
+	cli.SetArgs([]string{"server", "config", "print", "--config", file, "--rabbitmqconfig.url", "flag:5672"})
+	require.NoError(t, cli.Execute())
+
+	assert.Contains(t, stdout.String(), `rabbitmqconfig:
+  url: flag:5672
+  user: env
+  password: <redacted>
+  queuename: file_queue
+`, "flags override env which overrides the file")
+	assert.Contains(t, stdout.String(), `adminconfig:
+  listenaddr: :9093
+  token: ""
+`, "empty secret is not redacted")
+	assert.Contains(t, stdout.String(), "  expirycheckinterval: 1s\n", "defaults are used for missing settings")
+	assert.Equal(t, "Invalid configuration: adminconfig.token: is required for the admin API\n", stderr.String())
+}
+
+func TestConfigPrint_Errors(t *testing.T) {
+	dir := t.TempDir()
+	malformed := filepath.Join(dir, "malformed.yaml")
+	require.NoError(t, os.WriteFile(malformed, []byte("rabbitmqconfig: [\n"), 0o600))
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{
+			name:    "should fail when config file does not exist",
+			args:    []string{"client", "config", "print", "--config", filepath.Join(dir, "missing.yaml")},
+			wantErr: "no such file or directory",
+		},
+		{
+			name:    "should fail when config file is malformed",
+			args:    []string{"gateway", "config", "print", "--config", malformed},
+			wantErr: "cannot read configuration",
+		},
+		{
+			name:    "should fail when flag value is malformed",
+			args:    []string{"server", "config", "print", "--storageconfig.expirycheckinterval", "often"},
+			wantErr: "invalid argument",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli := NewCLI()
+			cli.SetOut(&bytes.Buffer{})
+			cli.SetErr(&bytes.Buffer{})
+			cli.SetArgs(tt.args)
+			err := cli.Execute()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/client"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/gateway"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func gatewayCmd() *cobra.Command {
+	var gatewayCmd = &cobra.Command{
+		Use:   "gateway",
+		Short: "REST gateway which publishes commands to the queue",
+		Run: func(cmd *cobra.Command, args []string) {
+			configuration, err := getGatewayConfiguration(cmd.Flags())
+			if err != nil {
+				log.Errorf("Cannot read configuration: %v", err)
+				return
+			}
+			if err := configuration.Validate(); err != nil {
+				logInvalidConfiguration(err)
+				return
+			}
+
+			stopLogging, err := initLogging(configuration.LoggingConfig)
+			if err != nil {
+				log.Errorf("Invalid logging configuration: %v", err)
+				return
+			}
+			defer stopLogging()
+
+			startGatewayApp(configuration)
+		},
+	}
+
+	addConfigFlags(gatewayCmd.PersistentFlags(), "gateway", gateway.DefaultConfigurations())
+	gatewayCmd.AddCommand(configCmd(func(flags *pflag.FlagSet) (serviceConfig, error) {
+		return getGatewayConfiguration(flags)
+	}))
+
+	return gatewayCmd
+}
+
+func getGatewayConfiguration(flags *pflag.FlagSet) (gateway.Configurations, error) {
+	configuration := gateway.DefaultConfigurations()
+	if _, err := loadConfiguration(flags, "gateway", &configuration); err != nil {
+		return gateway.Configurations{}, err
+	}
+	return configuration, nil
+}
+
+func startGatewayApp(configuration gateway.Configurations) {
+	stopTracing, err := initTracing("gateway", configuration.TracingConfig)
+	if err != nil {
+		log.Errorf("Cannot init tracing: %v", err)
+		return
+	}
+	defer stopTracing()
+
+	// every caller has its own client, so its commands are sent with its id
+	clients := make([]*client.Client, 0, len(configuration.Clients))
+	defer func() {
+		for _, c := range clients {
+			if err := c.Cleanup(); err != nil {
+				log.Errorf("Error happened when cleaning up client: %v", err)
+			}
+		}
+	}()
+	publishers := make(map[string]gateway.Publisher, len(configuration.Clients))
+	for _, caller := range configuration.Clients {
+		c := client.New(configuration.ClientConfig(caller))
+		if err := c.InitClient(); err != nil {
+			log.Errorf("Cannot initialize client %s: %v", caller.ID, err)
+			return
+		}
+		clients = append(clients, c)
+		publishers[caller.ID] = c
+	}
+
+	g := gateway.New(configuration, publishers)
+	g.Start()
+
+	terminate := make(chan os.Signal, 1)
+	signal.Notify(terminate, syscall.SIGTERM, syscall.SIGINT)
+	<-terminate
+	log.Info("Terminating application")
+
+	if err := g.Quit(); err != nil {
+		log.Errorf("Error happened when stopping gateway: %v", err)
+	}
+}
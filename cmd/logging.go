@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/logging"
+)
+
+var log = logging.Logger("cmd")
+
+// initLogging configures logs of the service and switches all packages to debug level and back on SIGUSR1.
+// The returned function stops it and closes log files.
+func initLogging(config logging.Config) (func(), error) {
+	closeOutputs, err := logging.Configure(config)
+	if err != nil {
+		return nil, err
+	}
+
+	toggle := make(chan os.Signal, 1)
+	signal.Notify(toggle, syscall.SIGUSR1)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-toggle:
+				if logging.ToggleDebug() {
+					log.Warning("Debug logs are enabled")
+				} else {
+					log.Warning("Debug logs are disabled")
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(toggle)
+		close(done)
+		if err := closeOutputs(); err != nil {
+			log.Errorf("Cannot close log files: %v", err)
+		}
+	}, nil
+}
@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/consensus"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/metrics"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/service"
+)
+
+const appRestartInterval = 5 * time.Second
+
+// startRaftServerApp runs the node of the Raft cluster. Only the leader consumes commands from RabbitMQ,
+// they are acked after they are committed to the log. The app has no scheduler, so delayed commands are rejected.
+func startRaftServerApp(configuration server.Configurations, reloader *serverReloader, appOpts []server.AppOption,
+	adminOpts []server.AdminOption, queryOpts []server.QueryOption) {
+	raftConfig := configuration.RaftConfig
+	peers, err := parsePeers(raftConfig.Peers)
+	if err != nil {
+		log.Errorf("Invalid raft configuration: %v", err)
+		return
+	}
+
+	if configuration.SchedulerConfig.File != "" {
+		log.Warning("Delayed commands are not supported by Raft nodes, schedulerconfig.file is ignored")
+	}
+
+	registryConfig, err := newRegistryConfig(configuration.StorageConfig)
+	if err != nil {
+		log.Errorf("Cannot create storage: %v", err)
+		return
+	}
+
+	node, err := consensus.NewNode(consensus.Config{
+		ID:                  raftConfig.NodeID,
+		BindAddr:            raftConfig.BindAddr,
+		AdvertiseAddr:       raftConfig.AdvertiseAddr,
+		DataDir:             raftConfig.DataDir,
+		Bootstrap:           raftConfig.Bootstrap,
+		Peers:               peers,
+		ApplyTimeout:        raftConfig.ApplyTimeout,
+		ExpiryCheckInterval: configuration.StorageConfig.ExpiryCheckInterval,
+	}, registryConfig)
+	if err != nil {
+		log.Errorf("Cannot start raft node: %v", err)
+		return
+	}
+	defer func() {
+		if err := node.Quit(); err != nil {
+			log.Errorf("Cannot stop raft node: %v", err)
+		}
+	}()
+
+	// apps of all leadership terms report to the same activity
+	activity := server.NewActivity()
+	appOpts = append(appOpts, server.WithActivity(activity))
+	itemService := consensus.NewItemService(node)
+	node.Start(func(ctx context.Context) {
+		consumeWhileLeader(ctx, reloader, itemService, appOpts)
+	})
+
+	if raftConfig.ListenAddr != "" {
+		raftServer := server.NewRaftServer(raftConfig, node)
+		raftServer.Start()
+		defer func() {
+			if err := raftServer.Quit(); err != nil {
+				log.Errorf("Cannot stop raft server: %v", err)
+			}
+		}()
+	}
+
+	if configuration.AdminConfig.ListenAddr != "" {
+		// the store is changed only through the log
+		adminOpts = append(adminOpts, server.AdminReadOnly())
+		adminServer := server.NewAdminServer(configuration.AdminConfig, node.Registry(), activity, adminOpts...)
+		adminServer.Start()
+		defer func() {
+			if err := adminServer.Quit(); err != nil {
+				log.Errorf("Cannot stop admin server: %v", err)
+			}
+		}()
+	}
+
+	if configuration.QueryConfig.ListenAddr != "" {
+		queryServer, err := server.NewQueryServer(configuration.QueryConfig, itemService, node.Registry(), queryOpts...)
+		if err != nil {
+			log.Errorf("Cannot create query server: %v", err)
+			return
+		}
+		if err := queryServer.Start(); err != nil {
+			log.Errorf("Cannot start query server: %v", err)
+			return
+		}
+		defer queryServer.Quit()
+	}
+
+	if configuration.MetricsConfig.ListenAddr != "" {
+		metricsServer := metrics.NewServer(configuration.MetricsConfig.ListenAddr)
+		metricsServer.Start()
+		defer func() {
+			if err := metricsServer.Quit(); err != nil {
+				log.Errorf("Cannot stop metrics server: %v", err)
+			}
+		}()
+	}
+
+	terminate := make(chan os.Signal, 1)
+	signal.Notify(terminate, syscall.SIGTERM, syscall.SIGINT)
+	<-terminate
+
+	log.Info("Terminating application")
+}
+
+// consumeWhileLeader runs the server app until ctx is done, the app is restarted when it stops on its own,
+// e.g. when the connection to RabbitMQ is lost.
+func consumeWhileLeader(ctx context.Context, reloader *serverReloader, itemService service.ItemService, appOpts []server.AppOption) {
+	for {
+		runApp(ctx, reloader, itemService, appOpts)
+
+		select {
+		case <-time.After(appRestartInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// runApp runs the app with the current configuration, changes of the configuration are applied to it until it stops.
+func runApp(ctx context.Context, reloader *serverReloader, itemService service.ItemService, appOpts []server.AppOption) {
+	app := reloader.newApp(itemService, appOpts...)
+	defer reloader.dropApp(app)
+
+	stopped := make(chan struct{})
+	cleanedUp := make(chan struct{})
+	go func() {
+		defer close(cleanedUp)
+
+		select {
+		case <-ctx.Done():
+		case <-stopped:
+		}
+		// not acked messages are redelivered to the next leader when the connection is closed
+		if err := app.Cleanup(); err != nil {
+			log.Errorf("Cannot clean up server app: %v", err)
+		}
+	}()
+	defer func() {
+		close(stopped)
+		<-cleanedUp
+	}()
+
+	if err := app.Init(); err != nil {
+		log.Errorf("Cannot init server app: %v", err)
+		return
+	}
+	if err := app.Start(); err != nil {
+		log.Errorf("Cannot start server app: %v", err)
+	}
+}
+
+// parsePeers parses peers in the format "<id>=<addr>".
+func parsePeers(values []string) ([]consensus.Peer, error) {
+	peers := make([]consensus.Peer, 0, len(values))
+	for _, value := range values {
+		id, addr, ok := strings.Cut(value, "=")
+		if !ok || id == "" || addr == "" {
+			return nil, fmt.Errorf("invalid peer %q, expected <id>=<addr>", value)
+		}
+		peers = append(peers, consensus.Peer{ID: id, Addr: addr})
+	}
+	return peers, nil
+}
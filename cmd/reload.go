@@ -0,0 +1,268 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/logging"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/limits"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/policy"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/service"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/pflag"
+)
+
+// reloadDelay is how long changes of the config file settle before it is reloaded, editors write files in several steps.
+const reloadDelay = 100 * time.Millisecond
+
+// reloadableSettings are settings of the server which are applied without restart, a key ending with "." covers
+// all settings under it.
+var reloadableSettings = []string{
+	"workerpoolconfig.workers",
+	"rabbitmqconfig.prefetch",
+	"loggingconfig.level",
+	"loggingconfig.packages",
+	"policyconfig.",
+	"limitsconfig.",
+}
+
+// serverReloader applies changes of the configuration to the running server: the number of workers, prefetch,
+// log levels, the policy and limits. Other changes require restart, they are logged and not applied.
+type serverReloader struct {
+	flags   *pflag.FlagSet
+	file    string
+	limiter *limits.Limiter
+	policy  *policy.Engine
+
+	mx sync.Mutex
+	// configuration is the configuration the server runs with
+	configuration server.Configurations
+	// app consumes messages, apps of Raft nodes are replaced when the leadership changes
+	app *server.App
+}
+
+func newServerReloader(flags *pflag.FlagSet, file string, configuration server.Configurations, limiter *limits.Limiter,
+	policyEngine *policy.Engine) *serverReloader {
+	return &serverReloader{
+		flags:         flags,
+		file:          file,
+		configuration: configuration,
+		limiter:       limiter,
+		policy:        policyEngine,
+	}
+}
+
+// newApp creates the app with the current configuration, changes are applied to the last created app.
+func (r *serverReloader) newApp(itemService service.ItemService, opts ...server.AppOption) *server.App {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	r.app = server.NewApp(r.configuration, itemService, opts...)
+	return r.app
+}
+
+// dropApp stops applying changes to the app.
+func (r *serverReloader) dropApp(app *server.App) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	if r.app == app {
+		r.app = nil
+	}
+}
+
+// reload reads the configuration again and applies its changes, an invalid configuration is not applied.
+func (r *serverReloader) reload() {
+	configuration, file, err := getServerConfiguration(r.flags)
+	// defaults are not applied when the config file is removed
+	if err == nil && r.file != "" && file != r.file {
+		err = fmt.Errorf("config file %s is not found", r.file)
+	}
+	if err != nil {
+		log.Errorf("Cannot reload configuration, the current one is used: %v", err)
+		return
+	}
+	if err := configuration.Validate(); err != nil {
+		logInvalidConfiguration(err)
+		log.Error("Configuration is not reloaded, the current one is used")
+		return
+	}
+
+	applied, restart := r.apply(configuration)
+	switch {
+	case len(applied) > 0:
+		log.Infof("Configuration is reloaded, changes of %s are applied", strings.Join(applied, ", "))
+	case len(restart) == 0:
+		log.Info("Configuration is reloaded, it is not changed")
+	}
+	if len(restart) > 0 {
+		log.Warningf("Changes of %s require restart, they are not applied", strings.Join(restart, ", "))
+	}
+}
+
+// apply applies changes of reloadable settings and returns them, and changed settings which require restart.
+// Settings which cannot be applied are logged and are not returned.
+func (r *serverReloader) apply(configuration server.Configurations) (applied, restart []string) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	var changed []string
+	for _, key := range changedSettings(r.configuration, configuration) {
+		if reloadable(key) {
+			changed = append(changed, key)
+		} else {
+			restart = append(restart, key)
+		}
+	}
+	// failed is the prefix of settings which are not applied
+	failed := make(map[string]bool)
+	changes := func(prefix string) bool {
+		for _, key := range changed {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if changes("workerpoolconfig.workers") {
+		r.configuration.WorkerPoolConfig.Workers = configuration.WorkerPoolConfig.Workers
+		if r.app != nil {
+			r.app.SetWorkers(configuration.WorkerPoolConfig.Workers)
+		}
+	}
+
+	if changes("rabbitmqconfig.prefetch") {
+		// the next app uses the new prefetch even when the current one cannot
+		r.configuration.RabbitMQConfig.Prefetch = configuration.RabbitMQConfig.Prefetch
+		if r.app != nil {
+			if err := r.app.SetPrefetch(configuration.RabbitMQConfig.Prefetch); err != nil {
+				log.Errorf("Cannot change prefetch: %v", err)
+				failed["rabbitmqconfig.prefetch"] = true
+			}
+		}
+	}
+
+	if changes("loggingconfig.") {
+		if err := logging.SetLevels(configuration.LoggingConfig); err != nil {
+			log.Errorf("Cannot change log levels: %v", err)
+			failed["loggingconfig."] = true
+		} else {
+			r.configuration.LoggingConfig.Level = configuration.LoggingConfig.Level
+			r.configuration.LoggingConfig.Packages = configuration.LoggingConfig.Packages
+		}
+	}
+
+	if changes("policyconfig.") {
+		config := configuration.PolicyConfig
+		if err := r.policy.SetFile(config.File, config.ReloadInterval); err != nil {
+			log.Errorf("Cannot load policy, the previous one is used: %v", err)
+			failed["policyconfig."] = true
+		} else {
+			r.configuration.PolicyConfig = config
+		}
+	}
+
+	if changes("limitsconfig.") {
+		config := configuration.LimitsConfig
+		if err := r.limiter.SetLimits(config.Default, clientLimits(config)); err != nil {
+			log.Errorf("Cannot change limits, the previous ones are used: %v", err)
+			failed["limitsconfig."] = true
+		} else {
+			r.configuration.LimitsConfig = config
+		}
+	}
+
+	for _, key := range changed {
+		if !failedSetting(key, failed) {
+			applied = append(applied, key)
+		}
+	}
+	return applied, restart
+}
+
+func reloadable(key string) bool {
+	for _, setting := range reloadableSettings {
+		if key == setting || strings.HasSuffix(setting, ".") && strings.HasPrefix(key, setting) {
+			return true
+		}
+	}
+	return false
+}
+
+func failedSetting(key string, failed map[string]bool) bool {
+	for prefix := range failed {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// watchConfiguration calls reload on SIGHUP and when the config file is changed, only SIGHUP is handled when
+// the file is empty. The returned function stops watching.
+func watchConfiguration(file string, reload func()) (func(), error) {
+	var watcher *fsnotify.Watcher
+	var events <-chan fsnotify.Event
+	var watchErrors <-chan error
+	if file != "" {
+		var err error
+		if watcher, err = fsnotify.NewWatcher(); err != nil {
+			return nil, err
+		}
+		// the directory is watched, since editors replace the file instead of writing it
+		if err := watcher.Add(filepath.Dir(file)); err != nil {
+			_ = watcher.Close()
+			return nil, err
+		}
+		events, watchErrors = watcher.Events, watcher.Errors
+	}
+
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+
+		var settled <-chan time.Time
+		for {
+			select {
+			case <-hangup:
+				log.Info("Reloading configuration on SIGHUP")
+				reload()
+			case event := <-events:
+				// removed file is not reloaded, it is usually created again
+				if filepath.Clean(event.Name) == filepath.Clean(file) && event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
+					settled = time.After(reloadDelay)
+				}
+			case <-settled:
+				settled = nil
+				log.Infof("Reloading configuration, %s is changed", file)
+				reload()
+			case err := <-watchErrors:
+				log.Errorf("Cannot watch config file: %v", err)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(hangup)
+		close(done)
+		<-stopped
+		if watcher != nil {
+			if err := watcher.Close(); err != nil {
+				log.Errorf("Cannot stop watching config file: %v", err)
+			}
+		}
+	}, nil
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/logging"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/limits"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/policy"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangedSettings(t *testing.T) {
+	current := server.DefaultConfigurations()
+	next := server.DefaultConfigurations()
+	next.RaftConfig.Peers = []string{}
+	assert.Empty(t, changedSettings(current, next), "empty lists are equal")
+
+	next.StorageConfig.MaxItems = 10
+	next.LimitsConfig.Clients = []server.ClientLimits{{ID: "producer"}}
+	next.WorkerPoolConfig.Workers = 10
+	assert.Equal(t, []string{"storageconfig.maxitems", "limitsconfig.clients", "workerpoolconfig.workers"}, changedSettings(current, next))
+}
+
+func TestServerReloader(t *testing.T) {
+	repository := logging.Logger("server/repository")
+	defer logging.Configure(logging.Config{})
+
+	dir := t.TempDir()
+	policyFile := filepath.Join(dir, "policy.yaml")
+	require.NoError(t, os.WriteFile(policyFile, []byte("default: [{commands: [GetItem]}]"), 0o600))
+	configFile := filepath.Join(dir, "server.yaml")
+	writeConfig := func(config string) {
+		require.NoError(t, os.WriteFile(configFile, []byte(config), 0o600))
+	}
+	writeConfig("storageconfig:\n  datadir: ./data\n")
+
+	flags := serverCmd().PersistentFlags()
+	require.NoError(t, flags.Set(configFlag, configFile))
+	configuration, file, err := getServerConfiguration(flags)
+	require.NoError(t, err)
+	assert.Equal(t, configFile, file)
+
+	limiter, err := newLimiter(configuration.LimitsConfig)
+	require.NoError(t, err)
+	policyEngine, err := policy.NewEngine(configuration.PolicyConfig.File, configuration.PolicyConfig.ReloadInterval)
+	require.NoError(t, err)
+	r := newServerReloader(flags, file, configuration, limiter, policyEngine)
+	r.newApp(nil)
+
+	addItem := &models.Command{Type: models.CommandType_AddItem, ItemID: 1, ItemPayload: "A"}
+	require.NoError(t, limiter.Allow("producer", addItem))
+
+	writeConfig(`
+storageconfig:
+  datadir: ./other
+workerpoolconfig:
+  workers: 10
+rabbitmqconfig:
+  prefetch: 20
+loggingconfig:
+  format: json
+  packages:
+    - package: server/repository
+      level: debug
+policyconfig:
+  file: ` + policyFile + `
+limitsconfig:
+  clients:
+    - id: producer
+      maxitems: 1
+`)
+	configuration, _, err = getServerConfiguration(flags)
+	require.NoError(t, err)
+	applied, restart := r.apply(configuration)
+	assert.Equal(t, []string{"rabbitmqconfig.prefetch", "policyconfig.file", "limitsconfig.clients", "workerpoolconfig.workers",
+		"loggingconfig.packages"}, applied)
+	assert.Equal(t, []string{"storageconfig.datadir", "loggingconfig.format"}, restart)
+
+	assert.Equal(t, logrus.DebugLevel, repository.GetLevel())
+	assert.ErrorIs(t, policyEngine.Authorize("producer", addItem), policy.ErrDenied)
+	assert.ErrorIs(t, limiter.Allow("producer", addItem), limits.ErrQuotaExceeded, "usage is kept")
+	assert.Equal(t, 10, r.configuration.WorkerPoolConfig.Workers)
+	assert.Equal(t, "./data", r.configuration.StorageConfig.DataDir, "settings which require restart are not applied")
+
+	// invalid and missing files are not applied
+	writeConfig("workerpoolconfig:\n  workers: -1\n")
+	r.reload()
+	assert.Equal(t, 10, r.configuration.WorkerPoolConfig.Workers)
+	require.NoError(t, os.Remove(configFile))
+	r.reload()
+	assert.Equal(t, 10, r.configuration.WorkerPoolConfig.Workers)
+
+	writeConfig("workerpoolconfig:\n  workers: 3\n")
+	r.reload()
+	assert.Equal(t, 3, r.configuration.WorkerPoolConfig.Workers)
+}
+
+func TestWatchConfiguration(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "server.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("workerpoolconfig:\n  workers: 5\n"), 0o600))
+
+	reloaded := make(chan struct{}, 10)
+	stop, err := watchConfiguration(file, func() { reloaded <- struct{}{} })
+	require.NoError(t, err)
+	defer stop()
+
+	require.NoError(t, os.WriteFile(filepath.Join(filepath.Dir(file), "other.yaml"), nil, 0o600))
+	require.NoError(t, os.WriteFile(file, []byte("workerpoolconfig:\n  workers: 10\n"), 0o600))
+	select {
+	case <-reloaded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("configuration is not reloaded")
+	}
+
+	select {
+	case <-reloaded:
+		t.Fatal("configuration is reloaded again")
+	case <-time.After(2 * reloadDelay):
+	}
+}
@@ -12,6 +12,7 @@ func NewCLI() *cobra.Command {
 
 	cli.AddCommand(serverCmd())
 	cli.AddCommand(clientCmd())
+	cli.AddCommand(gatewayCmd())
 
 	return cli
 }
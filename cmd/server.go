@@ -1,89 +1,229 @@
 package cmd
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"os/signal"
-	"strings"
 	"syscall"
 
 	"github.com/dliakhov/bloxroutelabs/client-server-app/server"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/limits"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/metrics"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/policy"
 	"github.com/dliakhov/bloxroutelabs/client-server-app/server/repository"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/scheduler"
 	"github.com/dliakhov/bloxroutelabs/client-server-app/server/service"
-	"github.com/iamolegga/enviper"
-	log "github.com/sirupsen/logrus"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/signing"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
+	"github.com/spf13/pflag"
 )
 
 func serverCmd() *cobra.Command {
-	return &cobra.Command{
+	serverCmd := &cobra.Command{
 		Use:   "server",
 		Short: "Server application",
 		Run: func(cmd *cobra.Command, args []string) {
-			log.SetOutput(os.Stdout)
-
-			configuration, err := getServerConfiguration()
+			configuration, file, err := getServerConfiguration(cmd.Flags())
 			if err != nil {
 				log.Errorf("Cannot read configuration: %v", err)
 				return
 			}
+			if err := configuration.Validate(); err != nil {
+				logInvalidConfiguration(err)
+				return
+			}
+
+			stopLogging, err := initLogging(configuration.LoggingConfig)
+			if err != nil {
+				log.Errorf("Invalid logging configuration: %v", err)
+				return
+			}
+			defer stopLogging()
 
-			startServerApp(configuration, err)
+			startServerApp(cmd.Flags(), file, configuration)
 		},
 	}
+	addConfigFlags(serverCmd.PersistentFlags(), "server", server.DefaultConfigurations())
+	serverCmd.AddCommand(configCmd(func(flags *pflag.FlagSet) (serviceConfig, error) {
+		configuration, _, err := getServerConfiguration(flags)
+		return configuration, err
+	}))
 
+	return serverCmd
 }
 
-func getServerConfiguration() (server.Configurations, error) {
-	e := enviper.New(viper.New())
+// getServerConfiguration returns the configuration and the path of the config file, which is empty when the file
+// is not found.
+func getServerConfiguration(flags *pflag.FlagSet) (server.Configurations, string, error) {
+	configuration := server.DefaultConfigurations()
+	file, err := loadConfiguration(flags, "server", &configuration)
+	if err != nil {
+		return server.Configurations{}, "", err
+	}
+	return configuration, file, nil
+}
 
-	var pwd string
-	var err error
-	if pwd, err = os.Getwd(); err != nil {
-		log.Fatal("unable to get current working directory: ", err)
+func startServerApp(flags *pflag.FlagSet, file string, configuration server.Configurations) {
+	role := configuration.ReplicationConfig.Role
+	if role == "" {
+		role = server.RoleStandalone
 	}
 
-	e.AddConfigPath(pwd)
-	e.SetConfigName(".config.server")
+	stopTracing, err := initTracing("server", configuration.TracingConfig)
+	if err != nil {
+		log.Errorf("Cannot init tracing: %v", err)
+		return
+	}
+	defer stopTracing()
 
-	// enable viper to handle env values for nested structs
-	e.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	verifier, err := newVerifier(configuration.AuthConfig)
+	if err != nil {
+		log.Errorf("Invalid auth configuration: %v", err)
+		return
+	}
+	var appOpts []server.AppOption
+	if verifier != nil {
+		appOpts = append(appOpts, server.WithVerifier(verifier))
+	}
 
-	// defaults to ENV variable values
-	e.AutomaticEnv()
+	limiter, err := newLimiter(configuration.LimitsConfig)
+	if err != nil {
+		log.Errorf("Invalid limits configuration: %v", err)
+		return
+	}
+	appOpts = append(appOpts, server.WithLimiter(limiter))
+	adminOpts := []server.AdminOption{server.AdminLimiter(limiter)}
 
-	var configuration server.Configurations
-	if err := e.ReadInConfig(); err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
-			log.Fatal("Error reading config file: ", err)
-		}
+	// the engine allows all commands until the policy file is set
+	policyEngine, err := policy.NewEngine(configuration.PolicyConfig.File, configuration.PolicyConfig.ReloadInterval)
+	if err != nil {
+		log.Errorf("Cannot load policy: %v", err)
+		return
 	}
+	policyEngine.Start()
+	defer policyEngine.Quit()
+	appOpts = append(appOpts, server.WithPolicy(policyEngine))
+	queryOpts := []server.QueryOption{server.QueryPolicy(policyEngine)}
 
-	err = e.Unmarshal(&configuration)
+	reloader := newServerReloader(flags, file, configuration, limiter, policyEngine)
+	stopWatching, err := watchConfiguration(file, reloader.reload)
 	if err != nil {
-		log.Errorf("Unable to decode into struct, %v", err)
-		return server.Configurations{}, err
+		log.Errorf("Cannot watch config file: %v", err)
+		return
 	}
-	return configuration, nil
-}
+	defer stopWatching()
+
+	if configuration.RaftConfig.NodeID != "" {
+		startRaftServerApp(configuration, reloader, appOpts, adminOpts, queryOpts)
+		return
+	}
+
+	registry, err := newRegistry(role, configuration.StorageConfig)
+	if err != nil {
+		log.Errorf("Cannot create storage: %v", err)
+		return
+	}
+
+	itemService := service.New(registry)
+	if role == server.RoleReplica {
+		// replica changes the storage only by changes of the primary
+		itemService = service.NewReadOnly(registry)
 
-func startServerApp(configuration server.Configurations, err error) {
-	repo := repository.New()
-	itemService := service.New(repo)
+		replica := server.NewReplica(configuration, registry)
+		replica.Start()
+		defer replica.Quit()
+	} else {
+		defer func() {
+			if err := registry.Save(); err != nil {
+				log.Errorf("Cannot save storage: %v", err)
+			}
+		}()
+
+		reaper := repository.NewReaper(registry, configuration.StorageConfig.ExpiryCheckInterval)
+		reaper.Start()
+		defer reaper.Quit()
 
-	terminate := make(chan os.Signal)
+		if configuration.ChangeFeedConfig.Exchange != "" {
+			changeFeed := server.NewChangeFeed(configuration, registry)
+			changeFeed.Start()
+			defer changeFeed.Quit()
+		}
+
+		commandScheduler, err := scheduler.New(itemService, configuration.SchedulerConfig.File)
+		if err != nil {
+			log.Errorf("Cannot create scheduler: %v", err)
+			return
+		}
+		commandScheduler.Start()
+		defer commandScheduler.Quit()
+
+		appOpts = append(appOpts, server.WithScheduler(commandScheduler))
+		adminOpts = append(adminOpts, server.AdminScheduler(commandScheduler))
+	}
+
+	terminate := make(chan os.Signal, 1)
 	signal.Notify(terminate, syscall.SIGTERM, syscall.SIGINT)
 
-	app := server.NewApp(configuration, itemService)
+	app := reloader.newApp(itemService, appOpts...)
+
+	if configuration.ReplicationConfig.ListenAddr != "" {
+		snapshotServer := server.NewSnapshotServer(configuration.ReplicationConfig, registry)
+		snapshotServer.Start()
+		defer func() {
+			if err := snapshotServer.Quit(); err != nil {
+				log.Errorf("Cannot stop snapshot server: %v", err)
+			}
+		}()
+	}
+
+	if configuration.AdminConfig.ListenAddr != "" {
+		if role != server.RoleStandalone {
+			// changes of the restored store are not published to replicas
+			adminOpts = append(adminOpts, server.AdminReadOnly())
+		} else if configuration.ChangeFeedConfig.Exchange != "" || configuration.QueryConfig.ListenAddr != "" {
+			adminOpts = append(adminOpts, server.AdminChangeConsumers())
+		}
+		adminServer := server.NewAdminServer(configuration.AdminConfig, registry, app.Activity(), adminOpts...)
+		adminServer.Start()
+		defer func() {
+			if err := adminServer.Quit(); err != nil {
+				log.Errorf("Cannot stop admin server: %v", err)
+			}
+		}()
+	}
+
+	if configuration.QueryConfig.ListenAddr != "" {
+		queryServer, err := server.NewQueryServer(configuration.QueryConfig, itemService, registry, queryOpts...)
+		if err != nil {
+			log.Errorf("Cannot create query server: %v", err)
+			return
+		}
+		if err := queryServer.Start(); err != nil {
+			log.Errorf("Cannot start query server: %v", err)
+			return
+		}
+		defer queryServer.Quit()
+	}
+
+	if configuration.MetricsConfig.ListenAddr != "" {
+		metricsServer := metrics.NewServer(configuration.MetricsConfig.ListenAddr)
+		metricsServer.Start()
+		defer func() {
+			if err := metricsServer.Quit(); err != nil {
+				log.Errorf("Cannot stop metrics server: %v", err)
+			}
+		}()
+	}
 
 	go func() {
-		err = app.Init()
+		err := app.Init()
 		if err != nil {
 			log.Errorf("Cannot init server app: %v", err)
 			return
 		}
 
-		err := app.Start()
+		err = app.Start()
 		if err != nil {
 			log.Errorf("Cannot start server app: %v", err)
 			return
@@ -99,3 +239,91 @@ func startServerApp(configuration server.Configurations, err error) {
 		return
 	}
 }
+
+// newVerifier returns the verifier of client signatures, it is nil when authentication is not configured.
+func newVerifier(config server.AuthConfig) (*signing.Verifier, error) {
+	if !config.Required && len(config.Clients) == 0 {
+		return nil, nil
+	}
+	if len(config.Clients) == 0 {
+		return nil, errors.New("required authentication needs client keys")
+	}
+
+	keys := make(map[string]signing.Key, len(config.Clients))
+	for _, client := range config.Clients {
+		if client.ID == "" {
+			return nil, errors.New("client id is required")
+		}
+		if _, ok := keys[client.ID]; ok {
+			return nil, fmt.Errorf("duplicated client %s", client.ID)
+		}
+		keys[client.ID] = client.Key
+	}
+	return signing.NewVerifier(keys, config.Window)
+}
+
+// newLimiter returns the limiter of clients, it counts their usage even when no limits are set.
+func newLimiter(config server.LimitsConfig) (*limits.Limiter, error) {
+	return limits.New(config.Default, clientLimits(config))
+}
+
+// clientLimits returns limits by client id, client ids are checked by validation of the configuration.
+func clientLimits(config server.LimitsConfig) map[string]limits.Limits {
+	clients := make(map[string]limits.Limits, len(config.Clients))
+	for _, client := range config.Clients {
+		clients[client.ID] = client.Limits
+	}
+	return clients
+}
+
+func newRegistry(role string, config server.StorageConfig) (repository.Registry, error) {
+	if role == server.RoleReplica {
+		// collections of replica mirror the primary, so their limits and persistence are not applied
+		return repository.NewRegistry(repository.RegistryConfig{ChangeLogRetention: config.ChangeLogRetention})
+	}
+
+	registryConfig, err := newRegistryConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return repository.NewRegistry(registryConfig)
+}
+
+func newRegistryConfig(config server.StorageConfig) (repository.RegistryConfig, error) {
+	defaults, err := collectionConfig(config.CollectionConfig)
+	if err != nil {
+		return repository.RegistryConfig{}, err
+	}
+
+	configs := make(map[string]repository.CollectionConfig, len(config.Collections))
+	for name, collectionCfg := range config.Collections {
+		configs[name], err = collectionConfig(collectionCfg)
+		if err != nil {
+			return repository.RegistryConfig{}, fmt.Errorf("collection %s: %w", name, err)
+		}
+	}
+
+	return repository.RegistryConfig{
+		DataDir:            config.DataDir,
+		Defaults:           defaults,
+		Collections:        configs,
+		ChangeLogRetention: config.ChangeLogRetention,
+	}, nil
+}
+
+func collectionConfig(config server.CollectionConfig) (repository.CollectionConfig, error) {
+	evictionPolicy, err := repository.ParseEvictionPolicy(config.EvictionPolicy)
+	if err != nil {
+		return repository.CollectionConfig{}, err
+	}
+
+	return repository.CollectionConfig{
+		Limits: repository.Limits{
+			MaxItems: config.MaxItems,
+			MaxBytes: config.MaxBytes,
+			Policy:   evictionPolicy,
+		},
+		DefaultTTL: config.DefaultTTL,
+		Persistent: config.Persistent,
+	}, nil
+}
@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/tracing"
+)
+
+// initTracing sets up tracing of the service and returns the function which flushes spans on shutdown.
+func initTracing(serviceName string, config tracing.Config) (func(), error) {
+	shutdown, err := tracing.Init(serviceName, config)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := shutdown(ctx); err != nil {
+			log.Errorf("Cannot flush spans: %v", err)
+		}
+	}, nil
+}
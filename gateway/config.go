@@ -0,0 +1,95 @@
+package gateway
+
+import (
+	"fmt"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/client"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/logging"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/signing"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/tracing"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/validation"
+)
+
+type Configurations struct {
+	// ListenAddr is the address of the REST API, e.g. ":8080".
+	ListenAddr string
+	// WaitForReply makes changes wait until the server replies that they are processed, otherwise they are
+	// accepted once they are published. It can be overridden by "wait" query parameter of the request.
+	// Reads always wait for the reply.
+	WaitForReply bool
+	// Clients are the callers of the API, every request is authenticated by the token of one of them.
+	Clients        []Client
+	RabbitMQConfig client.RabbitMQConfig
+	ShardingConfig client.ShardingConfig
+	// Envelope is passed to the clients, see client.Configurations. Commands of every caller are sent by its
+	// own client with its id and signing key.
+	Envelope bool
+	// TracingConfig exports spans of requests and sent messages, see tracing.Config.
+	TracingConfig tracing.Config
+	// LoggingConfig sets the level, format and outputs of logs, see logging.Config.
+	LoggingConfig logging.Config
+}
+
+// DefaultConfigurations returns the configuration which is used for settings missing in the config file, env and flags.
+func DefaultConfigurations() Configurations {
+	clientDefaults := client.DefaultConfigurations()
+	return Configurations{
+		ListenAddr:     ":8080",
+		RabbitMQConfig: clientDefaults.RabbitMQConfig,
+		ShardingConfig: clientDefaults.ShardingConfig,
+		TracingConfig:  clientDefaults.TracingConfig,
+		LoggingConfig:  clientDefaults.LoggingConfig,
+	}
+}
+
+// Validate checks the configuration and returns all its problems in validation.Error.
+func (c Configurations) Validate() error {
+	var errs validation.Errors
+	if c.ListenAddr == "" {
+		errs.Addf("listenaddr", "is required")
+	}
+	if len(c.Clients) == 0 {
+		errs.Addf("clients", "are required")
+	}
+	ids := make(map[string]bool, len(c.Clients))
+	for i, caller := range c.Clients {
+		field := fmt.Sprintf("clients[%d]", i)
+		switch {
+		case caller.ID == "":
+			errs.Addf(field+".id", "is required")
+		case ids[caller.ID]:
+			errs.Addf(field+".id", "duplicated client %s", caller.ID)
+		}
+		ids[caller.ID] = true
+		if caller.Token == "" {
+			errs.Addf(field+".token", "is required")
+		}
+		if caller.SigningConfig.Algorithm != "" {
+			errs.Add(field+".signingconfig", caller.SigningConfig.Validate())
+		}
+	}
+	errs.Add("rabbitmqconfig", c.RabbitMQConfig.Validate())
+	errs.Add("shardingconfig", c.ShardingConfig.Validate())
+	errs.Add("tracingconfig", c.TracingConfig.Validate())
+	errs.Add("loggingconfig", c.LoggingConfig.Validate())
+	return errs.Err()
+}
+
+// Client is sent in "Authorization: Bearer <token>" header of requests.
+type Client struct {
+	ID    string
+	Token string `secret:"true"`
+	// SigningConfig signs commands of the client when its algorithm is set, see signing.Key.
+	SigningConfig signing.Key
+}
+
+// ClientConfig returns configuration of the client which publishes commands of the caller.
+func (c Configurations) ClientConfig(caller Client) client.Configurations {
+	return client.Configurations{
+		RabbitMQConfig: c.RabbitMQConfig,
+		ShardingConfig: c.ShardingConfig,
+		Envelope:       c.Envelope,
+		ClientID:       caller.ID,
+		SigningConfig:  caller.SigningConfig,
+	}
+}
@@ -0,0 +1,365 @@
+package gateway
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/client"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/logging"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/signing"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/tracing"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var log = logging.Logger("gateway")
+
+const (
+	traceIDKey   = "X-Trace-ID"
+	clientIDKey  = signing.ClientIDHeader
+	itemsPath    = "/items"
+	maxBodyBytes = 1 << 20
+)
+
+var tracer = otel.Tracer("github.com/dliakhov/bloxroutelabs/client-server-app/gateway")
+
+// Publisher sends commands of a caller to the server, it is implemented by client.Client.
+//
+//go:generate mockgen -package=gateway -source=gateway.go -destination=gateway_mock.go
+type Publisher interface {
+	SendCommand(ctx context.Context, command *models.Command) error
+	// ExecuteCommand sends the command and waits until it is processed by the server.
+	ExecuteCommand(ctx context.Context, command *models.Command) error
+	QueryItems(ctx context.Context, command *models.Command) ([]models.Item, error)
+}
+
+// Gateway translates REST requests to commands for producers which cannot use AMQP:
+//
+//	POST   /items                 AddItem, body {"id": 1, "payload": "A", "ttl_seconds": 10}
+//	DELETE /items/{id}            RemoveItem, optional "expected_version" parameter
+//	GET    /items/{id}            GetItem
+//	GET    /items                 GetAllItems
+//
+// Changes can be delayed by "not_before" field of the body or parameter in RFC 3339 format.
+// Requests are authenticated by "Authorization: Bearer <token>" header with the token of one of Configurations.Clients,
+// and their commands are sent by the publisher of the client.
+// All requests have optional "collection" parameter. Every request gets a new trace id, or continues the trace
+// of W3C traceparent header, which is returned in X-Trace-ID header. Changes are answered with 202 Accepted when they are published and 200 OK when they
+// are processed by the server, see Configurations.WaitForReply.
+type Gateway struct {
+	httpServer   *http.Server
+	publishers   map[string]Publisher
+	clients      []Client
+	waitForReply bool
+}
+
+// New returns the gateway which sends commands by publishers of the clients by their ids.
+func New(config Configurations, publishers map[string]Publisher) *Gateway {
+	g := &Gateway{
+		publishers:   publishers,
+		clients:      config.Clients,
+		waitForReply: config.WaitForReply,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(itemsPath, g.traced(itemsPath, g.handleItems))
+	mux.HandleFunc(itemsPath+"/", g.traced(itemsPath+"/{id}", g.handleItem))
+
+	g.httpServer = &http.Server{
+		Addr:              config.ListenAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	return g
+}
+
+func (g *Gateway) Start() {
+	go func() {
+		log.Infof("Gateway is served on %s%s", g.httpServer.Addr, itemsPath)
+		if err := g.httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("Gateway stopped: %v", err)
+		}
+	}()
+}
+
+func (g *Gateway) Quit() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return g.httpServer.Shutdown(ctx)
+}
+
+type addItemRequest struct {
+	ID         int64  `json:"id"`
+	Payload    string `json:"payload"`
+	TTLSeconds int64  `json:"ttl_seconds"`
+	// NotBefore delays the command when set.
+	NotBefore *time.Time `json:"not_before"`
+}
+
+type itemResponse struct {
+	ID        int64      `json:"id"`
+	Payload   string     `json:"payload"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Version   uint64     `json:"version"`
+}
+
+func newItemResponse(item models.Item) itemResponse {
+	response := itemResponse{
+		ID:      item.ID,
+		Payload: item.Payload,
+		Version: item.Version,
+	}
+	if !item.ExpiresAt.IsZero() {
+		response.ExpiresAt = &item.ExpiresAt
+	}
+	return response
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// handleItems serves /items.
+func (g *Gateway) handleItems(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	collection := r.URL.Query().Get("collection")
+
+	switch r.Method {
+	case http.MethodPost:
+		var request addItemRequest
+		decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&request); err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid item: %v", err)})
+			return
+		}
+		if request.TTLSeconds < 0 {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: "ttl_seconds cannot be negative"})
+			return
+		}
+
+		command := &models.Command{
+			Type:           models.CommandType_AddItem,
+			ItemID:         request.ID,
+			ItemPayload:    request.Payload,
+			ItemTTLSeconds: request.TTLSeconds,
+			Collection:     collection,
+		}
+		if request.NotBefore != nil {
+			command.NotBefore = request.NotBefore.UnixNano()
+		}
+		g.send(ctx, w, r, command)
+	case http.MethodGet:
+		items, err := g.publisher(ctx).QueryItems(ctx, &models.Command{Type: models.CommandType_GetAllItems, Collection: collection})
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		response := make([]itemResponse, 0, len(items))
+		for _, item := range items {
+			response = append(response, newItemResponse(item))
+		}
+		writeJSON(w, http.StatusOK, response)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// handleItem serves /items/{id}.
+func (g *Gateway) handleItem(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := r.URL.Query()
+
+	itemID, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, itemsPath+"/"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid item id"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodDelete:
+		command := &models.Command{
+			Type:       models.CommandType_RemoveItem,
+			ItemID:     itemID,
+			Collection: query.Get("collection"),
+		}
+		if value := query.Get("expected_version"); value != "" {
+			if command.ExpectedVersion, err = strconv.ParseUint(value, 10, 64); err != nil {
+				writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid expected_version"})
+				return
+			}
+		}
+		if value := query.Get("not_before"); value != "" {
+			notBefore, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid not_before"})
+				return
+			}
+			command.NotBefore = notBefore.UnixNano()
+		}
+
+		g.send(ctx, w, r, command)
+	case http.MethodGet:
+		items, err := g.publisher(ctx).QueryItems(ctx, &models.Command{
+			Type:       models.CommandType_GetItem,
+			ItemID:     itemID,
+			Collection: query.Get("collection"),
+		})
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if len(items) == 0 {
+			writeJSON(w, http.StatusNotFound, errorResponse{Error: "item not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, newItemResponse(items[0]))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// traced starts the span of the request which continues the trace of traceparent header when it is set.
+// The trace id is passed to the handler in the request context and is also set to the response header.
+// Requests without a token of the clients are answered with 401 Unauthorized, the client id is passed to
+// the handler in the request context.
+func (g *Gateway) traced(route string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, r.Method+" "+route, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+			semconv.HTTPMethod(r.Method),
+			semconv.HTTPRoute(route),
+			semconv.HTTPTarget(r.URL.RequestURI()),
+		))
+		defer span.End()
+
+		traceID := tracing.TraceID(ctx)
+		if traceID == "" {
+			traceID = uuid.NewString()
+		}
+		w.Header().Set(traceIDKey, traceID)
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		clientID, ok := g.authenticate(r)
+		if ok {
+			span.SetAttributes(attribute.String("client.id", clientID))
+			ctx = context.WithValue(context.WithValue(ctx, traceIDKey, traceID), clientIDKey, clientID)
+			handler(recorder, r.WithContext(ctx))
+		} else {
+			recorder.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSON(recorder, http.StatusUnauthorized, errorResponse{Error: "invalid bearer token"})
+		}
+		span.SetAttributes(semconv.HTTPStatusCode(recorder.status))
+		if recorder.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(recorder.status))
+		}
+	}
+}
+
+// publisher returns the publisher of the client which sent the request.
+func (g *Gateway) publisher(ctx context.Context) Publisher {
+	return g.publishers[ctx.Value(clientIDKey).(string)]
+}
+
+// authenticate returns the id of the client which token is in "Authorization: Bearer <token>" header.
+func (g *Gateway) authenticate(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	token := strings.TrimPrefix(header, "Bearer ")
+	if token == header {
+		return "", false
+	}
+	for _, client := range g.clients {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(client.Token)) == 1 {
+			return client.ID, true
+		}
+	}
+	return "", false
+}
+
+// statusRecorder keeps the status of the response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// send publishes the command which changes the storage with the requested priority and waits for the reply
+// if it is requested.
+func (g *Gateway) send(ctx context.Context, w http.ResponseWriter, r *http.Request, command *models.Command) {
+	wait := g.waitForReply
+	if value := r.URL.Query().Get("wait"); value != "" {
+		var err error
+		if wait, err = strconv.ParseBool(value); err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid wait"})
+			return
+		}
+	}
+	if value := r.URL.Query().Get("priority"); value != "" {
+		priority, err := strconv.ParseUint(value, 10, 8)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid priority"})
+			return
+		}
+		command.Priority = uint32(priority)
+	}
+
+	response := struct {
+		TraceID string `json:"trace_id"`
+	}{TraceID: ctx.Value(traceIDKey).(string)}
+
+	if !wait {
+		if err := g.publisher(ctx).SendCommand(ctx, command); err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, response)
+		return
+	}
+
+	if err := g.publisher(ctx).ExecuteCommand(ctx, command); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		// the trace id is set to the response header by traced
+		log.WithField(traceIDKey, w.Header().Get(traceIDKey)).Errorf("Cannot write response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusBadGateway
+	switch {
+	case errors.Is(err, client.ErrCommandFailed):
+		status = http.StatusUnprocessableEntity
+	case errors.Is(err, client.ErrBatchSpansShards):
+		status = http.StatusBadRequest
+	case errors.Is(err, context.DeadlineExceeded):
+		status = http.StatusGatewayTimeout
+	}
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
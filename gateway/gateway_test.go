@@ -0,0 +1,387 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/client"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/signing"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/tracing"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/validation"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+var testClients = []Client{{ID: "producer-1", Token: "secret-1"}, {ID: "producer-2", Token: "secret-2"}}
+
+func TestGateway(t *testing.T) {
+	expiresAt := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		waitForReply bool
+		method       string
+		target       string
+		body         string
+		mockBehavior func(publisher *MockPublisher)
+		wantStatus   int
+		wantBody     string
+	}{
+		{
+			name:   "should publish added item",
+			method: http.MethodPost,
+			target: "/items?collection=sessions",
+			body:   `{"id": 1, "payload": "A", "ttl_seconds": 10}`,
+			mockBehavior: func(publisher *MockPublisher) {
+				publisher.EXPECT().SendCommand(gomock.Any(), &models.Command{
+					Type:           models.CommandType_AddItem,
+					ItemID:         1,
+					ItemPayload:    "A",
+					ItemTTLSeconds: 10,
+					Collection:     "sessions",
+				}).Return(nil)
+			},
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name:         "should wait until added item is processed",
+			waitForReply: true,
+			method:       http.MethodPost,
+			target:       "/items",
+			body:         `{"id": 1, "payload": "A"}`,
+			mockBehavior: func(publisher *MockPublisher) {
+				publisher.EXPECT().ExecuteCommand(gomock.Any(), &models.Command{
+					Type:        models.CommandType_AddItem,
+					ItemID:      1,
+					ItemPayload: "A",
+				}).Return(nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:         "should not wait when it is disabled by the request",
+			waitForReply: true,
+			method:       http.MethodDelete,
+			target:       "/items/1?wait=false",
+			mockBehavior: func(publisher *MockPublisher) {
+				publisher.EXPECT().SendCommand(gomock.Any(), &models.Command{
+					Type:   models.CommandType_RemoveItem,
+					ItemID: 1,
+				}).Return(nil)
+			},
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name:   "should publish delayed commands",
+			method: http.MethodPost,
+			target: "/items",
+			body:   `{"id": 1, "payload": "A", "not_before": "2022-10-01T12:00:00Z"}`,
+			mockBehavior: func(publisher *MockPublisher) {
+				publisher.EXPECT().SendCommand(gomock.Any(), &models.Command{
+					Type:        models.CommandType_AddItem,
+					ItemID:      1,
+					ItemPayload: "A",
+					NotBefore:   expiresAt.UnixNano(),
+				}).Return(nil)
+			},
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name:   "should publish delayed removal",
+			method: http.MethodDelete,
+			target: "/items/1?not_before=2022-10-01T12:00:00Z",
+			mockBehavior: func(publisher *MockPublisher) {
+				publisher.EXPECT().SendCommand(gomock.Any(), &models.Command{
+					Type:      models.CommandType_RemoveItem,
+					ItemID:    1,
+					NotBefore: expiresAt.UnixNano(),
+				}).Return(nil)
+			},
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name:   "should publish removal with priority",
+			method: http.MethodDelete,
+			target: "/items/1?priority=9",
+			mockBehavior: func(publisher *MockPublisher) {
+				publisher.EXPECT().SendCommand(gomock.Any(), &models.Command{
+					Type:     models.CommandType_RemoveItem,
+					ItemID:   1,
+					Priority: 9,
+				}).Return(nil)
+			},
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name:       "should reject invalid priority",
+			method:     http.MethodDelete,
+			target:     "/items/1?priority=256",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "should reject invalid not_before",
+			method:     http.MethodDelete,
+			target:     "/items/1?not_before=tomorrow",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "should return failure of removed item",
+			method: http.MethodDelete,
+			target: "/items/1?wait=true&expected_version=2",
+			mockBehavior: func(publisher *MockPublisher) {
+				publisher.EXPECT().ExecuteCommand(gomock.Any(), &models.Command{
+					Type:            models.CommandType_RemoveItem,
+					ItemID:          1,
+					ExpectedVersion: 2,
+				}).Return(fmt.Errorf("%w: version conflict", client.ErrCommandFailed))
+			},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   `{"error":"command failed: version conflict"}`,
+		},
+		{
+			name:   "should return timeout of reply",
+			method: http.MethodDelete,
+			target: "/items/1?wait=true",
+			mockBehavior: func(publisher *MockPublisher) {
+				publisher.EXPECT().ExecuteCommand(gomock.Any(), gomock.Any()).Return(context.DeadlineExceeded)
+			},
+			wantStatus: http.StatusGatewayTimeout,
+		},
+		{
+			name:   "should return publishing error",
+			method: http.MethodPost,
+			target: "/items",
+			body:   `{"id": 1}`,
+			mockBehavior: func(publisher *MockPublisher) {
+				publisher.EXPECT().SendCommand(gomock.Any(), gomock.Any()).Return(errors.New("connection closed"))
+			},
+			wantStatus: http.StatusBadGateway,
+		},
+		{
+			name:       "should reject invalid item",
+			method:     http.MethodPost,
+			target:     "/items",
+			body:       `{"id": "1"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "should reject negative ttl",
+			method:     http.MethodPost,
+			target:     "/items",
+			body:       `{"id": 1, "ttl_seconds": -1}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "should reject invalid item id",
+			method:     http.MethodDelete,
+			target:     "/items/abc",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "should get item",
+			method: http.MethodGet,
+			target: "/items/1?collection=sessions",
+			mockBehavior: func(publisher *MockPublisher) {
+				publisher.EXPECT().QueryItems(gomock.Any(), &models.Command{
+					Type:       models.CommandType_GetItem,
+					ItemID:     1,
+					Collection: "sessions",
+				}).Return([]models.Item{{ID: 1, Payload: "A", ExpiresAt: expiresAt, Version: 2}}, nil)
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"id":1,"payload":"A","expires_at":"2022-10-01T12:00:00Z","version":2}`,
+		},
+		{
+			name:   "should return not found item",
+			method: http.MethodGet,
+			target: "/items/1",
+			mockBehavior: func(publisher *MockPublisher) {
+				publisher.EXPECT().QueryItems(gomock.Any(), gomock.Any()).Return(nil, nil)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:   "should get all items",
+			method: http.MethodGet,
+			target: "/items",
+			mockBehavior: func(publisher *MockPublisher) {
+				publisher.EXPECT().QueryItems(gomock.Any(), &models.Command{Type: models.CommandType_GetAllItems}).
+					Return([]models.Item{{ID: 1, Payload: "A", Version: 1}, {ID: 2, Payload: "B", Version: 1}}, nil)
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `[{"id":1,"payload":"A","version":1},{"id":2,"payload":"B","version":1}]`,
+		},
+		{
+			name:       "should not allow other methods",
+			method:     http.MethodPut,
+			target:     "/items",
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			publisher := NewMockPublisher(ctrl)
+			if tt.mockBehavior != nil {
+				tt.mockBehavior(publisher)
+			}
+			g := New(Configurations{WaitForReply: tt.waitForReply, Clients: testClients},
+				map[string]Publisher{testClients[0].ID: publisher})
+
+			request := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			request.Header.Set("Authorization", "Bearer "+testClients[0].Token)
+			recorder := httptest.NewRecorder()
+			g.httpServer.Handler.ServeHTTP(recorder, request)
+
+			assert.Equal(t, tt.wantStatus, recorder.Code)
+			assert.NotEmpty(t, recorder.Header().Get(traceIDKey))
+			if tt.wantBody != "" {
+				assert.JSONEq(t, tt.wantBody, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestGateway_TraceID(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var traceID interface{}
+	publisher := NewMockPublisher(ctrl)
+	publisher.EXPECT().SendCommand(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, _ *models.Command) error {
+			traceID = ctx.Value(traceIDKey)
+			return nil
+		})
+	g := New(Configurations{Clients: testClients}, map[string]Publisher{testClients[0].ID: publisher})
+
+	request := httptest.NewRequest(http.MethodDelete, "/items/1", nil)
+	request.Header.Set("Authorization", "Bearer "+testClients[0].Token)
+	recorder := httptest.NewRecorder()
+	g.httpServer.Handler.ServeHTTP(recorder, request)
+
+	assert.Equal(t, http.StatusAccepted, recorder.Code)
+	assert.Equal(t, recorder.Header().Get(traceIDKey), traceID)
+	assert.JSONEq(t, fmt.Sprintf(`{"trace_id":%q}`, traceID), recorder.Body.String())
+}
+
+func TestGateway_Authentication(t *testing.T) {
+	tests := []struct {
+		name          string
+		authorization string
+		wantClientID  string
+	}{
+		{
+			name:          "should send command by publisher of the token client",
+			authorization: "Bearer secret-2",
+			wantClientID:  "producer-2",
+		},
+		{
+			name: "should reject request without token",
+		},
+		{
+			name:          "should reject unknown token",
+			authorization: "Bearer secret-3",
+		},
+		{
+			name:          "should reject token of other scheme",
+			authorization: "Basic secret-1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			publishers := make(map[string]Publisher, len(testClients))
+			for _, caller := range testClients {
+				publisher := NewMockPublisher(ctrl)
+				if caller.ID == tt.wantClientID {
+					publisher.EXPECT().SendCommand(gomock.Any(), gomock.Any()).Return(nil)
+				}
+				publishers[caller.ID] = publisher
+			}
+			g := New(Configurations{Clients: testClients}, publishers)
+
+			request := httptest.NewRequest(http.MethodDelete, "/items/1", nil)
+			if tt.authorization != "" {
+				request.Header.Set("Authorization", tt.authorization)
+			}
+			recorder := httptest.NewRecorder()
+			g.httpServer.Handler.ServeHTTP(recorder, request)
+
+			if tt.wantClientID == "" {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+				assert.Equal(t, "Bearer", recorder.Header().Get("WWW-Authenticate"))
+				return
+			}
+			assert.Equal(t, http.StatusAccepted, recorder.Code)
+		})
+	}
+}
+
+func TestGateway_TraceParent(t *testing.T) {
+	if _, err := tracing.Init("gateway", tracing.Config{}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var traceID interface{}
+	publisher := NewMockPublisher(ctrl)
+	publisher.EXPECT().SendCommand(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, _ *models.Command) error {
+			traceID = ctx.Value(traceIDKey)
+			return nil
+		})
+	g := New(Configurations{Clients: testClients}, map[string]Publisher{testClients[0].ID: publisher})
+
+	request := httptest.NewRequest(http.MethodDelete, "/items/1", nil)
+	request.Header.Set("Authorization", "Bearer "+testClients[0].Token)
+	request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	recorder := httptest.NewRecorder()
+	g.httpServer.Handler.ServeHTTP(recorder, request)
+
+	assert.Equal(t, http.StatusAccepted, recorder.Code)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", traceID)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", recorder.Header().Get(traceIDKey))
+}
+
+func TestConfigurations_Validate(t *testing.T) {
+	configuration := DefaultConfigurations()
+	configuration.Clients = testClients
+	assert.NoError(t, configuration.Validate())
+
+	configuration.ListenAddr = ""
+	configuration.RabbitMQConfig.User = ""
+	err := configuration.Validate()
+	assert.ErrorIs(t, err, validation.ErrInvalid)
+	assert.EqualError(t, err, "invalid configuration: listenaddr: is required; rabbitmqconfig.user: is required unless externalauth is set")
+
+	configuration = DefaultConfigurations()
+	err = configuration.Validate()
+	assert.EqualError(t, err, "invalid configuration: clients: are required")
+
+	configuration.Clients = []Client{{ID: "producer-1", Token: "secret-1"}, {ID: "producer-1"}, {Token: "secret-3"}}
+	err = configuration.Validate()
+	assert.EqualError(t, err, "invalid configuration: clients[1].id: duplicated client producer-1; clients[1].token: is required; clients[2].id: is required")
+
+	configuration.Clients = []Client{{ID: "producer-1", Token: "secret-1", SigningConfig: signing.Key{Algorithm: "rsa"}}}
+	err = configuration.Validate()
+	assert.EqualError(t, err, `invalid configuration: clients[0].signingconfig: invalid signing key: unsupported algorithm "rsa"`)
+
+	caller := Client{ID: "producer-1", SigningConfig: signing.Key{Algorithm: signing.AlgorithmHMAC, Key: "c2VjcmV0"}}
+	clientConfig := configuration.ClientConfig(caller)
+	assert.Equal(t, caller.ID, clientConfig.ClientID)
+	assert.Equal(t, caller.SigningConfig, clientConfig.SigningConfig)
+}
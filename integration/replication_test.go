@@ -0,0 +1,179 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/client"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/repository"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const replicationToken = "integration"
+
+// TestReplication starts primary and two replicas as separate processes and checks that replicas converge
+// to the same ordered contents as the primary. It requires RabbitMQ, e.g. started by `make run-rabbit-mq`.
+func TestReplication(t *testing.T) {
+	rabbitMQ := client.RabbitMQConfig{
+		URL:      getEnv("RABBITMQCONFIG_URL", "localhost:5672"),
+		User:     getEnv("RABBITMQCONFIG_USER", "user"),
+		Password: getEnv("RABBITMQCONFIG_PASSWORD", "password"),
+	}
+	conn, err := rabbitMQ.Dial()
+	if err != nil {
+		t.Skipf("RabbitMQ is not available: %v", err)
+	}
+	conn.Close()
+
+	binary := filepath.Join(t.TempDir(), "app")
+	build := exec.Command("go", "build", "-o", binary, "..")
+	build.Stdout, build.Stderr = os.Stdout, os.Stderr
+	require.NoError(t, build.Run())
+
+	runID := uuid.NewString()
+	exchange := "changes-" + runID
+	commandsQueue := "items-" + runID
+	env := []string{
+		"RABBITMQCONFIG_URL=" + rabbitMQ.URL,
+		"RABBITMQCONFIG_USER=" + rabbitMQ.User,
+		"RABBITMQCONFIG_PASSWORD=" + rabbitMQ.Password,
+		"CHANGEFEEDCONFIG_EXCHANGE=" + exchange,
+		"REPLICATIONCONFIG_TOKEN=" + replicationToken,
+	}
+
+	primaryAddr := freeAddr(t)
+	startServer(t, binary, append(env,
+		"RABBITMQCONFIG_QUEUENAME="+commandsQueue,
+		"REPLICATIONCONFIG_ROLE=primary",
+		"REPLICATIONCONFIG_LISTENADDR="+primaryAddr,
+	))
+	waitForSnapshot(t, primaryAddr)
+
+	replicaEnv := append(env,
+		"RABBITMQCONFIG_QUEUENAME=reads-"+runID,
+		"REPLICATIONCONFIG_ROLE=replica",
+		"REPLICATIONCONFIG_PRIMARYURL=http://"+primaryAddr,
+	)
+	// the first replica follows all changes, the second one starts from the snapshot with existing items
+	firstReplicaAddr := freeAddr(t)
+	startServer(t, binary, append(replicaEnv, "REPLICATIONCONFIG_LISTENADDR="+firstReplicaAddr))
+
+	c := client.New(client.Configurations{RabbitMQConfig: client.RabbitMQConfig{
+		URL:       rabbitMQ.URL,
+		User:      rabbitMQ.User,
+		Password:  rabbitMQ.Password,
+		QueueName: commandsQueue,
+	}})
+	require.NoError(t, c.InitClient())
+	defer c.Cleanup()
+
+	ctx := context.Background()
+	for i := int64(1); i <= 50; i++ {
+		require.NoError(t, c.SendCommand(ctx, &models.Command{Type: models.CommandType_AddItem, ItemID: i, ItemPayload: fmt.Sprint("item", i)}))
+	}
+
+	secondReplicaAddr := freeAddr(t)
+	startServer(t, binary, append(replicaEnv, "REPLICATIONCONFIG_LISTENADDR="+secondReplicaAddr))
+
+	for i := int64(1); i <= 50; i += 3 {
+		require.NoError(t, c.SendCommand(ctx, &models.Command{Type: models.CommandType_RemoveItem, ItemID: i}))
+	}
+	require.NoError(t, c.SendCommand(ctx, &models.Command{Type: models.CommandType_UpdateItem, ItemID: 2, ItemPayload: "updated"}))
+	require.NoError(t, c.SendBatch(ctx, client.NewBatchBuilder("sessions").
+		AddItem(1, "A", 0).
+		AddItem(2, "B", 0).
+		RemoveItem(1).
+		Build()))
+	require.NoError(t, c.SendCommand(ctx, &models.Command{Type: models.CommandType_CreateCollection, Collection: "dropped"}))
+	require.NoError(t, c.SendCommand(ctx, &models.Command{Type: models.CommandType_DropCollection, Collection: "dropped"}))
+	require.NoError(t, c.SendCommand(ctx, &models.Command{Type: models.CommandType_AddItem, ItemID: 1, ItemPayload: "done", Collection: "done"}))
+
+	require.Eventually(t, func() bool {
+		return hasCollection(getSnapshot(t, primaryAddr), "done")
+	}, 30*time.Second, 100*time.Millisecond)
+
+	// the primary processes commands concurrently, so replicas are compared with its current contents
+	for _, addr := range []string{firstReplicaAddr, secondReplicaAddr} {
+		assert.Eventually(t, func() bool {
+			want, got := getSnapshot(t, primaryAddr), getSnapshot(t, addr)
+			return want != nil && got != nil && assert.ObjectsAreEqual(want.Collections, got.Collections)
+		}, 30*time.Second, 100*time.Millisecond, "replica %s is not in sync", addr)
+	}
+}
+
+func startServer(t *testing.T, binary string, env []string) {
+	cmd := exec.Command(binary, "server")
+	// working directory without config file, so only environment variables are used
+	cmd.Dir = t.TempDir()
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	require.NoError(t, cmd.Start())
+
+	t.Cleanup(func() {
+		_ = cmd.Process.Signal(os.Interrupt)
+		_ = cmd.Wait()
+	})
+}
+
+func waitForSnapshot(t *testing.T, addr string) {
+	require.Eventually(t, func() bool {
+		return getSnapshot(t, addr) != nil
+	}, 10*time.Second, 100*time.Millisecond)
+}
+
+// getSnapshot returns nil if the server is not available.
+func getSnapshot(t *testing.T, addr string) *repository.ReplicaSnapshot {
+	req, err := http.NewRequest(http.MethodGet, "http://"+addr+"/replication/snapshot", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+replicationToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	snapshot := new(repository.ReplicaSnapshot)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(snapshot))
+	return snapshot
+}
+
+func hasCollection(snapshot *repository.ReplicaSnapshot, name string) bool {
+	if snapshot == nil {
+		return false
+	}
+	for _, collection := range snapshot.Collections {
+		if collection.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func freeAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func getEnv(key, defaultValue string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,349 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/validation"
+	"github.com/sirupsen/logrus"
+)
+
+// Formats of logs.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Outputs which are not files.
+const (
+	OutputStdout = "stdout"
+	OutputStderr = "stderr"
+)
+
+var (
+	ErrInvalidLevel   = errors.New("invalid level")
+	ErrUnknownPackage = errors.New("unknown package")
+)
+
+type Config struct {
+	// Level is the level of packages which are not listed in Packages: trace, debug, info (default), warn or error.
+	Level string
+	// Format is text (default) or json.
+	Format string
+	// Outputs are files logs are written to, stdout and stderr are the standard streams. Logs are written to stdout
+	// when it is empty.
+	Outputs []string
+	// Packages override the level of packages, the level of a package is also used for its subpackages.
+	Packages []PackageLevel
+}
+
+type PackageLevel struct {
+	// Package is the path of the package in the module, e.g. "server/repository".
+	Package string
+	Level   string
+}
+
+// Levels are levels of logs which are used now.
+type Levels struct {
+	// Level is the default level.
+	Level string `json:"level"`
+	// Packages are levels of all packages.
+	Packages map[string]string `json:"packages"`
+	// Debug is set when all packages are switched to debug level, see ToggleDebug.
+	Debug bool `json:"debug"`
+}
+
+var (
+	mu        sync.Mutex
+	loggers   = map[string]*logrus.Logger{}
+	level     = logrus.InfoLevel
+	overrides = map[string]logrus.Level{}
+	debug     bool
+	output    io.Writer        = &lockedWriter{w: os.Stdout}
+	formatter logrus.Formatter = &logrus.TextFormatter{}
+)
+
+// Logger returns the logger of the package, name is the path of the package in the module, e.g. "server/repository".
+// The logger is configured by Configure and is written to stdout in text until then.
+func Logger(name string) *logrus.Logger {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if logger, ok := loggers[name]; ok {
+		return logger
+	}
+	logger := logrus.New()
+	logger.SetOutput(output)
+	logger.SetFormatter(formatter)
+	logger.SetLevel(levelOf(name))
+	loggers[name] = logger
+	return logger
+}
+
+// Configure sets the level, format and outputs of all loggers, including the standard logger of logrus.
+// The returned function closes log files.
+func Configure(config Config) (func() error, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	defaultLevel, packageLevels := config.levels()
+	var newFormatter logrus.Formatter = &logrus.TextFormatter{}
+	if config.Format == FormatJSON {
+		newFormatter = &logrus.JSONFormatter{}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	newOutput, closeOutputs, err := openOutputs(config.Outputs)
+	if err != nil {
+		return nil, err
+	}
+
+	level, overrides, output, formatter = defaultLevel, packageLevels, newOutput, newFormatter
+	for _, logger := range append(sortedLoggers(), logrus.StandardLogger()) {
+		logger.SetOutput(output)
+		logger.SetFormatter(formatter)
+	}
+	apply()
+	return closeOutputs, nil
+}
+
+// SetLevels replaces the default level and levels of packages by ones of the config, its format and outputs
+// are not changed. Levels set by SetLevel are reset.
+func SetLevels(config Config) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	defaultLevel, packageLevels := config.levels()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	level, overrides = defaultLevel, packageLevels
+	apply()
+	return nil
+}
+
+// levels returns the default level and levels of packages of the valid config.
+func (c Config) levels() (logrus.Level, map[string]logrus.Level) {
+	defaultLevel, _ := parseLevel(c.Level, logrus.InfoLevel)
+	packageLevels := make(map[string]logrus.Level, len(c.Packages))
+	for _, packageLevel := range c.Packages {
+		packageLevels[packageLevel.Package], _ = parseLevel(packageLevel.Level, defaultLevel)
+	}
+	return defaultLevel, packageLevels
+}
+
+// Validate checks levels, the format and that packages have loggers. Outputs are checked when they are opened.
+func (c Config) Validate() error {
+	var errs validation.Errors
+	if _, err := parseLevel(c.Level, logrus.InfoLevel); err != nil {
+		errs.Add("level", err)
+	}
+	switch c.Format {
+	case "", FormatText, FormatJSON:
+	default:
+		errs.Addf("format", "unknown format %q", c.Format)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	packages := make(map[string]bool, len(c.Packages))
+	for i, packageLevel := range c.Packages {
+		field := fmt.Sprintf("packages[%d]", i)
+		switch {
+		case packages[packageLevel.Package]:
+			errs.Addf(field+".package", "duplicated package %q", packageLevel.Package)
+		case !knownPackage(packageLevel.Package):
+			errs.Addf(field+".package", "unknown package %q", packageLevel.Package)
+		}
+		packages[packageLevel.Package] = true
+		if _, err := parseLevel(packageLevel.Level, logrus.InfoLevel); err != nil {
+			errs.Add(field+".level", err)
+		}
+	}
+	return errs.Err()
+}
+
+// SetLevel changes the level of the package, empty name changes the default level. Empty level of the package
+// resets it to the default level.
+func SetLevel(name, newLevel string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if name == "" {
+		parsed, err := parseLevel(newLevel, logrus.InfoLevel)
+		if err != nil {
+			return err
+		}
+		level = parsed
+		apply()
+		return nil
+	}
+
+	if !knownPackage(name) {
+		return fmt.Errorf("%w: %s", ErrUnknownPackage, name)
+	}
+	if newLevel == "" {
+		delete(overrides, name)
+		apply()
+		return nil
+	}
+	parsed, err := parseLevel(newLevel, logrus.InfoLevel)
+	if err != nil {
+		return err
+	}
+	overrides[name] = parsed
+	apply()
+	return nil
+}
+
+// ToggleDebug switches all packages to debug level or back to their levels and returns whether debug level is on.
+func ToggleDebug() bool {
+	mu.Lock()
+	defer mu.Unlock()
+
+	debug = !debug
+	apply()
+	return debug
+}
+
+// GetLevels returns levels of all packages.
+func GetLevels() Levels {
+	mu.Lock()
+	defer mu.Unlock()
+
+	levels := Levels{
+		Level:    level.String(),
+		Packages: make(map[string]string, len(loggers)),
+		Debug:    debug,
+	}
+	for name, logger := range loggers {
+		levels.Packages[name] = logger.GetLevel().String()
+	}
+	return levels
+}
+
+// apply sets levels of all loggers, it should be called under the lock.
+func apply() {
+	for name, logger := range loggers {
+		logger.SetLevel(levelOf(name))
+	}
+	if debug && level < logrus.DebugLevel {
+		logrus.SetLevel(logrus.DebugLevel)
+	} else {
+		logrus.SetLevel(level)
+	}
+}
+
+// levelOf returns the level of the package by the closest package with the level, it should be called under the lock.
+func levelOf(name string) logrus.Level {
+	packageLevel := level
+	for prefix := name; prefix != ""; prefix = parent(prefix) {
+		if l, ok := overrides[prefix]; ok {
+			packageLevel = l
+			break
+		}
+	}
+	if debug && packageLevel < logrus.DebugLevel {
+		return logrus.DebugLevel
+	}
+	return packageLevel
+}
+
+func parent(name string) string {
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		return name[:i]
+	}
+	return ""
+}
+
+// knownPackage reports whether the package or its subpackages have loggers, it should be called under the lock.
+func knownPackage(name string) bool {
+	for logger := range loggers {
+		if logger == name || strings.HasPrefix(logger, name+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedLoggers() []*logrus.Logger {
+	names := make([]string, 0, len(loggers))
+	for name := range loggers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	sorted := make([]*logrus.Logger, 0, len(names))
+	for _, name := range names {
+		sorted = append(sorted, loggers[name])
+	}
+	return sorted
+}
+
+func parseLevel(value string, defaultLevel logrus.Level) (logrus.Level, error) {
+	if value == "" {
+		return defaultLevel, nil
+	}
+	parsed, err := logrus.ParseLevel(value)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidLevel, err)
+	}
+	return parsed, nil
+}
+
+func openOutputs(paths []string) (io.Writer, func() error, error) {
+	if len(paths) == 0 {
+		paths = []string{OutputStdout}
+	}
+
+	var writers []io.Writer
+	var files []*os.File
+	closeFiles := func() error {
+		var err error
+		for _, f := range files {
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		return err
+	}
+	for _, path := range paths {
+		switch path {
+		case OutputStdout:
+			writers = append(writers, os.Stdout)
+		case OutputStderr:
+			writers = append(writers, os.Stderr)
+		default:
+			f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+			if err != nil {
+				closeFiles()
+				return nil, nil, err
+			}
+			files = append(files, f)
+			writers = append(writers, f)
+		}
+	}
+	return &lockedWriter{w: io.MultiWriter(writers...)}, closeFiles, nil
+}
+
+// lockedWriter serializes writes of the loggers of all packages, so lines are not interleaved.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
+}
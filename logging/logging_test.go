@@ -0,0 +1,131 @@
+package logging
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/validation"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigure(t *testing.T) {
+	server := Logger("server")
+	repository := Logger("server/repository")
+	client := Logger("client")
+	defer Configure(Config{})
+
+	file := filepath.Join(t.TempDir(), "app.log")
+	closeOutputs, err := Configure(Config{
+		Level:    "warn",
+		Format:   FormatJSON,
+		Outputs:  []string{file},
+		Packages: []PackageLevel{{Package: "server", Level: "debug"}},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, logrus.DebugLevel, server.GetLevel())
+	assert.Equal(t, logrus.DebugLevel, repository.GetLevel(), "level of the parent package is used")
+	assert.Equal(t, logrus.WarnLevel, client.GetLevel())
+	assert.Equal(t, logrus.WarnLevel, Logger("gateway").GetLevel(), "logger created later is configured too")
+
+	repository.WithField("X-Trace-ID", "trace_id").Debug("Item is added")
+	client.Info("Command is sent")
+	require.NoError(t, closeOutputs())
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 1)
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, "Item is added", entry["msg"])
+	assert.Equal(t, "debug", entry["level"])
+	assert.Equal(t, "trace_id", entry["X-Trace-ID"])
+}
+
+func TestConfigure_Invalid(t *testing.T) {
+	Logger("server")
+	defer Configure(Config{})
+
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr error
+	}{
+		{name: "should reject unknown level", config: Config{Level: "loud"}, wantErr: validation.ErrInvalid},
+		{name: "should reject unknown format", config: Config{Format: "xml"}, wantErr: validation.ErrInvalid},
+		{name: "should reject unknown level of package", config: Config{Packages: []PackageLevel{{Package: "server", Level: "loud"}}}, wantErr: validation.ErrInvalid},
+		{name: "should reject unknown package", config: Config{Packages: []PackageLevel{{Package: "serve", Level: "debug"}}}, wantErr: validation.ErrInvalid},
+		{name: "should reject duplicated package", config: Config{Packages: []PackageLevel{{Package: "server"}, {Package: "server"}}}, wantErr: validation.ErrInvalid},
+		{name: "should reject output in missing directory", config: Config{Outputs: []string{filepath.Join(t.TempDir(), "missing", "app.log")}}, wantErr: os.ErrNotExist},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Configure(tt.config)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestSetLevel(t *testing.T) {
+	server := Logger("server")
+	repository := Logger("server/repository")
+	defer Configure(Config{})
+	_, err := Configure(Config{})
+	require.NoError(t, err)
+
+	require.NoError(t, SetLevel("server/repository", "error"))
+	assert.Equal(t, logrus.ErrorLevel, repository.GetLevel())
+	assert.Equal(t, logrus.InfoLevel, server.GetLevel())
+
+	require.NoError(t, SetLevel("", "warn"))
+	assert.Equal(t, logrus.WarnLevel, server.GetLevel())
+	assert.Equal(t, logrus.ErrorLevel, repository.GetLevel())
+
+	require.NoError(t, SetLevel("server/repository", ""))
+	assert.Equal(t, logrus.WarnLevel, repository.GetLevel())
+
+	assert.ErrorIs(t, SetLevel("server", "loud"), ErrInvalidLevel)
+	assert.ErrorIs(t, SetLevel("serve", "debug"), ErrUnknownPackage)
+}
+
+func TestToggleDebug(t *testing.T) {
+	server := Logger("server")
+	repository := Logger("server/repository")
+	defer Configure(Config{})
+	_, err := Configure(Config{Packages: []PackageLevel{{Package: "server/repository", Level: "trace"}}})
+	require.NoError(t, err)
+
+	assert.True(t, ToggleDebug())
+	assert.Equal(t, logrus.DebugLevel, server.GetLevel())
+	assert.Equal(t, logrus.TraceLevel, repository.GetLevel(), "more verbose level is kept")
+	assert.True(t, GetLevels().Debug)
+
+	assert.False(t, ToggleDebug())
+	levels := GetLevels()
+	assert.Equal(t, "info", levels.Level)
+	assert.Equal(t, "info", levels.Packages["server"])
+	assert.Equal(t, "trace", levels.Packages["server/repository"])
+	assert.False(t, levels.Debug)
+}
+
+func TestSetLevels(t *testing.T) {
+	server := Logger("server")
+	repository := Logger("server/repository")
+	defer Configure(Config{})
+	_, err := Configure(Config{Level: "warn"})
+	require.NoError(t, err)
+	require.NoError(t, SetLevel("server", "error"))
+
+	require.NoError(t, SetLevels(Config{Level: "info", Packages: []PackageLevel{{Package: "server/repository", Level: "debug"}}}))
+	assert.Equal(t, logrus.InfoLevel, server.GetLevel(), "level set by SetLevel is reset")
+	assert.Equal(t, logrus.DebugLevel, repository.GetLevel())
+
+	assert.ErrorIs(t, SetLevels(Config{Level: "loud"}), validation.ErrInvalid)
+	assert.Equal(t, logrus.DebugLevel, repository.GetLevel(), "invalid levels are not applied")
+}
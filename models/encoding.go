@@ -0,0 +1,68 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Content types are set to the AMQP "content_type" property, so the receiver knows how the message body is encoded.
+const (
+	// ContentTypeProtobuf is the default, messages without content type are decoded as protobuf too.
+	ContentTypeProtobuf = "application/protobuf"
+	// ContentTypeJSON is protobuf JSON mapping, which is readable in the RabbitMQ management UI.
+	ContentTypeJSON = "application/json"
+)
+
+var ErrUnsupportedContentType = errors.New("unsupported content type")
+
+// Marshal encodes the message in the given content type.
+func Marshal(contentType string, msg proto.Message) ([]byte, error) {
+	mediaType, err := ParseContentType(contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	if mediaType == ContentTypeJSON {
+		return protojson.Marshal(msg)
+	}
+	return proto.Marshal(msg)
+}
+
+// Unmarshal decodes the message body encoded in the given content type.
+func Unmarshal(contentType string, body []byte, msg proto.Message) error {
+	mediaType, err := ParseContentType(contentType)
+	if err != nil {
+		return err
+	}
+
+	if mediaType == ContentTypeJSON {
+		return protojson.Unmarshal(body, msg)
+	}
+	return proto.Unmarshal(body, msg)
+}
+
+// ParseContentType returns ContentTypeProtobuf or ContentTypeJSON for the content type, parameters like charset
+// are ignored. Empty content type and "application/x-protobuf" mean protobuf.
+func ParseContentType(contentType string) (string, error) {
+	if contentType == "" {
+		return ContentTypeProtobuf, nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", fmt.Errorf("%w: %q", ErrUnsupportedContentType, contentType)
+	}
+
+	switch mediaType {
+	case ContentTypeProtobuf, "application/x-protobuf":
+		return ContentTypeProtobuf, nil
+	case ContentTypeJSON:
+		return ContentTypeJSON, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnsupportedContentType, contentType)
+	}
+}
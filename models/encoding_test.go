@@ -0,0 +1,51 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestMarshal(t *testing.T) {
+	command := &Command{Type: CommandType_AddItem, ItemID: 1, ItemPayload: "A", Collection: "sessions"}
+
+	tests := []struct {
+		name        string
+		contentType string
+		wantErr     error
+	}{
+		{name: "should encode protobuf by default", contentType: ""},
+		{name: "should encode protobuf", contentType: ContentTypeProtobuf},
+		{name: "should encode protobuf with legacy content type", contentType: "application/x-protobuf"},
+		{name: "should encode json", contentType: ContentTypeJSON},
+		{name: "should encode json with charset", contentType: "application/json; charset=utf-8"},
+		{name: "should reject unknown content type", contentType: "text/plain", wantErr: ErrUnsupportedContentType},
+		{name: "should reject invalid content type", contentType: "application/", wantErr: ErrUnsupportedContentType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := Marshal(tt.contentType, command)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			decoded := new(Command)
+			require.NoError(t, Unmarshal(tt.contentType, body, decoded))
+			assert.True(t, proto.Equal(command, decoded), "decoded %v", decoded)
+		})
+	}
+}
+
+func TestUnmarshal_JSON(t *testing.T) {
+	command := new(Command)
+	err := Unmarshal(ContentTypeJSON, []byte(`{"type": "RemoveItem", "ItemID": "2", "ExpectedVersion": 3}`), command)
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(&Command{Type: CommandType_RemoveItem, ItemID: 2, ExpectedVersion: 3}, command))
+
+	err = Unmarshal(ContentTypeJSON, []byte(`{"Unknown": 1}`), command)
+	assert.Error(t, err)
+}
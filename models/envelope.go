@@ -0,0 +1,137 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// EnvelopeSchemaVersion is the version of Envelope this code sends and understands.
+const EnvelopeSchemaVersion = 1
+
+var (
+	ErrUnsupportedSchemaVersion = errors.New("unsupported schema version")
+	// ErrUnknownPayload is returned for envelopes without payload, e.g. of a command type added in a newer version.
+	ErrUnknownPayload = errors.New("unknown envelope payload")
+)
+
+// NewEnvelope wraps the command in the envelope of the current schema version.
+func NewEnvelope(command *Command, clientID string, issuedAt time.Time) (*Envelope, error) {
+	envelope := &Envelope{
+		SchemaVersion: EnvelopeSchemaVersion,
+		ClientID:      clientID,
+		IssuedAt:      issuedAt.UnixNano(),
+		NotBefore:     command.NotBefore,
+	}
+
+	switch command.Type {
+	case CommandType_AddItem:
+		envelope.Payload = &Envelope_AddItem{AddItem: &AddItemCommand{
+			Collection:     command.Collection,
+			ItemID:         command.ItemID,
+			ItemPayload:    command.ItemPayload,
+			ItemTTLSeconds: command.ItemTTLSeconds,
+		}}
+	case CommandType_GetItem:
+		envelope.Payload = &Envelope_GetItem{GetItem: &GetItemCommand{
+			Collection: command.Collection,
+			ItemID:     command.ItemID,
+		}}
+	case CommandType_GetAllItems:
+		envelope.Payload = &Envelope_GetAllItems{GetAllItems: &GetAllItemsCommand{
+			Collection: command.Collection,
+		}}
+	case CommandType_RemoveItem:
+		envelope.Payload = &Envelope_RemoveItem{RemoveItem: &RemoveItemCommand{
+			Collection:      command.Collection,
+			ItemID:          command.ItemID,
+			ExpectedVersion: command.ExpectedVersion,
+		}}
+	case CommandType_CreateCollection:
+		envelope.Payload = &Envelope_CreateCollection{CreateCollection: &CreateCollectionCommand{
+			Collection: command.Collection,
+		}}
+	case CommandType_DropCollection:
+		envelope.Payload = &Envelope_DropCollection{DropCollection: &DropCollectionCommand{
+			Collection: command.Collection,
+		}}
+	case CommandType_UpdateItem:
+		envelope.Payload = &Envelope_UpdateItem{UpdateItem: &UpdateItemCommand{
+			Collection:      command.Collection,
+			ItemID:          command.ItemID,
+			ItemPayload:     command.ItemPayload,
+			ItemTTLSeconds:  command.ItemTTLSeconds,
+			ExpectedVersion: command.ExpectedVersion,
+		}}
+	default:
+		return nil, fmt.Errorf("command type %s cannot be sent in envelope", command.Type)
+	}
+	return envelope, nil
+}
+
+// Command converts payload of the envelope to the command processed by the server. Envelopes of other schema
+// versions and without payload are rejected.
+func (x *Envelope) Command() (*Command, error) {
+	if x.SchemaVersion != EnvelopeSchemaVersion {
+		return nil, fmt.Errorf("%w: %d, supported version is %d", ErrUnsupportedSchemaVersion, x.SchemaVersion, EnvelopeSchemaVersion)
+	}
+
+	command, err := x.payloadCommand()
+	if err != nil {
+		return nil, err
+	}
+	command.NotBefore = x.NotBefore
+	return command, nil
+}
+
+func (x *Envelope) payloadCommand() (*Command, error) {
+	switch payload := x.Payload.(type) {
+	case *Envelope_AddItem:
+		return &Command{
+			Type:           CommandType_AddItem,
+			Collection:     payload.AddItem.Collection,
+			ItemID:         payload.AddItem.ItemID,
+			ItemPayload:    payload.AddItem.ItemPayload,
+			ItemTTLSeconds: payload.AddItem.ItemTTLSeconds,
+		}, nil
+	case *Envelope_GetItem:
+		return &Command{
+			Type:       CommandType_GetItem,
+			Collection: payload.GetItem.Collection,
+			ItemID:     payload.GetItem.ItemID,
+		}, nil
+	case *Envelope_GetAllItems:
+		return &Command{
+			Type:       CommandType_GetAllItems,
+			Collection: payload.GetAllItems.Collection,
+		}, nil
+	case *Envelope_RemoveItem:
+		return &Command{
+			Type:            CommandType_RemoveItem,
+			Collection:      payload.RemoveItem.Collection,
+			ItemID:          payload.RemoveItem.ItemID,
+			ExpectedVersion: payload.RemoveItem.ExpectedVersion,
+		}, nil
+	case *Envelope_CreateCollection:
+		return &Command{
+			Type:       CommandType_CreateCollection,
+			Collection: payload.CreateCollection.Collection,
+		}, nil
+	case *Envelope_DropCollection:
+		return &Command{
+			Type:       CommandType_DropCollection,
+			Collection: payload.DropCollection.Collection,
+		}, nil
+	case *Envelope_UpdateItem:
+		return &Command{
+			Type:            CommandType_UpdateItem,
+			Collection:      payload.UpdateItem.Collection,
+			ItemID:          payload.UpdateItem.ItemID,
+			ItemPayload:     payload.UpdateItem.ItemPayload,
+			ItemTTLSeconds:  payload.UpdateItem.ItemTTLSeconds,
+			ExpectedVersion: payload.UpdateItem.ExpectedVersion,
+		}, nil
+	default:
+		return nil, ErrUnknownPayload
+	}
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestEnvelope(t *testing.T) {
+	issuedAt := time.Unix(1664625600, 0)
+
+	commands := []*Command{
+		{Type: CommandType_AddItem, Collection: "sessions", ItemID: 1, ItemPayload: "A", ItemTTLSeconds: 10},
+		{Type: CommandType_GetItem, Collection: "sessions", ItemID: 1},
+		{Type: CommandType_GetAllItems, Collection: "sessions"},
+		{Type: CommandType_RemoveItem, Collection: "sessions", ItemID: 1, ExpectedVersion: 2, NotBefore: issuedAt.Add(time.Hour).UnixNano()},
+		{Type: CommandType_CreateCollection, Collection: "sessions"},
+		{Type: CommandType_DropCollection, Collection: "sessions"},
+		{Type: CommandType_UpdateItem, Collection: "sessions", ItemID: 1, ItemPayload: "B", ItemTTLSeconds: 5, ExpectedVersion: 3},
+	}
+	for _, command := range commands {
+		t.Run(command.Type.String(), func(t *testing.T) {
+			envelope, err := NewEnvelope(command, "client-1", issuedAt)
+			require.NoError(t, err)
+			assert.Equal(t, uint32(EnvelopeSchemaVersion), envelope.SchemaVersion)
+			assert.Equal(t, "client-1", envelope.ClientID)
+			assert.Equal(t, issuedAt.UnixNano(), envelope.IssuedAt)
+
+			got, err := envelope.Command()
+			require.NoError(t, err)
+			assert.True(t, proto.Equal(command, got), "command %v", got)
+		})
+	}
+}
+
+func TestEnvelope_Command(t *testing.T) {
+	tests := []struct {
+		name     string
+		envelope *Envelope
+		wantErr  error
+	}{
+		{
+			name:     "should reject envelope without version",
+			envelope: &Envelope{Payload: &Envelope_GetAllItems{GetAllItems: &GetAllItemsCommand{}}},
+			wantErr:  ErrUnsupportedSchemaVersion,
+		},
+		{
+			name:     "should reject newer version",
+			envelope: &Envelope{SchemaVersion: EnvelopeSchemaVersion + 1, Payload: &Envelope_GetAllItems{GetAllItems: &GetAllItemsCommand{}}},
+			wantErr:  ErrUnsupportedSchemaVersion,
+		},
+		{
+			name:     "should reject envelope without payload",
+			envelope: &Envelope{SchemaVersion: EnvelopeSchemaVersion},
+			wantErr:  ErrUnknownPayload,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.envelope.Command()
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestEnvelope_UnknownPayload(t *testing.T) {
+	// payload of a newer version is an unknown field, which must not be decoded as the zero command (AddItem)
+	body := []byte{0x08, EnvelopeSchemaVersion, 0xfa, 0x01, 0x00}
+	envelope := new(Envelope)
+	require.NoError(t, proto.Unmarshal(body, envelope))
+
+	_, err := envelope.Command()
+	assert.ErrorIs(t, err, ErrUnknownPayload)
+}
+
+func TestNewEnvelope_UnknownCommandType(t *testing.T) {
+	_, err := NewEnvelope(&Command{Type: CommandType(100)}, "", time.Now())
+	assert.Error(t, err)
+}
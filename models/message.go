@@ -0,0 +1,14 @@
+package models
+
+// Message types are set to the AMQP "type" property, so the server knows how to decode the message body.
+const (
+	// MessageTypeCommand is the default, messages without type are decoded as Command too.
+	MessageTypeCommand = "Command"
+	MessageTypeBatch   = "Batch"
+	// MessageTypeEnvelope is a Command wrapped in the versioned Envelope.
+	MessageTypeEnvelope = "Envelope"
+	// MessageTypeChangeEvent is used for the change feed published by the server.
+	MessageTypeChangeEvent = "ChangeEvent"
+	// MessageTypeReply is used for replies to commands which have ReplyTo property set.
+	MessageTypeReply = "Reply"
+)
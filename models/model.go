@@ -1,6 +1,49 @@
 package models
 
+import "time"
+
 type Item struct {
 	ID      int64
 	Payload string
+	// ExpiresAt is zero for items which never expire.
+	ExpiresAt time.Time
+	// Version is incremented on every change of the item. Versions of an item which is removed and added again
+	// continue after versions of removed items of the collection, so they are never repeated.
+	Version uint64
+	// Sequence is assigned when the item is added and defines the insertion order in its storage. It is based on
+	// the time of insertion by the server clock, so items of different shards can be merged only approximately.
+	Sequence uint64
+}
+
+// Expired reports whether the item has a TTL which is already elapsed at the given moment.
+func (i Item) Expired(now time.Time) bool {
+	return !i.ExpiresAt.IsZero() && !now.Before(i.ExpiresAt)
+}
+
+// NewItemRecord converts item to the message sent in replies.
+func NewItemRecord(item Item) *ItemRecord {
+	record := &ItemRecord{
+		ID:       item.ID,
+		Payload:  item.Payload,
+		Version:  item.Version,
+		Sequence: item.Sequence,
+	}
+	if !item.ExpiresAt.IsZero() {
+		record.ExpiresAt = item.ExpiresAt.UnixNano()
+	}
+	return record
+}
+
+// Item converts the record received in a reply to item.
+func (r *ItemRecord) Item() Item {
+	item := Item{
+		ID:       r.ID,
+		Payload:  r.Payload,
+		Version:  r.Version,
+		Sequence: r.Sequence,
+	}
+	if r.ExpiresAt != 0 {
+		item.ExpiresAt = time.Unix(0, r.ExpiresAt)
+	}
+	return item
 }
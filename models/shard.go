@@ -0,0 +1,21 @@
+package models
+
+import "fmt"
+
+// ShardOf returns the shard of the item in range [0, shards) by jump consistent hash, so when shards are added
+// only the items which move to the new shards change their shard.
+func ShardOf(itemID int64, shards int) int {
+	key := uint64(itemID)
+	b, j := int64(-1), int64(0)
+	for j < int64(shards) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
+
+// ShardRoutingKey is the routing key of the shard queue in the sharding exchange.
+func ShardRoutingKey(shard int) string {
+	return fmt.Sprintf("shard-%d", shard)
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShardOf(t *testing.T) {
+	const items = 10000
+	counts := make([]int, 4)
+	moved := 0
+	for itemID := int64(0); itemID < items; itemID++ {
+		shard := ShardOf(itemID, 4)
+		assert.Equal(t, shard, ShardOf(itemID, 4))
+		counts[shard]++
+
+		// when the shard is added, items move only to the new shard
+		if newShard := ShardOf(itemID, 5); newShard != shard {
+			assert.Equal(t, 4, newShard)
+			moved++
+		}
+	}
+
+	for _, count := range counts {
+		assert.InDelta(t, items/4, count, items/20)
+	}
+	assert.InDelta(t, items/5, moved, items/20)
+	assert.Equal(t, 0, ShardOf(42, 1))
+}
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/workerpool"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const recentErrorsLimit = 100
+
+// Activity keeps messages which are being processed by the app and the last processing errors.
+type Activity struct {
+	inFlight map[uint64]InFlightMessage
+	lastID   uint64
+	// errors is a ring buffer of the last recentErrorsLimit errors, next is the position of the next one
+	errors []ProcessingError
+	next   int
+	pool   *workerpool.WorkerPool
+	now    func() time.Time
+	mx     sync.Mutex
+}
+
+type InFlightMessage struct {
+	MessageID string    `json:"message_id"`
+	Type      string    `json:"type"`
+	TraceID   string    `json:"trace_id"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+type ProcessingError struct {
+	MessageID string    `json:"message_id"`
+	Type      string    `json:"type"`
+	TraceID   string    `json:"trace_id"`
+	Error     string    `json:"error"`
+	Time      time.Time `json:"time"`
+}
+
+func NewActivity() *Activity {
+	return &Activity{
+		inFlight: make(map[uint64]InFlightMessage),
+		now:      time.Now,
+	}
+}
+
+// begin registers the message as in-flight, the returned function should be called with the result of processing.
+func (a *Activity) begin(d amqp.Delivery) func(err error) {
+	traceID, _ := d.Headers[traceIDKey].(string)
+	message := InFlightMessage{
+		MessageID: d.MessageId,
+		Type:      d.Type,
+		TraceID:   traceID,
+	}
+
+	a.mx.Lock()
+	defer a.mx.Unlock()
+
+	a.lastID++
+	id := a.lastID
+	message.StartedAt = a.now()
+	a.inFlight[id] = message
+
+	return func(err error) {
+		a.mx.Lock()
+		defer a.mx.Unlock()
+
+		delete(a.inFlight, id)
+		if err == nil {
+			return
+		}
+
+		processingError := ProcessingError{
+			MessageID: message.MessageID,
+			Type:      message.Type,
+			TraceID:   message.TraceID,
+			Error:     err.Error(),
+			Time:      a.now(),
+		}
+		if len(a.errors) < recentErrorsLimit {
+			a.errors = append(a.errors, processingError)
+		} else {
+			a.errors[a.next] = processingError
+		}
+		a.next = (a.next + 1) % recentErrorsLimit
+	}
+}
+
+func (a *Activity) setWorkerPool(pool *workerpool.WorkerPool) {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+
+	a.pool = pool
+}
+
+// InFlight returns messages which are being processed from the oldest to the newest.
+func (a *Activity) InFlight() []InFlightMessage {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+
+	ids := make([]uint64, 0, len(a.inFlight))
+	for id := range a.inFlight {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	messages := make([]InFlightMessage, 0, len(ids))
+	for _, id := range ids {
+		messages = append(messages, a.inFlight[id])
+	}
+	return messages
+}
+
+// RecentErrors returns the last processing errors from the newest to the oldest.
+func (a *Activity) RecentErrors() []ProcessingError {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+
+	errors := make([]ProcessingError, 0, len(a.errors))
+	for i := 1; i <= len(a.errors); i++ {
+		errors = append(errors, a.errors[(a.next-i+len(a.errors))%len(a.errors)])
+	}
+	return errors
+}
+
+// Workers returns state of the worker pool, zero value when the app is not started.
+func (a *Activity) Workers() workerpool.Stats {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+
+	if a.pool == nil {
+		return workerpool.Stats{}
+	}
+	return a.pool.Stats()
+}
@@ -0,0 +1,408 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/logging"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/limits"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/repository"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/scheduler"
+)
+
+const (
+	adminItemsPath     = "/admin/items"
+	adminScheduledPath = "/admin/scheduled"
+	defaultPageLimit   = 100
+	maxPageLimit       = 1000
+)
+
+// AdminServer serves state of the server for debugging. All requests require "Authorization: Bearer <token>" header.
+//
+//	GET  /admin/collections                          names of collections and numbers of their items
+//	GET  /admin/items?collection=&offset=&limit=     items of the collection in insertion order
+//	GET  /admin/items/{id}?collection=               one item
+//	GET  /admin/items/count?collection=              number of items
+//	GET  /admin/workers                              state of the worker pool
+//	GET  /admin/inflight                             messages which are being processed
+//	GET  /admin/errors                               recent processing errors, the newest first
+//	GET  /admin/dump                                 all collections in JSON
+//	POST /admin/restore                              replaces all collections with the dump
+//	GET  /admin/scheduled                            pending delayed commands in the order they are due
+//	DELETE /admin/scheduled/{id}                     cancels the delayed command
+//	GET  /admin/clients                              rate limits and quotas used by clients
+//	GET  /admin/loglevel                             levels of logs by package
+//	PUT  /admin/loglevel?package=&level=             sets the level of the package, the default one without package
+//
+// Empty collection parameter means the default collection. Reads do not change the LRU order of items.
+type AdminServer struct {
+	httpServer *http.Server
+	registry   repository.Registry
+	activity   *Activity
+	scheduler  *scheduler.Scheduler
+	limiter    *limits.Limiter
+	token      string
+	// restoreDenied is the reason restoring of the store is disabled
+	restoreDenied string
+}
+
+type AdminOption func(s *AdminServer)
+
+// AdminReadOnly disables restoring of the store, e.g. when the store is replicated.
+func AdminReadOnly() AdminOption {
+	return func(s *AdminServer) {
+		s.restoreDenied = "store is replicated and cannot be restored"
+	}
+}
+
+// AdminChangeConsumers disables restoring of the store which changes are consumed by the change feed or Watch streams,
+// since restore writes no change events and the consumers would silently diverge from the store.
+func AdminChangeConsumers() AdminOption {
+	return func(s *AdminServer) {
+		s.restoreDenied = "changes of the store are consumed by the change feed or Watch streams, it cannot be restored"
+	}
+}
+
+// AdminScheduler enables endpoints of delayed commands.
+func AdminScheduler(scheduler *scheduler.Scheduler) AdminOption {
+	return func(s *AdminServer) {
+		s.scheduler = scheduler
+	}
+}
+
+// AdminLimiter enables the endpoint of client usage.
+func AdminLimiter(limiter *limits.Limiter) AdminOption {
+	return func(s *AdminServer) {
+		s.limiter = limiter
+	}
+}
+
+func NewAdminServer(config AdminConfig, registry repository.Registry, activity *Activity, opts ...AdminOption) *AdminServer {
+	s := &AdminServer{
+		registry: registry,
+		activity: activity,
+		token:    config.Token,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/admin/collections", s.get(s.collections))
+	mux.HandleFunc(adminItemsPath, s.get(s.items))
+	mux.HandleFunc(adminItemsPath+"/", s.get(s.item))
+	mux.HandleFunc("/admin/workers", s.get(func(r *http.Request) (interface{}, error) {
+		return s.activity.Workers(), nil
+	}))
+	mux.HandleFunc("/admin/inflight", s.get(func(r *http.Request) (interface{}, error) {
+		return s.activity.InFlight(), nil
+	}))
+	mux.HandleFunc("/admin/errors", s.get(func(r *http.Request) (interface{}, error) {
+		return s.activity.RecentErrors(), nil
+	}))
+	mux.HandleFunc("/admin/dump", s.get(func(r *http.Request) (interface{}, error) {
+		return s.registry.Snapshot(), nil
+	}))
+	mux.HandleFunc("/admin/restore", s.restore)
+	mux.HandleFunc(adminScheduledPath, s.get(s.scheduled))
+	mux.HandleFunc(adminScheduledPath+"/", s.cancelScheduled)
+	mux.HandleFunc("/admin/clients", s.get(s.clients))
+	mux.HandleFunc("/admin/loglevel", s.logLevel)
+
+	s.httpServer = &http.Server{
+		Addr:              config.ListenAddr,
+		Handler:           s.authenticate(mux),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	return s
+}
+
+func (s *AdminServer) Start() {
+	go func() {
+		log.Infof("Admin API is served on %s/admin/", s.httpServer.Addr)
+		if err := s.httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("Admin server stopped: %v", err)
+		}
+	}()
+}
+
+func (s *AdminServer) Quit() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return s.httpServer.Shutdown(ctx)
+}
+
+func (s *AdminServer) authenticate(next http.Handler) http.Handler {
+	return bearerAuth(s.token, next)
+}
+
+// httpError is returned by handlers to respond with the status code.
+type httpError struct {
+	status int
+	err    error
+}
+
+func (e *httpError) Error() string {
+	return e.err.Error()
+}
+
+var (
+	errSchedulerDisabled = &httpError{status: http.StatusNotFound, err: errors.New("delayed commands are not supported by this server")}
+	errLimiterDisabled   = &httpError{status: http.StatusNotFound, err: errors.New("clients are not limited by this server")}
+)
+
+func badRequest(format string, args ...interface{}) error {
+	return &httpError{status: http.StatusBadRequest, err: fmt.Errorf(format, args...)}
+}
+
+// get wraps the handler of GET requests which responds with JSON.
+func (s *AdminServer) get(handler func(r *http.Request) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		response, err := handler(r)
+		if err != nil {
+			writeAdminError(w, err)
+			return
+		}
+		writeAdminJSON(w, response)
+	}
+}
+
+func writeAdminJSON(w http.ResponseWriter, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Errorf("Cannot write admin response: %v", err)
+	}
+}
+
+func writeAdminError(w http.ResponseWriter, err error) {
+	var httpErr *httpError
+	switch {
+	case errors.As(err, &httpErr):
+		http.Error(w, err.Error(), httpErr.status)
+	case errors.Is(err, repository.ErrCollectionNotFound), errors.Is(err, repository.ErrItemNotFound),
+		errors.Is(err, scheduler.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+type collectionInfo struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func (s *AdminServer) collections(r *http.Request) (interface{}, error) {
+	names := s.registry.Collections()
+	collections := make([]collectionInfo, 0, len(names))
+	for _, name := range names {
+		count, err := s.count(name)
+		if errors.Is(err, repository.ErrCollectionNotFound) {
+			// collection was dropped in the meantime
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		collections = append(collections, collectionInfo{Name: name, Count: count})
+	}
+	return collections, nil
+}
+
+type itemsPage struct {
+	Collection string                    `json:"collection"`
+	Total      int                       `json:"total"`
+	Offset     int                       `json:"offset"`
+	Limit      int                       `json:"limit"`
+	Items      []repository.SnapshotItem `json:"items"`
+}
+
+func (s *AdminServer) items(r *http.Request) (interface{}, error) {
+	offset, err := queryInt(r, "offset", 0)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := queryInt(r, "limit", defaultPageLimit)
+	if err != nil {
+		return nil, err
+	}
+	if limit == 0 || limit > maxPageLimit {
+		return nil, badRequest("limit should be from 1 to %d", maxPageLimit)
+	}
+
+	collection := collectionParam(r)
+	items, err := s.collectionItems(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	page := itemsPage{
+		Collection: collection,
+		Total:      len(items),
+		Offset:     offset,
+		Limit:      limit,
+		Items:      []repository.SnapshotItem{},
+	}
+	for i := offset; i < len(items) && i < offset+limit; i++ {
+		page.Items = append(page.Items, repository.NewSnapshotItem(items[i]))
+	}
+	return page, nil
+}
+
+type itemsCount struct {
+	Collection string `json:"collection"`
+	Count      int    `json:"count"`
+}
+
+// item serves /admin/items/{id} and /admin/items/count.
+func (s *AdminServer) item(r *http.Request) (interface{}, error) {
+	collection := collectionParam(r)
+	param := strings.TrimPrefix(r.URL.Path, adminItemsPath+"/")
+	if param == "count" {
+		count, err := s.count(collection)
+		if err != nil {
+			return nil, err
+		}
+		return itemsCount{Collection: collection, Count: count}, nil
+	}
+
+	itemID, err := strconv.ParseInt(param, 10, 64)
+	if err != nil {
+		return nil, badRequest("invalid item id: %s", param)
+	}
+	repo, err := s.registry.GetCollection(collection)
+	if err != nil {
+		return nil, err
+	}
+	// the read does not touch the item in the LRU order
+	item, err := repo.GetItem(repository.WithoutAccess(r.Context()), itemID)
+	if err != nil {
+		return nil, err
+	}
+	return repository.NewSnapshotItem(item), nil
+}
+
+// collectionItems returns all items of the collection, GetAllItems does not touch LRU items.
+func (s *AdminServer) collectionItems(collection string) ([]models.Item, error) {
+	repo, err := s.registry.GetCollection(collection)
+	if err != nil {
+		return nil, err
+	}
+	return repo.GetAllItems(context.Background())
+}
+
+// count returns the number of items of the collection.
+func (s *AdminServer) count(collection string) (int, error) {
+	repo, err := s.registry.GetCollection(collection)
+	if err != nil {
+		return 0, err
+	}
+	return repo.Count(context.Background())
+}
+
+func (s *AdminServer) restore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if s.restoreDenied != "" {
+		http.Error(w, s.restoreDenied, http.StatusConflict)
+		return
+	}
+
+	snapshot := new(repository.ReplicaSnapshot)
+	if err := json.NewDecoder(r.Body).Decode(snapshot); err != nil {
+		writeAdminError(w, badRequest("invalid dump: %v", err))
+		return
+	}
+	if err := s.registry.Restore(snapshot); err != nil {
+		writeAdminError(w, badRequest("cannot restore dump: %v", err))
+		return
+	}
+
+	log.Warningf("Store is restored from the dump of %d collections", len(snapshot.Collections))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *AdminServer) scheduled(r *http.Request) (interface{}, error) {
+	if s.scheduler == nil {
+		return nil, errSchedulerDisabled
+	}
+	return s.scheduler.List(), nil
+}
+
+// cancelScheduled serves /admin/scheduled/{id}.
+func (s *AdminServer) cancelScheduled(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if s.scheduler == nil {
+		writeAdminError(w, errSchedulerDisabled)
+		return
+	}
+
+	if err := s.scheduler.Cancel(strings.TrimPrefix(r.URL.Path, adminScheduledPath+"/")); err != nil {
+		writeAdminError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *AdminServer) clients(r *http.Request) (interface{}, error) {
+	if s.limiter == nil {
+		return nil, errLimiterDisabled
+	}
+	return s.limiter.Usage(), nil
+}
+
+// logLevel serves /admin/loglevel. Empty level of the package resets it to the default level.
+func (s *AdminServer) logLevel(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		name, level := r.URL.Query().Get("package"), r.URL.Query().Get("level")
+		if err := logging.SetLevel(name, level); err != nil {
+			writeAdminError(w, badRequest("%v", err))
+			return
+		}
+		log.Warningf("Level of logs of package %q is set to %q", name, level)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	writeAdminJSON(w, logging.GetLevels())
+}
+
+func collectionParam(r *http.Request) string {
+	if collection := r.URL.Query().Get("collection"); collection != "" {
+		return collection
+	}
+	return repository.DefaultCollection
+}
+
+func queryInt(r *http.Request, name string, defaultValue int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, badRequest("invalid %s: %s", name, value)
+	}
+	return n, nil
+}
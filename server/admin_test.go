@@ -0,0 +1,355 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/logging"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/limits"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/repository"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/scheduler"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/service"
+	"github.com/golang/mock/gomock"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminServer(t *testing.T) {
+	ctx := context.Background()
+	registry, err := repository.NewRegistry(repository.RegistryConfig{})
+	require.NoError(t, err)
+	repo, err := registry.Collection(ctx, "")
+	require.NoError(t, err)
+	for i := int64(1); i <= 5; i++ {
+		require.NoError(t, repo.AddItem(ctx, models.Item{ID: i, Payload: "A"}))
+	}
+
+	activity := NewActivity()
+	activity.now = func() time.Time { return time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC) }
+	activity.begin(amqp.Delivery{MessageId: "1", Type: models.MessageTypeCommand, Headers: amqp.Table{traceIDKey: "trace_id"}})
+	activity.begin(amqp.Delivery{MessageId: "2"})(errors.New("cannot process"))
+
+	httpServer := httptest.NewServer(NewAdminServer(AdminConfig{Token: "secret"}, registry, activity).httpServer.Handler)
+	defer httpServer.Close()
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "should reject request without token",
+			method:     http.MethodGet,
+			path:       "/admin/items",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "should reject request with wrong token",
+			method:     http.MethodGet,
+			path:       "/admin/items",
+			token:      "wrong",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "should list collections",
+			method:     http.MethodGet,
+			path:       "/admin/collections",
+			token:      "secret",
+			wantStatus: http.StatusOK,
+			wantBody:   `[{"name":"default","count":5}]`,
+		},
+		{
+			name:       "should list page of items",
+			method:     http.MethodGet,
+			path:       "/admin/items?offset=3&limit=2",
+			token:      "secret",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"collection":"default","total":5,"offset":3,"limit":2,"items":[{"id":4,"payload":"A","version":1,"sequence":SEQ4},{"id":5,"payload":"A","version":1,"sequence":SEQ5}]}`,
+		},
+		{
+			name:       "should list empty page after the last item",
+			method:     http.MethodGet,
+			path:       "/admin/items?offset=10",
+			token:      "secret",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"collection":"default","total":5,"offset":10,"limit":100,"items":[]}`,
+		},
+		{
+			name:       "should reject invalid limit",
+			method:     http.MethodGet,
+			path:       "/admin/items?limit=0",
+			token:      "secret",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "should return not found for unknown collection",
+			method:     http.MethodGet,
+			path:       "/admin/items?collection=unknown",
+			token:      "secret",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "should get item",
+			method:     http.MethodGet,
+			path:       "/admin/items/2",
+			token:      "secret",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"id":2,"payload":"A","version":1,"sequence":SEQ2}`,
+		},
+		{
+			name:       "should return not found for unknown item",
+			method:     http.MethodGet,
+			path:       "/admin/items/10",
+			token:      "secret",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "should count items",
+			method:     http.MethodGet,
+			path:       "/admin/items/count",
+			token:      "secret",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"collection":"default","count":5}`,
+		},
+		{
+			name:       "should return worker pool state",
+			method:     http.MethodGet,
+			path:       "/admin/workers",
+			token:      "secret",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"workers":0,"busy":0}`,
+		},
+		{
+			name:       "should return in-flight messages",
+			method:     http.MethodGet,
+			path:       "/admin/inflight",
+			token:      "secret",
+			wantStatus: http.StatusOK,
+			wantBody:   `[{"message_id":"1","type":"Command","trace_id":"trace_id","started_at":"2022-10-01T12:00:00Z"}]`,
+		},
+		{
+			name:       "should return recent errors",
+			method:     http.MethodGet,
+			path:       "/admin/errors",
+			token:      "secret",
+			wantStatus: http.StatusOK,
+			wantBody:   `[{"message_id":"2","type":"","trace_id":"","error":"cannot process","time":"2022-10-01T12:00:00Z"}]`,
+		},
+		{
+			name:       "should not allow changing methods for reads",
+			method:     http.MethodPost,
+			path:       "/admin/items",
+			token:      "secret",
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+	}
+	items, err := repo.GetAllItems(ctx)
+	require.NoError(t, err)
+	sequences := strings.NewReplacer(
+		"SEQ2", jsonString(t, items[1].Sequence),
+		"SEQ4", jsonString(t, items[3].Sequence),
+		"SEQ5", jsonString(t, items[4].Sequence),
+	)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := adminRequest(t, httpServer.URL, tt.method, tt.path, tt.token, "")
+			defer resp.Body.Close()
+
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			if tt.wantBody != "" {
+				var body json.RawMessage
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+				assert.JSONEq(t, sequences.Replace(tt.wantBody), string(body))
+			}
+		})
+	}
+}
+
+func TestAdminServer_DumpRestore(t *testing.T) {
+	ctx := context.Background()
+	source, err := repository.NewRegistry(repository.RegistryConfig{})
+	require.NoError(t, err)
+	repo, err := source.Collection(ctx, "sessions")
+	require.NoError(t, err)
+	require.NoError(t, repo.AddItem(ctx, models.Item{ID: 1, Payload: "A", ExpiresAt: time.Now().Add(time.Hour)}))
+
+	target, err := repository.NewRegistry(repository.RegistryConfig{})
+	require.NoError(t, err)
+	require.NoError(t, target.CreateCollection(ctx, "stale"))
+
+	sourceServer := httptest.NewServer(NewAdminServer(AdminConfig{Token: "secret"}, source, NewActivity()).httpServer.Handler)
+	defer sourceServer.Close()
+	targetServer := httptest.NewServer(NewAdminServer(AdminConfig{Token: "secret"}, target, NewActivity()).httpServer.Handler)
+	defer targetServer.Close()
+	readOnlyServer := httptest.NewServer(NewAdminServer(AdminConfig{Token: "secret"}, target, NewActivity(), AdminReadOnly()).httpServer.Handler)
+	defer readOnlyServer.Close()
+	consumedServer := httptest.NewServer(NewAdminServer(AdminConfig{Token: "secret"}, target, NewActivity(), AdminChangeConsumers()).httpServer.Handler)
+	defer consumedServer.Close()
+
+	resp := adminRequest(t, sourceServer.URL, http.MethodGet, "/admin/dump", "secret", "")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var dump json.RawMessage
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&dump))
+
+	resp = adminRequest(t, readOnlyServer.URL, http.MethodPost, "/admin/restore", "secret", string(dump))
+	resp.Body.Close()
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	resp = adminRequest(t, consumedServer.URL, http.MethodPost, "/admin/restore", "secret", string(dump))
+	resp.Body.Close()
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	assert.Equal(t, []string{"stale"}, target.Collections(), "store with change consumers is not restored")
+
+	resp = adminRequest(t, targetServer.URL, http.MethodPost, "/admin/restore", "secret", "not a dump")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = adminRequest(t, targetServer.URL, http.MethodPost, "/admin/restore", "secret", string(dump))
+	resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, []string{"sessions"}, target.Collections())
+	assert.Equal(t, source.Snapshot().Collections[0].Items[0].Payload, target.Snapshot().Collections[0].Items[0].Payload)
+}
+
+func TestAdminServer_Scheduled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	registry, err := repository.NewRegistry(repository.RegistryConfig{})
+	require.NoError(t, err)
+	commandScheduler, err := scheduler.New(service.NewMockItemService(ctrl), "")
+	require.NoError(t, err)
+	notBefore := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	scheduled, err := commandScheduler.Schedule(context.Background(), &models.Command{
+		Type:      models.CommandType_RemoveItem,
+		ItemID:    1,
+		NotBefore: notBefore.UnixNano(),
+	})
+	require.NoError(t, err)
+
+	httpServer := httptest.NewServer(NewAdminServer(AdminConfig{Token: "secret"}, registry, NewActivity(), AdminScheduler(commandScheduler)).httpServer.Handler)
+	defer httpServer.Close()
+	disabledServer := httptest.NewServer(NewAdminServer(AdminConfig{Token: "secret"}, registry, NewActivity()).httpServer.Handler)
+	defer disabledServer.Close()
+
+	resp := adminRequest(t, httpServer.URL, http.MethodGet, "/admin/scheduled", "secret", "")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var pending []map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&pending))
+	require.Len(t, pending, 1)
+	assert.Equal(t, scheduled.ID, pending[0]["id"])
+	assert.Equal(t, "2030-01-01T00:00:00Z", pending[0]["not_before"])
+	assert.Equal(t, "RemoveItem", pending[0]["command"].(map[string]interface{})["type"])
+
+	resp = adminRequest(t, httpServer.URL, http.MethodDelete, "/admin/scheduled/"+scheduled.ID, "secret", "")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Empty(t, commandScheduler.List())
+
+	resp = adminRequest(t, httpServer.URL, http.MethodDelete, "/admin/scheduled/"+scheduled.ID, "secret", "")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp = adminRequest(t, disabledServer.URL, http.MethodGet, "/admin/scheduled", "secret", "")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestAdminServer_Clients(t *testing.T) {
+	registry, err := repository.NewRegistry(repository.RegistryConfig{})
+	require.NoError(t, err)
+	limiter, err := limits.New(limits.Limits{MaxItems: 10}, nil)
+	require.NoError(t, err)
+	require.NoError(t, limiter.Allow("client-1", &models.Command{Type: models.CommandType_AddItem, ItemID: 1, ItemPayload: "AB"}))
+
+	httpServer := httptest.NewServer(NewAdminServer(AdminConfig{Token: "secret"}, registry, NewActivity(), AdminLimiter(limiter)).httpServer.Handler)
+	defer httpServer.Close()
+	disabledServer := httptest.NewServer(NewAdminServer(AdminConfig{Token: "secret"}, registry, NewActivity()).httpServer.Handler)
+	defer disabledServer.Close()
+
+	resp := adminRequest(t, httpServer.URL, http.MethodGet, "/admin/clients", "secret", "")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var usage []map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&usage))
+	require.Len(t, usage, 1)
+	assert.Equal(t, "client-1", usage[0]["client_id"])
+	assert.Equal(t, float64(1), usage[0]["items"])
+	assert.Equal(t, float64(2), usage[0]["bytes"])
+	assert.Equal(t, float64(1), usage[0]["messages_total"])
+
+	resp = adminRequest(t, disabledServer.URL, http.MethodGet, "/admin/clients", "secret", "")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestAdminServer_LogLevel(t *testing.T) {
+	registry, err := repository.NewRegistry(repository.RegistryConfig{})
+	require.NoError(t, err)
+	httpServer := httptest.NewServer(NewAdminServer(AdminConfig{Token: "secret"}, registry, NewActivity()).httpServer.Handler)
+	defer httpServer.Close()
+	defer logging.SetLevel("server/repository", "")
+
+	resp := adminRequest(t, httpServer.URL, http.MethodPut, "/admin/loglevel?package=server/repository&level=debug", "secret", "")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var levels logging.Levels
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&levels))
+	assert.Equal(t, "debug", levels.Packages["server/repository"])
+	assert.Equal(t, levels.Level, levels.Packages["server"])
+
+	for _, path := range []string{"/admin/loglevel?package=server&level=loud", "/admin/loglevel?package=unknown&level=debug"} {
+		resp := adminRequest(t, httpServer.URL, http.MethodPut, path, "secret", "")
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, path)
+	}
+
+	resp = adminRequest(t, httpServer.URL, http.MethodPost, "/admin/loglevel", "secret", "")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestActivity_RecentErrors(t *testing.T) {
+	activity := NewActivity()
+	for i := 0; i < recentErrorsLimit+2; i++ {
+		activity.begin(amqp.Delivery{MessageId: jsonString(t, i)})(errors.New("error"))
+	}
+	activity.begin(amqp.Delivery{MessageId: "ok"})(nil)
+
+	errs := activity.RecentErrors()
+	require.Len(t, errs, recentErrorsLimit)
+	assert.Equal(t, jsonString(t, recentErrorsLimit+1), errs[0].MessageID)
+	assert.Equal(t, "2", errs[recentErrorsLimit-1].MessageID)
+	assert.Empty(t, activity.InFlight())
+}
+
+func adminRequest(t *testing.T, url, method, path, token, body string) *http.Response {
+	req, err := http.NewRequest(method, url+path, strings.NewReader(body))
+	require.NoError(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return resp
+}
+
+func jsonString(t *testing.T, v interface{}) string {
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	return string(data)
+}
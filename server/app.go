@@ -2,39 +2,197 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"time"
 
+	"github.com/dliakhov/bloxroutelabs/client-server-app/broker"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/logging"
 	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/limits"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/policy"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/repository"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/scheduler"
 	"github.com/dliakhov/bloxroutelabs/client-server-app/server/service"
 	"github.com/dliakhov/bloxroutelabs/client-server-app/server/workerpool"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/signing"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/tracing"
 	amqp "github.com/rabbitmq/amqp091-go"
-	log "github.com/sirupsen/logrus"
-	"google.golang.org/protobuf/proto"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var log = logging.Logger("server")
+
 const (
-	traceIDKey   = "X-Trace-ID"
+	traceIDKey  = "X-Trace-ID"
+	clientIDKey = signing.ClientIDHeader
+	// numOfWorkers is the size of the worker pool when it is not set
 	numOfWorkers = 5
+	// deadLetterReasonKey is the header of dead-lettered messages with the reason they are rejected.
+	deadLetterReasonKey = "X-Dead-Letter-Reason"
+	// costUnit is the size of the message body which costs 1 in fair queuing.
+	costUnit = 1024
+	// maxRequeueDelay limits how long limited messages are held before they are returned to the queue.
+	maxRequeueDelay = time.Minute
+)
+
+// Fair queuing keys of messages, see WorkerPoolConfig.
+const (
+	FairnessClient = "client"
+	FairnessType   = "type"
 )
 
+var tracer = otel.Tracer("github.com/dliakhov/bloxroutelabs/client-server-app/server")
+
+// ErrInvalidMessage is returned for messages which can never be processed, e.g. of unsupported content type.
+// They are moved to the dead-letter queue instead of being redelivered.
+var ErrInvalidMessage = errors.New("invalid message")
+
+// rejected reports whether the command is rejected by the storage, e.g. by its limits, by the item version or on
+// a replica, so it fails the same way every time it is redelivered.
+func rejected(err error) bool {
+	return errors.Is(err, repository.ErrCapacityExceeded) ||
+		errors.Is(err, repository.ErrVersionConflict) ||
+		errors.Is(err, repository.ErrItemNotFound) ||
+		errors.Is(err, repository.ErrCollectionNotFound) ||
+		errors.Is(err, service.ErrReadOnly)
+}
+
+// requeueError is returned for messages which should be returned to the queue after the delay.
+type requeueError struct {
+	err   error
+	delay time.Duration
+}
+
+func (e *requeueError) Error() string {
+	return e.err.Error()
+}
+
+func (e *requeueError) Unwrap() error {
+	return e.err
+}
+
 type App struct {
 	config      Configurations
 	conn        *amqp.Connection
 	itemService service.ItemService
 	workerPool  *workerpool.WorkerPool
+	activity    *Activity
+	// ch and prefetch are guarded by mx, so prefetch can be changed while messages are consumed
+	ch       *amqp.Channel
+	prefetch int
+	mx       sync.Mutex
+	// scheduler holds delayed commands, they are rejected when it is not set
+	scheduler *scheduler.Scheduler
+	// verifier authenticates signed messages, they are not verified when it is not set
+	verifier *signing.Verifier
+	// policy authorizes commands of clients, all commands are allowed when it is not set
+	policy *policy.Engine
+	// limiter applies rate limits and quotas of clients, messages are not limited when it is not set
+	limiter *limits.Limiter
+	// reply sends reply to the ReplyTo queue of the message
+	reply func(ctx context.Context, d amqp.Delivery, reply *models.Reply) error
+	// deadLetter moves the message to the dead-letter queue with the reason of rejection
+	deadLetter func(d amqp.Delivery, reason error) error
+}
+
+type AppOption func(a *App)
+
+// WithActivity makes the app report processed messages to the activity shared with other apps.
+func WithActivity(activity *Activity) AppOption {
+	return func(a *App) {
+		a.activity = activity
+	}
+}
+
+// WithScheduler makes the app pass delayed commands to the scheduler.
+func WithScheduler(s *scheduler.Scheduler) AppOption {
+	return func(a *App) {
+		a.scheduler = s
+	}
+}
+
+// WithVerifier makes the app verify signatures of messages.
+func WithVerifier(v *signing.Verifier) AppOption {
+	return func(a *App) {
+		a.verifier = v
+	}
+}
+
+// WithPolicy makes the app reject commands which are not allowed by the policy.
+func WithPolicy(e *policy.Engine) AppOption {
+	return func(a *App) {
+		a.policy = e
+	}
+}
+
+// WithLimiter makes the app limit messages of clients.
+func WithLimiter(l *limits.Limiter) AppOption {
+	return func(a *App) {
+		a.limiter = l
+	}
 }
 
-func NewApp(config Configurations, itemService service.ItemService) *App {
-	return &App{
+func NewApp(config Configurations, itemService service.ItemService, opts ...AppOption) *App {
+	a := &App{
 		config:      config,
 		itemService: itemService,
-		workerPool:  workerpool.NewWorkerPool(numOfWorkers),
+		activity:    NewActivity(),
+		prefetch:    config.RabbitMQConfig.Prefetch,
+	}
+	var poolOpts []workerpool.Option
+	// messages are queued when they are ordered by clients or priorities
+	if pool := config.WorkerPoolConfig; pool.Fairness != "" || config.RabbitMQConfig.MaxPriority > 0 {
+		poolOpts = append(poolOpts, workerpool.WithFairQueue(pool.Quantum, pool.MaxQueued))
+	}
+	a.workerPool = workerpool.NewWorkerPool(workersOf(config.WorkerPoolConfig), poolOpts...)
+	a.reply = a.publishReply
+	a.deadLetter = a.publishDeadLetter
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// SetWorkers changes the number of messages processed at the same time, zero sets the default number.
+func (a *App) SetWorkers(workers int) {
+	a.workerPool.Resize(workersOf(WorkerPoolConfig{Workers: workers}))
+}
+
+// SetPrefetch changes the limit of messages delivered to the app and not acked yet, zero means no limit.
+func (a *App) SetPrefetch(prefetch int) error {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+
+	if a.ch != nil {
+		if err := a.ch.Qos(prefetch, 0, true); err != nil {
+			return err
+		}
 	}
+	a.prefetch = prefetch
+	return nil
+}
+
+func workersOf(config WorkerPoolConfig) int {
+	if config.Workers <= 0 {
+		return numOfWorkers
+	}
+	return config.Workers
+}
+
+// Activity returns messages being processed and recent errors of the app.
+func (a *App) Activity() *Activity {
+	return a.activity
 }
 
 func (a *App) Init() error {
 	var err error
-	a.conn, err = amqp.Dial(fmt.Sprintf("amqp://%s:%s@%s", a.config.RabbitMQConfig.User, a.config.RabbitMQConfig.Password, a.config.RabbitMQConfig.URL))
+	a.conn, err = a.config.RabbitMQConfig.Dial()
 	if err != nil {
 		return err
 	}
@@ -48,17 +206,48 @@ func (a *App) Start() error {
 	}
 
 	queue, err := ch.QueueDeclare(
-		a.config.RabbitMQConfig.QueueName,
+		a.queueName(),
 		true,
 		false,
 		false,
 		false,
-		nil,
+		broker.QueueArgs(a.config.RabbitMQConfig.MaxPriority),
 	)
 	if err != nil {
 		return err
 	}
 
+	// the limit of the channel is used, since the limit of the consumer cannot be changed while it consumes
+	a.mx.Lock()
+	a.ch = ch
+	if a.prefetch > 0 {
+		err = ch.Qos(a.prefetch, 0, true)
+	}
+	a.mx.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if sharding := a.config.ShardingConfig; sharding.Shards > 0 {
+		err = ch.ExchangeDeclare(
+			sharding.Exchange,
+			amqp.ExchangeDirect,
+			true,
+			false,
+			false,
+			false,
+			nil,
+		)
+		if err != nil {
+			return err
+		}
+
+		if err := ch.QueueBind(queue.Name, models.ShardRoutingKey(sharding.Shard), sharding.Exchange, false, nil); err != nil {
+			return err
+		}
+		log.Infof("Consuming shard %d of %d", sharding.Shard, sharding.Shards)
+	}
+
 	msgs, err := ch.Consume(
 		queue.Name,
 		"",
@@ -68,66 +257,466 @@ func (a *App) Start() error {
 		false,
 		nil,
 	)
+	if err != nil {
+		return err
+	}
 
 	a.workerPool.Start()
+	a.activity.setWorkerPool(a.workerPool)
+	// deliveries are closed by Cleanup, so no task is submitted after the pool is stopped
+	defer a.workerPool.Quit()
 
 	log.Info("Application is started")
 	for d := range msgs {
-		a.workerPool.SubmitTask(func() {
-			if err := a.ProcessMessage(d); err != nil {
-				log.Errorf("Cannot process message: %v", err)
-			} else {
-				d.Ack(false)
-			}
+		d := d
+		key, priority := a.fairKey(d), a.priority(d)
+		// the span of waiting for a worker
+		_, queued := tracer.Start(tracing.Extract(context.Background(), d.Headers), "queue", trace.WithAttributes(
+			attribute.String("workerpool.key", key),
+			attribute.Int("workerpool.priority", int(priority)),
+		))
+		a.workerPool.Submit(key, priority, (len(d.Body)+costUnit-1)/costUnit, func() {
+			queued.End()
+			a.handle(d)
 		})
 	}
 
 	return nil
 }
 
-func (a *App) ProcessMessage(d amqp.Delivery) error {
-	var traceID string
+// fairKey returns the key the message is queued by. The client id is not verified yet, so a client sending
+// messages with another id can take the turns of that client, but it is still limited and authorized by its own key.
+func (a *App) fairKey(d amqp.Delivery) string {
+	switch a.config.WorkerPoolConfig.Fairness {
+	case FairnessClient:
+		clientID, _ := d.Headers[clientIDKey].(string)
+		return clientID
+	case FairnessType:
+		return d.Type
+	default:
+		return ""
+	}
+}
+
+// priority returns the priority of the message, priorities above the max priority of the queue are treated
+// as the max one like the broker does.
+func (a *App) priority(d amqp.Delivery) uint8 {
+	if maxPriority := a.config.RabbitMQConfig.MaxPriority; d.Priority > maxPriority {
+		return maxPriority
+	}
+	return d.Priority
+}
+
+// handle processes the message and acks it. Invalid messages are acked after they are moved to the dead-letter
+// queue, other failed messages are not acked.
+func (a *App) handle(d amqp.Delivery) {
+	done := a.activity.begin(d)
+	err := a.ProcessMessage(d)
+	done(err)
+
+	logger := log.WithField(traceIDKey, traceIDOf(tracing.Extract(context.Background(), d.Headers), d))
+	var requeue *requeueError
+	switch {
+	case err == nil:
+		d.Ack(false)
+	case errors.As(err, &requeue):
+		logger.Warningf("Message %s is requeued in %s: %v", d.MessageId, requeue.delay, err)
+		// the worker is not blocked, the message is held unacked until it is returned to the queue
+		time.AfterFunc(requeue.delay, func() {
+			if err := d.Nack(false, true); err != nil {
+				logger.Errorf("Cannot requeue message %s: %v", d.MessageId, err)
+			}
+		})
+	case errors.Is(err, ErrInvalidMessage), rejected(err):
+		if err := a.deadLetter(d, err); err != nil {
+			logger.Errorf("Cannot move message %s to the dead-letter queue: %v", d.MessageId, err)
+			return
+		}
+		logger.Warningf("Message %s is moved to the dead-letter queue: %v", d.MessageId, err)
+		d.Ack(false)
+	default:
+		logger.Errorf("Cannot process message %s: %v", d.MessageId, err)
+	}
+}
+
+// ProcessMessage processes the message in the span which continues the trace of its traceparent header.
+// X-Trace-ID header is used as the trace id of logs, the id of the trace is used when it is not set.
+func (a *App) ProcessMessage(d amqp.Delivery) (err error) {
+	messageType := d.Type
+	if messageType == "" {
+		messageType = models.MessageTypeCommand
+	}
+	ctx, span := tracer.Start(tracing.Extract(context.Background(), d.Headers), "process "+messageType,
+		trace.WithSpanKind(trace.SpanKindConsumer), trace.WithAttributes(
+			semconv.MessagingSystem("rabbitmq"),
+			semconv.MessagingOperationProcess,
+			semconv.MessagingSourceName(a.queueName()),
+			semconv.MessagingMessageID(d.MessageId),
+			semconv.MessagingMessagePayloadSizeBytes(len(d.Body)),
+		))
+	defer func() { tracing.End(span, err) }()
+
+	traceID := traceIDOf(ctx, d)
+	logger := log.WithField(traceIDKey, traceID)
 	defer func() {
 		if err := recover(); err != nil {
-			log.WithField(traceIDKey, traceID).Errorf("Panic occured: %v", err)
+			logger.Errorf("Panic occured: %v", err)
 		}
 	}()
 
-	command := new(models.Command)
-	err := proto.Unmarshal(d.Body, command)
+	if traceIDVal, ok := d.Headers[traceIDKey]; ok {
+		if _, ok := traceIDVal.(string); !ok {
+			logger.Warningf("Trace id has no correct type: %v", traceIDVal)
+		}
+	}
+	if traceID == "" {
+		logger.Warning("Trace id is not set")
+	}
+
+	clientID, err := a.authenticate(d)
+	if err != nil {
+		logger.Errorf("Cannot authenticate message: %v", err)
+		return fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+	}
+	logger = logger.WithField(clientIDKey, clientID)
+	if clientID != "" {
+		span.SetAttributes(attribute.String("client.id", clientID))
+	}
+
+	ctx = context.WithValue(ctx, traceIDKey, traceID)
+	ctx = context.WithValue(ctx, clientIDKey, clientID)
+
+	switch d.Type {
+	case "", models.MessageTypeCommand:
+		command := new(models.Command)
+		if err := models.Unmarshal(d.ContentType, d.Body, command); err != nil {
+			logger.Errorf("Cannot unmarshal message: %v", err)
+			return fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+		}
+		if err := a.admit(ctx, d, command); err != nil {
+			return err
+		}
+
+		err = a.processCommand(ctx, d, command)
+	case models.MessageTypeEnvelope:
+		envelope := new(models.Envelope)
+		if err := models.Unmarshal(d.ContentType, d.Body, envelope); err != nil {
+			logger.Errorf("Cannot unmarshal message: %v", err)
+			return fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+		}
+		command, commandErr := envelope.Command()
+		if commandErr != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidMessage, commandErr)
+		}
+		if clientID != "" && envelope.ClientID != "" && envelope.ClientID != clientID {
+			return fmt.Errorf("%w: envelope of client %s is signed by client %s", ErrInvalidMessage, envelope.ClientID, clientID)
+		}
+
+		if err := a.admit(ctx, d, command); err != nil {
+			return err
+		}
+
+		logger.Debugf("Command of client %s issued at %s",
+			envelope.ClientID, time.Unix(0, envelope.IssuedAt).UTC().Format(time.RFC3339Nano))
+		err = a.processCommand(ctx, d, command)
+	case models.MessageTypeBatch:
+		batch := new(models.Batch)
+		if err := models.Unmarshal(d.ContentType, d.Body, batch); err != nil {
+			logger.Errorf("Cannot unmarshal message: %v", err)
+			return fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+		}
+
+		for _, command := range batch.Commands {
+			if command.NotBefore != 0 {
+				return fmt.Errorf("%w: commands of batch cannot be delayed", ErrInvalidMessage)
+			}
+		}
+		// batch is applied atomically, so it is rejected when any command is denied
+		if err := a.admit(ctx, d, batch.Commands...); err != nil {
+			return err
+		}
+
+		err = a.processBatch(ctx, d, batch)
+	default:
+		err = fmt.Errorf("%w: unknown message type: %s", ErrInvalidMessage, d.Type)
+	}
 	if err != nil {
-		log.Errorf("Cannot unmarshal message: %v", err)
+		logger.Errorf("Cannot process message: %v", err)
 		return err
 	}
 
-	if d.Headers != nil {
-		traceIDVal, ok := d.Headers[traceIDKey]
-		if !ok {
-			log.Warning("Trace id is not set")
+	logger.Debugf("Message ID: %s processed successfully", d.MessageId)
+	return nil
+}
+
+// traceIDOf returns the trace id of logs of the message: X-Trace-ID header of older clients or the id of the trace
+// of ctx.
+func traceIDOf(ctx context.Context, d amqp.Delivery) string {
+	if traceID, ok := d.Headers[traceIDKey].(string); ok {
+		return traceID
+	}
+	return tracing.TraceID(ctx)
+}
+
+// contextLogger returns the logger with the trace id and the client id of the message processed in ctx.
+func contextLogger(ctx context.Context) *logrus.Entry {
+	return log.WithField(traceIDKey, ctx.Value(traceIDKey)).WithField(clientIDKey, ctx.Value(clientIDKey))
+}
+
+// authenticate verifies the signature of the message and returns the client id. The client id is empty
+// for messages which are not verified.
+func (a *App) authenticate(d amqp.Delivery) (string, error) {
+	if a.verifier == nil {
+		return "", nil
+	}
+
+	clientID, err := a.verifier.Verify(d)
+	if errors.Is(err, signing.ErrUnsigned) && !a.config.AuthConfig.Required {
+		return "", nil
+	}
+	return clientID, err
+}
+
+// admit checks the commands of the message by the policy and the limits of the client. Rejected message is answered
+// with the error when it has ReplyTo queue, so the requester does not wait for the reply, and is moved to
+// the dead-letter queue. Message over the limits with requeue action is returned to the queue when the limits allow it,
+// unless its signature expires before.
+// Quota used by the admitted message is refunded when its commands are not processed.
+func (a *App) admit(ctx context.Context, d amqp.Delivery, commands ...*models.Command) error {
+	clientID, _ := ctx.Value(clientIDKey).(string)
+	logger := contextLogger(ctx)
+
+	var err error
+	if a.policy != nil {
+		for _, command := range commands {
+			if err = a.policy.Authorize(clientID, command); err != nil {
+				logger.Warningf("Command is denied: %v", err)
+				break
+			}
+		}
+	}
+
+	if err == nil && a.limiter != nil {
+		var exceeded *limits.ExceededError
+		err = a.limiter.Allow(clientID, commands...)
+		if errors.As(err, &exceeded) && exceeded.Action == limits.ActionRequeue && !a.expiresBefore(d, exceeded.RetryAfter) {
+			delay := exceeded.RetryAfter
+			if delay > maxRequeueDelay {
+				delay = maxRequeueDelay
+			}
+			return &requeueError{err: err, delay: delay}
+		}
+		if err != nil {
+			logger.Warningf("Message is rejected: %v", err)
 		}
+	}
 
-		traceID, ok = traceIDVal.(string)
-		if !ok {
-			log.Warningf("Trace id has no correct type: %v", traceIDVal)
+	if err == nil {
+		return nil
+	}
+	if d.ReplyTo != "" {
+		if err := a.reply(ctx, d, &models.Reply{Error: err.Error()}); err != nil {
+			return err
 		}
 	}
+	return fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+}
+
+// expiresBefore reports whether the signed message falls out of the window of the verifier before the delay, so it would
+// be rejected as expired when it is redelivered.
+func (a *App) expiresBefore(d amqp.Delivery, delay time.Duration) bool {
+	if a.verifier == nil {
+		return false
+	}
+	expiresIn, signed := a.verifier.ExpiresIn(d)
+	return signed && expiresIn < delay
+}
+
+// refund returns the quota used by the commands to the client when they are not processed.
+func (a *App) refund(ctx context.Context, err error, commands ...*models.Command) {
+	if err == nil || a.limiter == nil {
+		return
+	}
+	clientID, _ := ctx.Value(clientIDKey).(string)
+	a.limiter.Refund(clientID, commands...)
+}
+
+func (a *App) processCommand(ctx context.Context, d amqp.Delivery, command *models.Command) (err error) {
+	if d.ReplyTo != "" {
+		return a.processWithReply(ctx, d, command)
+	}
+	defer func() { a.refund(ctx, err, command) }()
+
+	if delayed(command) {
+		_, err = a.schedule(ctx, command)
+		return err
+	}
+	return a.itemService.ProcessItemCommand(ctx, command)
+}
+
+// schedule passes the delayed command to the scheduler and returns its schedule id.
+func (a *App) schedule(ctx context.Context, command *models.Command) (string, error) {
+	if a.scheduler == nil {
+		return "", fmt.Errorf("%w: delayed commands are not supported by this server", ErrInvalidMessage)
+	}
+
+	scheduled, err := a.scheduler.Schedule(ctx, command)
+	if errors.Is(err, scheduler.ErrNotSchedulable) {
+		return "", fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+	}
+	if err != nil {
+		return "", err
+	}
+	return scheduled.ID, nil
+}
 
-	ctx := context.WithValue(context.Background(), traceIDKey, traceID)
+// delayed reports whether NotBefore of the command is in the future.
+func delayed(command *models.Command) bool {
+	return command.NotBefore != 0 && time.Unix(0, command.NotBefore).After(time.Now())
+}
+
+// processWithReply processes the command and sends the result to the ReplyTo queue of the message.
+// Processing error is sent in the reply, so the requester handles it.
+func (a *App) processWithReply(ctx context.Context, d amqp.Delivery, command *models.Command) error {
+	var items []models.Item
+	var scheduleID string
+	var err error
+	switch {
+	case delayed(command):
+		scheduleID, err = a.schedule(ctx, command)
+	case command.Type == models.CommandType_GetItem, command.Type == models.CommandType_GetAllItems:
+		items, err = a.itemService.QueryItems(ctx, command)
+	default:
+		err = a.itemService.ProcessItemCommand(ctx, command)
+	}
+	a.refund(ctx, err, command)
 
-	err = a.itemService.ProcessItemCommand(ctx, command)
+	reply := &models.Reply{ScheduleID: scheduleID}
+	for _, item := range items {
+		reply.Items = append(reply.Items, models.NewItemRecord(item))
+	}
 	if err != nil {
-		log.WithField(traceIDKey, traceID).Errorf("Cannot process message: %v", err)
+		contextLogger(ctx).Errorf("Cannot process message: %v", err)
+		reply.Error = err.Error()
+	}
+
+	return a.reply(ctx, d, reply)
+}
+
+func (a *App) processBatch(ctx context.Context, d amqp.Delivery, batch *models.Batch) error {
+	items, err := a.itemService.ProcessBatch(ctx, batch)
+	a.refund(ctx, err, batch.Commands...)
+	if d.ReplyTo == "" {
 		return err
 	}
 
-	log.Infof("Message ID: %s processed successfully\n", d.MessageId)
-	return nil
+	// processing error is sent in the reply like for commands, so the requester handles it
+	reply := &models.Reply{}
+	for _, item := range items {
+		reply.Items = append(reply.Items, models.NewItemRecord(item))
+	}
+	if err != nil {
+		contextLogger(ctx).Errorf("Cannot process message: %v", err)
+		reply.Error = err.Error()
+	}
+	return a.reply(ctx, d, reply)
 }
 
-func (a *App) Cleanup() error {
-	if a.workerPool != nil {
-		a.workerPool.Quit()
+func (a *App) publishReply(ctx context.Context, d amqp.Delivery, reply *models.Reply) error {
+	ch, err := a.conn.Channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	// reply is encoded like the message, which is already decoded, so its content type is supported
+	contentType, err := models.ParseContentType(d.ContentType)
+	if err != nil {
+		return err
+	}
+	body, err := models.Marshal(contentType, reply)
+	if err != nil {
+		return err
+	}
+
+	headers := amqp.Table{
+		traceIDKey: ctx.Value(traceIDKey),
 	}
+	tracing.Inject(ctx, headers)
+	return ch.PublishWithContext(ctx,
+		"",
+		d.ReplyTo,
+		false,
+		false,
+		amqp.Publishing{
+			Headers:       headers,
+			ContentType:   contentType,
+			Type:          models.MessageTypeReply,
+			CorrelationId: d.CorrelationId,
+			Body:          body,
+		})
+}
+
+// publishDeadLetter publishes the message with its properties to the dead-letter queue.
+func (a *App) publishDeadLetter(d amqp.Delivery, reason error) error {
+	ch, err := a.conn.Channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	queue, err := ch.QueueDeclare(
+		a.deadLetterQueueName(),
+		true,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		return err
+	}
+
+	headers := amqp.Table{}
+	for key, value := range d.Headers {
+		headers[key] = value
+	}
+	headers[deadLetterReasonKey] = reason.Error()
+
+	return ch.PublishWithContext(context.Background(),
+		"",
+		queue.Name,
+		false,
+		false,
+		amqp.Publishing{
+			Headers:       headers,
+			ContentType:   d.ContentType,
+			DeliveryMode:  amqp.Persistent,
+			CorrelationId: d.CorrelationId,
+			ReplyTo:       d.ReplyTo,
+			MessageId:     d.MessageId,
+			Timestamp:     d.Timestamp,
+			Type:          d.Type,
+			Body:          d.Body,
+		})
+}
+
+func (a *App) deadLetterQueueName() string {
+	if a.config.RabbitMQConfig.DeadLetterQueue != "" {
+		return a.config.RabbitMQConfig.DeadLetterQueue
+	}
+	return a.queueName() + ".dead-letter"
+}
+
+// queueName returns the queue of the shard when sharding is enabled.
+func (a *App) queueName() string {
+	if sharding := a.config.ShardingConfig; sharding.Shards > 0 {
+		return fmt.Sprintf("%s.%s", a.config.RabbitMQConfig.QueueName, models.ShardRoutingKey(sharding.Shard))
+	}
+	return a.config.RabbitMQConfig.QueueName
+}
+
+func (a *App) Cleanup() error {
 	if a.conn != nil {
 		return a.conn.Close()
 	}
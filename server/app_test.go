@@ -1,13 +1,29 @@
 package server
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/limits"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/policy"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/repository"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/scheduler"
 	service "github.com/dliakhov/bloxroutelabs/client-server-app/server/service"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/signing"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/tracing"
 	"github.com/golang/mock/gomock"
 	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"google.golang.org/protobuf/proto"
 )
 
@@ -121,3 +137,967 @@ func TestApp_ProcessMessage(t *testing.T) {
 		})
 	}
 }
+
+func TestApp_ProcessMessage_TraceContext(t *testing.T) {
+	if _, err := tracing.Init("server", tracing.Config{}); err != nil {
+		t.Fatal(err)
+	}
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	body, err := proto.Marshal(&models.Command{Type: models.CommandType_GetAllItems})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var traceIDs []interface{}
+	itemService := service.NewMockItemService(ctrl)
+	itemService.EXPECT().ProcessItemCommand(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, _ interface{}) error {
+			traceIDs = append(traceIDs, ctx.Value(traceIDKey))
+			return nil
+		}).Times(2)
+	a := NewApp(Configurations{}, itemService)
+
+	traceparent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	if err := a.ProcessMessage(amqp.Delivery{Body: body, Headers: amqp.Table{"traceparent": traceparent}}); err != nil {
+		t.Fatalf("Error occured: %v", err)
+	}
+	// X-Trace-ID of older clients is still logged
+	headers := amqp.Table{"traceparent": traceparent, "X-Trace-ID": "trace_id"}
+	if err := a.ProcessMessage(amqp.Delivery{Body: body, Headers: headers}); err != nil {
+		t.Fatalf("Error occured: %v", err)
+	}
+
+	if want := []interface{}{"4bf92f3577b34da6a3ce929d0e0e4736", "trace_id"}; !reflect.DeepEqual(traceIDs, want) {
+		t.Errorf("Trace ids = %v, want %v", traceIDs, want)
+	}
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("Spans = %d, want 2", len(spans))
+	}
+	for _, span := range spans {
+		if span.Name() != "process Command" || span.Parent().SpanID().String() != "00f067aa0ba902b7" ||
+			span.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("Span %s of parent %s does not continue the trace", span.Name(), span.Parent().SpanID())
+		}
+	}
+}
+
+func TestApp_ProcessMessage_MessageTypes(t *testing.T) {
+	batch := &models.Batch{Commands: []*models.Command{
+		{Type: models.CommandType_AddItem, ItemID: 1, ItemPayload: "A"},
+		{Type: models.CommandType_RemoveItem, ItemID: 2},
+	}}
+	batchBodyBytes, err := proto.Marshal(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		itemService func(ctrl *gomock.Controller) service.ItemService
+		d           amqp.Delivery
+		wantErr     bool
+	}{
+		{
+			name: "should process batch",
+			itemService: func(ctrl *gomock.Controller) service.ItemService {
+				itemService := service.NewMockItemService(ctrl)
+				itemService.EXPECT().ProcessBatch(gomock.Any(), protoEq{batch}).Return(nil, nil)
+				return itemService
+			},
+			d: amqp.Delivery{
+				Type: models.MessageTypeBatch,
+				Body: batchBodyBytes,
+			},
+		},
+		{
+			name: "should return error when batch is not processed",
+			itemService: func(ctrl *gomock.Controller) service.ItemService {
+				itemService := service.NewMockItemService(ctrl)
+				itemService.EXPECT().ProcessBatch(gomock.Any(), protoEq{batch}).Return(nil, errors.New("cannot process batch"))
+				return itemService
+			},
+			d: amqp.Delivery{
+				Type: models.MessageTypeBatch,
+				Body: batchBodyBytes,
+			},
+			wantErr: true,
+		},
+		{
+			name: "should return error when message type is unknown",
+			itemService: func(ctrl *gomock.Controller) service.ItemService {
+				return service.NewMockItemService(ctrl)
+			},
+			d: amqp.Delivery{
+				Type: "Unknown",
+				Body: batchBodyBytes,
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			a := NewApp(Configurations{}, tt.itemService(ctrl))
+			err := a.ProcessMessage(tt.d)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Error occured: %v, wantErr = %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApp_ProcessMessage_Reply(t *testing.T) {
+	commandBody := func(command *models.Command) []byte {
+		body, err := proto.Marshal(command)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return body
+	}
+	getAllItems := &models.Command{Type: models.CommandType_GetAllItems, Collection: "sessions"}
+	addItem := &models.Command{Type: models.CommandType_AddItem, ItemID: 1, ItemPayload: "A"}
+	batch := &models.Batch{Commands: []*models.Command{addItem, getAllItems}}
+	batchBody, err := proto.Marshal(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		itemService func(ctrl *gomock.Controller) service.ItemService
+		d           amqp.Delivery
+		wantReply   *models.Reply
+	}{
+		{
+			name: "should reply with found items",
+			itemService: func(ctrl *gomock.Controller) service.ItemService {
+				itemService := service.NewMockItemService(ctrl)
+				itemService.EXPECT().QueryItems(gomock.Any(), protoEq{getAllItems}).
+					Return([]models.Item{{ID: 1, Payload: "A", Version: 1, Sequence: 10}}, nil)
+				return itemService
+			},
+			d: amqp.Delivery{Body: commandBody(getAllItems), ReplyTo: "replies"},
+			wantReply: &models.Reply{Items: []*models.ItemRecord{
+				{ID: 1, Payload: "A", Version: 1, Sequence: 10},
+			}},
+		},
+		{
+			name: "should reply with error",
+			itemService: func(ctrl *gomock.Controller) service.ItemService {
+				itemService := service.NewMockItemService(ctrl)
+				itemService.EXPECT().ProcessItemCommand(gomock.Any(), protoEq{addItem}).Return(errors.New("cannot add item"))
+				return itemService
+			},
+			d:         amqp.Delivery{Body: commandBody(addItem), ReplyTo: "replies"},
+			wantReply: &models.Reply{Error: "cannot add item"},
+		},
+		{
+			name: "should reply with found items of batch",
+			itemService: func(ctrl *gomock.Controller) service.ItemService {
+				itemService := service.NewMockItemService(ctrl)
+				itemService.EXPECT().ProcessBatch(gomock.Any(), protoEq{batch}).
+					Return([]models.Item{{ID: 1, Payload: "A", Version: 1, Sequence: 10}}, nil)
+				return itemService
+			},
+			d: amqp.Delivery{Type: models.MessageTypeBatch, Body: batchBody, ReplyTo: "replies"},
+			wantReply: &models.Reply{Items: []*models.ItemRecord{
+				{ID: 1, Payload: "A", Version: 1, Sequence: 10},
+			}},
+		},
+		{
+			name: "should reply with error of batch",
+			itemService: func(ctrl *gomock.Controller) service.ItemService {
+				itemService := service.NewMockItemService(ctrl)
+				itemService.EXPECT().ProcessBatch(gomock.Any(), protoEq{batch}).Return(nil, repository.ErrCapacityExceeded)
+				return itemService
+			},
+			d:         amqp.Delivery{Type: models.MessageTypeBatch, Body: batchBody, ReplyTo: "replies"},
+			wantReply: &models.Reply{Error: repository.ErrCapacityExceeded.Error()},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			a := NewApp(Configurations{}, tt.itemService(ctrl))
+			var gotReply *models.Reply
+			a.reply = func(ctx context.Context, d amqp.Delivery, reply *models.Reply) error {
+				gotReply = reply
+				return nil
+			}
+
+			if err := a.ProcessMessage(tt.d); err != nil {
+				t.Errorf("Error occured: %v", err)
+			}
+			if !proto.Equal(tt.wantReply, gotReply) {
+				t.Errorf("Reply = %v, want %v", gotReply, tt.wantReply)
+			}
+		})
+	}
+}
+
+func TestApp_ProcessMessage_ContentTypes(t *testing.T) {
+	addItem := &models.Command{Type: models.CommandType_AddItem, ItemID: 1, ItemPayload: "A"}
+
+	tests := []struct {
+		name        string
+		itemService func(ctrl *gomock.Controller) service.ItemService
+		d           amqp.Delivery
+		wantErr     error
+	}{
+		{
+			name: "should process json command",
+			itemService: func(ctrl *gomock.Controller) service.ItemService {
+				itemService := service.NewMockItemService(ctrl)
+				itemService.EXPECT().ProcessItemCommand(gomock.Any(), protoEq{addItem}).Return(nil)
+				return itemService
+			},
+			d: amqp.Delivery{
+				ContentType: "application/json; charset=utf-8",
+				Body:        []byte(`{"type": "AddItem", "ItemID": 1, "ItemPayload": "A"}`),
+			},
+		},
+		{
+			name: "should process json batch",
+			itemService: func(ctrl *gomock.Controller) service.ItemService {
+				itemService := service.NewMockItemService(ctrl)
+				itemService.EXPECT().ProcessBatch(gomock.Any(), protoEq{&models.Batch{Commands: []*models.Command{addItem}}}).Return(nil, nil)
+				return itemService
+			},
+			d: amqp.Delivery{
+				ContentType: models.ContentTypeJSON,
+				Type:        models.MessageTypeBatch,
+				Body:        []byte(`{"Commands": [{"type": "AddItem", "ItemID": 1, "ItemPayload": "A"}]}`),
+			},
+		},
+		{
+			name: "should reject unknown content type",
+			itemService: func(ctrl *gomock.Controller) service.ItemService {
+				return service.NewMockItemService(ctrl)
+			},
+			d: amqp.Delivery{
+				ContentType: "application/xml",
+				Body:        []byte(`<Command/>`),
+			},
+			wantErr: ErrInvalidMessage,
+		},
+		{
+			name: "should reject malformed json",
+			itemService: func(ctrl *gomock.Controller) service.ItemService {
+				return service.NewMockItemService(ctrl)
+			},
+			d: amqp.Delivery{
+				ContentType: models.ContentTypeJSON,
+				Body:        []byte(`{"type": `),
+			},
+			wantErr: ErrInvalidMessage,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			a := NewApp(Configurations{}, tt.itemService(ctrl))
+			err := a.ProcessMessage(tt.d)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Error occured: %v, wantErr = %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApp_ProcessMessage_JSONReply(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	itemService := service.NewMockItemService(ctrl)
+	itemService.EXPECT().QueryItems(gomock.Any(), gomock.Any()).Return([]models.Item{{ID: 1, Payload: "A", Version: 1}}, nil)
+
+	a := NewApp(Configurations{}, itemService)
+	var gotReply *models.Reply
+	a.reply = func(ctx context.Context, d amqp.Delivery, reply *models.Reply) error {
+		gotReply = reply
+		return nil
+	}
+
+	err := a.ProcessMessage(amqp.Delivery{
+		ContentType: models.ContentTypeJSON,
+		Body:        []byte(`{"type": "GetItem", "ItemID": 1}`),
+		ReplyTo:     "replies",
+	})
+	if err != nil {
+		t.Fatalf("Error occured: %v", err)
+	}
+
+	body, err := models.Marshal(models.ContentTypeJSON, gotReply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := new(models.Reply)
+	if err := models.Unmarshal(models.ContentTypeJSON, body, decoded); err != nil || len(decoded.Items) != 1 {
+		t.Errorf("Reply %s is not decoded: %v", body, err)
+	}
+}
+
+func TestApp_ProcessMessage_Envelope(t *testing.T) {
+	envelopeBody := func(envelope *models.Envelope) []byte {
+		body, err := proto.Marshal(envelope)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return body
+	}
+	removeItem := &models.Command{Type: models.CommandType_RemoveItem, ItemID: 1, ExpectedVersion: 2, Collection: "sessions"}
+	envelope, err := models.NewEnvelope(removeItem, "client-1", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		itemService func(ctrl *gomock.Controller) service.ItemService
+		d           amqp.Delivery
+		wantErr     error
+	}{
+		{
+			name: "should process command of envelope",
+			itemService: func(ctrl *gomock.Controller) service.ItemService {
+				itemService := service.NewMockItemService(ctrl)
+				itemService.EXPECT().ProcessItemCommand(gomock.Any(), protoEq{removeItem}).Return(nil)
+				return itemService
+			},
+			d: amqp.Delivery{Type: models.MessageTypeEnvelope, Body: envelopeBody(envelope)},
+		},
+		{
+			name: "should return processing error of envelope command",
+			itemService: func(ctrl *gomock.Controller) service.ItemService {
+				itemService := service.NewMockItemService(ctrl)
+				itemService.EXPECT().ProcessItemCommand(gomock.Any(), protoEq{removeItem}).Return(repository.ErrVersionConflict)
+				return itemService
+			},
+			d:       amqp.Delivery{Type: models.MessageTypeEnvelope, Body: envelopeBody(envelope)},
+			wantErr: repository.ErrVersionConflict,
+		},
+		{
+			name: "should process json envelope",
+			itemService: func(ctrl *gomock.Controller) service.ItemService {
+				itemService := service.NewMockItemService(ctrl)
+				itemService.EXPECT().ProcessItemCommand(gomock.Any(), protoEq{removeItem}).Return(nil)
+				return itemService
+			},
+			d: amqp.Delivery{
+				Type:        models.MessageTypeEnvelope,
+				ContentType: models.ContentTypeJSON,
+				Body:        []byte(`{"SchemaVersion": 1, "RemoveItem": {"Collection": "sessions", "ItemID": 1, "ExpectedVersion": 2}}`),
+			},
+		},
+		{
+			name: "should reject unsupported schema version",
+			itemService: func(ctrl *gomock.Controller) service.ItemService {
+				return service.NewMockItemService(ctrl)
+			},
+			d: amqp.Delivery{
+				Type: models.MessageTypeEnvelope,
+				Body: envelopeBody(&models.Envelope{
+					SchemaVersion: 2,
+					Payload:       &models.Envelope_GetAllItems{GetAllItems: &models.GetAllItemsCommand{}},
+				}),
+			},
+			wantErr: ErrInvalidMessage,
+		},
+		{
+			name: "should reject envelope without payload",
+			itemService: func(ctrl *gomock.Controller) service.ItemService {
+				return service.NewMockItemService(ctrl)
+			},
+			d:       amqp.Delivery{Type: models.MessageTypeEnvelope, Body: envelopeBody(&models.Envelope{SchemaVersion: 1})},
+			wantErr: ErrInvalidMessage,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			a := NewApp(Configurations{}, tt.itemService(ctrl))
+			err := a.ProcessMessage(tt.d)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Error occured: %v, wantErr = %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApp_ProcessMessage_Delayed(t *testing.T) {
+	notBefore := time.Now().Add(time.Hour).UnixNano()
+	removeItem := &models.Command{Type: models.CommandType_RemoveItem, ItemID: 1, NotBefore: notBefore}
+	body, err := proto.Marshal(removeItem)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	itemService := service.NewMockItemService(ctrl)
+	commandScheduler, err := scheduler.New(itemService, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should schedule delayed command", func(t *testing.T) {
+		a := NewApp(Configurations{}, itemService, WithScheduler(commandScheduler))
+		var gotReply *models.Reply
+		a.reply = func(ctx context.Context, d amqp.Delivery, reply *models.Reply) error {
+			gotReply = reply
+			return nil
+		}
+
+		if err := a.ProcessMessage(amqp.Delivery{Body: body, ReplyTo: "replies"}); err != nil {
+			t.Fatalf("Error occured: %v", err)
+		}
+		pending := commandScheduler.List()
+		if len(pending) != 1 || gotReply == nil || gotReply.ScheduleID != pending[0].ID {
+			t.Errorf("Reply = %v, pending = %v", gotReply, pending)
+		}
+	})
+
+	t.Run("should process command which is already due", func(t *testing.T) {
+		due := proto.Clone(removeItem).(*models.Command)
+		due.NotBefore = time.Now().Add(-time.Second).UnixNano()
+		dueBody, err := proto.Marshal(due)
+		if err != nil {
+			t.Fatal(err)
+		}
+		itemService.EXPECT().ProcessItemCommand(gomock.Any(), protoEq{due}).Return(nil)
+
+		a := NewApp(Configurations{}, itemService, WithScheduler(commandScheduler))
+		if err := a.ProcessMessage(amqp.Delivery{Body: dueBody}); err != nil {
+			t.Errorf("Error occured: %v", err)
+		}
+	})
+
+	t.Run("should reject delayed command without scheduler", func(t *testing.T) {
+		a := NewApp(Configurations{}, itemService)
+		if err := a.ProcessMessage(amqp.Delivery{Body: body}); !errors.Is(err, ErrInvalidMessage) {
+			t.Errorf("Error occured: %v, wantErr = %v", err, ErrInvalidMessage)
+		}
+	})
+
+	t.Run("should reject delayed batch", func(t *testing.T) {
+		batchBody, err := proto.Marshal(&models.Batch{Commands: []*models.Command{removeItem}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		a := NewApp(Configurations{}, itemService, WithScheduler(commandScheduler))
+		err = a.ProcessMessage(amqp.Delivery{Type: models.MessageTypeBatch, Body: batchBody})
+		if !errors.Is(err, ErrInvalidMessage) {
+			t.Errorf("Error occured: %v, wantErr = %v", err, ErrInvalidMessage)
+		}
+	})
+}
+
+func TestApp_ProcessMessage_Signed(t *testing.T) {
+	key := signing.Key{Algorithm: signing.AlgorithmHMAC, Key: "c2VjcmV0"}
+	signer, err := signing.NewSigner("client-1", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	removeItem := &models.Command{Type: models.CommandType_RemoveItem, ItemID: 1}
+	envelope, err := models.NewEnvelope(removeItem, "client-2", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed := func(messageType string, msg proto.Message) amqp.Delivery {
+		body, err := proto.Marshal(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		publishing := amqp.Publishing{Type: messageType, Body: body}
+		if err := signer.Sign(&publishing); err != nil {
+			t.Fatal(err)
+		}
+		return amqp.Delivery{Headers: publishing.Headers, Type: publishing.Type, Body: publishing.Body}
+	}
+	signedCommand := signed(models.MessageTypeCommand, removeItem)
+	unsignedCommand := amqp.Delivery{Body: signedCommand.Body}
+
+	tests := []struct {
+		name         string
+		required     bool
+		deliveries   []amqp.Delivery
+		wantClientID string
+		wantErr      error
+	}{
+		{
+			name:         "should process signed message of the client",
+			deliveries:   []amqp.Delivery{signedCommand},
+			wantClientID: "client-1",
+		},
+		{
+			name:       "should process unsigned message when authentication is not required",
+			deliveries: []amqp.Delivery{unsignedCommand},
+		},
+		{
+			name:       "should reject unsigned message when authentication is required",
+			required:   true,
+			deliveries: []amqp.Delivery{unsignedCommand},
+			wantErr:    ErrInvalidMessage,
+		},
+		{
+			name:         "should reject replayed message",
+			deliveries:   []amqp.Delivery{signedCommand, signedCommand},
+			wantClientID: "client-1",
+			wantErr:      ErrInvalidMessage,
+		},
+		{
+			name:       "should reject envelope of other client",
+			deliveries: []amqp.Delivery{signed(models.MessageTypeEnvelope, envelope)},
+			wantErr:    ErrInvalidMessage,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			verifier, err := signing.NewVerifier(map[string]signing.Key{"client-1": key}, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			itemService := service.NewMockItemService(ctrl)
+			if tt.wantErr == nil || len(tt.deliveries) > 1 {
+				itemService.EXPECT().ProcessItemCommand(gomock.Any(), protoEq{removeItem}).
+					DoAndReturn(func(ctx context.Context, _ *models.Command) error {
+						if clientID := ctx.Value(clientIDKey); clientID != tt.wantClientID {
+							t.Errorf("Client id = %v, want %v", clientID, tt.wantClientID)
+						}
+						return nil
+					})
+			}
+
+			a := NewApp(Configurations{AuthConfig: AuthConfig{Required: tt.required}}, itemService, WithVerifier(verifier))
+			for _, d := range tt.deliveries {
+				err = a.ProcessMessage(d)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Error occured: %v, wantErr = %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApp_ProcessMessage_Policy(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "policy.yaml")
+	policyYAML := "clients: {client-1: [{commands: [AddItem]}]}\ndefault: [{commands: [GetItem]}]"
+	if err := os.WriteFile(file, []byte(policyYAML), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	engine, err := policy.NewEngine(file, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := signing.Key{Algorithm: signing.AlgorithmHMAC, Key: "c2VjcmV0"}
+	verifier, err := signing.NewVerifier(map[string]signing.Key{"client-1": key}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := signing.NewSigner("client-1", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addItem := &models.Command{Type: models.CommandType_AddItem, ItemID: 1, ItemPayload: "A"}
+	getItem := &models.Command{Type: models.CommandType_GetItem, ItemID: 1}
+	body := func(msg proto.Message) []byte {
+		body, err := proto.Marshal(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return body
+	}
+	signed := func(d amqp.Delivery) amqp.Delivery {
+		publishing := amqp.Publishing{Type: d.Type, Body: d.Body}
+		if err := signer.Sign(&publishing); err != nil {
+			t.Fatal(err)
+		}
+		d.Headers = publishing.Headers
+		return d
+	}
+
+	tests := []struct {
+		name        string
+		itemService func(itemService *service.MockItemService)
+		d           amqp.Delivery
+		wantReply   *models.Reply
+		wantErr     error
+	}{
+		{
+			name: "should process command allowed for the client",
+			itemService: func(itemService *service.MockItemService) {
+				itemService.EXPECT().ProcessItemCommand(gomock.Any(), protoEq{addItem}).Return(nil)
+			},
+			d: signed(amqp.Delivery{Body: body(addItem)}),
+		},
+		{
+			name: "should process command allowed by default rules",
+			itemService: func(itemService *service.MockItemService) {
+				itemService.EXPECT().ProcessItemCommand(gomock.Any(), protoEq{getItem}).Return(nil)
+			},
+			d: amqp.Delivery{Body: body(getItem)},
+		},
+		{
+			name:    "should reject denied command",
+			d:       amqp.Delivery{Body: body(addItem)},
+			wantErr: ErrInvalidMessage,
+		},
+		{
+			name:      "should reply to denied command",
+			d:         amqp.Delivery{Body: body(addItem), ReplyTo: "replies"},
+			wantReply: &models.Reply{Error: "command is denied: AddItem of item 1 to collection default is not allowed for anonymous client"},
+			wantErr:   ErrInvalidMessage,
+		},
+		{
+			name: "should reject batch with denied command",
+			d: signed(amqp.Delivery{
+				Type: models.MessageTypeBatch,
+				Body: body(&models.Batch{Commands: []*models.Command{addItem, getItem}}),
+			}),
+			wantErr: ErrInvalidMessage,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			itemService := service.NewMockItemService(ctrl)
+			if tt.itemService != nil {
+				tt.itemService(itemService)
+			}
+			a := NewApp(Configurations{}, itemService, WithVerifier(verifier), WithPolicy(engine))
+			var gotReply *models.Reply
+			a.reply = func(ctx context.Context, d amqp.Delivery, reply *models.Reply) error {
+				gotReply = reply
+				return nil
+			}
+
+			err := a.ProcessMessage(tt.d)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Error occured: %v, wantErr = %v", err, tt.wantErr)
+			}
+			if !proto.Equal(tt.wantReply, gotReply) {
+				t.Errorf("Reply = %v, want %v", gotReply, tt.wantReply)
+			}
+		})
+	}
+}
+
+func TestApp_ProcessMessage_Limits(t *testing.T) {
+	limiter, err := limits.New(limits.Limits{Rate: 1, Action: limits.ActionReject},
+		map[string]limits.Limits{"client-1": {MaxItems: 1, QuotaPeriod: 2 * time.Hour}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := signing.Key{Algorithm: signing.AlgorithmHMAC, Key: "c2VjcmV0"}
+	// the window covers the quota period, so messages over the quota are requeued
+	verifier, err := signing.NewVerifier(map[string]signing.Key{"client-1": key}, 3*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := signing.NewSigner("client-1", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addItem := &models.Command{Type: models.CommandType_AddItem, ItemID: 1, ItemPayload: "A"}
+	body, err := proto.Marshal(addItem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := func() amqp.Delivery {
+		publishing := amqp.Publishing{Body: body}
+		if err := signer.Sign(&publishing); err != nil {
+			t.Fatal(err)
+		}
+		return amqp.Delivery{Headers: publishing.Headers, Body: body}
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	itemService := service.NewMockItemService(ctrl)
+	gomock.InOrder(
+		itemService.EXPECT().ProcessItemCommand(gomock.Any(), protoEq{addItem}).Return(repository.ErrCapacityExceeded),
+		itemService.EXPECT().ProcessItemCommand(gomock.Any(), protoEq{addItem}).Return(nil).Times(2),
+	)
+	a := NewApp(Configurations{}, itemService, WithVerifier(verifier), WithLimiter(limiter))
+	var gotReply *models.Reply
+	a.reply = func(ctx context.Context, d amqp.Delivery, reply *models.Reply) error {
+		gotReply = reply
+		return nil
+	}
+
+	// quota of not processed command is refunded
+	if err := a.ProcessMessage(signed()); !errors.Is(err, repository.ErrCapacityExceeded) {
+		t.Fatalf("Error = %v, want %v", err, repository.ErrCapacityExceeded)
+	}
+	if err := a.ProcessMessage(signed()); err != nil {
+		t.Fatalf("Error occured: %v", err)
+	}
+	var requeue *requeueError
+	err = a.ProcessMessage(signed())
+	if !errors.As(err, &requeue) || !errors.Is(err, limits.ErrQuotaExceeded) {
+		t.Fatalf("Error = %v, want requeue of exceeded quota", err)
+	}
+	if requeue.delay != maxRequeueDelay {
+		t.Errorf("Requeue delay = %s, want %s", requeue.delay, maxRequeueDelay)
+	}
+
+	// batch larger than the quota is never allowed, so it is not requeued
+	batchBody, err := proto.Marshal(&models.Batch{Commands: []*models.Command{addItem, addItem}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	publishing := amqp.Publishing{Type: models.MessageTypeBatch, Body: batchBody}
+	if err := signer.Sign(&publishing); err != nil {
+		t.Fatal(err)
+	}
+	err = a.ProcessMessage(amqp.Delivery{Type: models.MessageTypeBatch, Headers: publishing.Headers, Body: batchBody})
+	if !errors.Is(err, ErrInvalidMessage) || !strings.Contains(err.Error(), limits.ErrQuotaExceeded.Error()) {
+		t.Errorf("Error = %v, want rejected message", err)
+	}
+
+	// anonymous clients use the default limits
+	if err := a.ProcessMessage(amqp.Delivery{Body: body}); err != nil {
+		t.Fatalf("Error occured: %v", err)
+	}
+	err = a.ProcessMessage(amqp.Delivery{Body: body, ReplyTo: "replies"})
+	if !errors.Is(err, ErrInvalidMessage) || !strings.Contains(err.Error(), limits.ErrRateLimited.Error()) {
+		t.Errorf("Error = %v, want rejected message", err)
+	}
+	if gotReply == nil || !strings.Contains(gotReply.Error, limits.ErrRateLimited.Error()) {
+		t.Errorf("Reply = %v", gotReply)
+	}
+}
+
+func TestApp_ProcessMessage_LimitsExpiredSignature(t *testing.T) {
+	limiter, err := limits.New(limits.Limits{},
+		map[string]limits.Limits{"client-1": {MaxItems: 1, QuotaPeriod: time.Hour, Action: limits.ActionRequeue}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := signing.Key{Algorithm: signing.AlgorithmHMAC, Key: "c2VjcmV0"}
+	verifier, err := signing.NewVerifier(map[string]signing.Key{"client-1": key}, signing.DefaultWindow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := signing.NewSigner("client-1", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addItem := &models.Command{Type: models.CommandType_AddItem, ItemID: 1, ItemPayload: "A"}
+	body, err := proto.Marshal(addItem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := func() amqp.Delivery {
+		publishing := amqp.Publishing{Body: body}
+		if err := signer.Sign(&publishing); err != nil {
+			t.Fatal(err)
+		}
+		return amqp.Delivery{Acknowledger: &fakeAcknowledger{}, Headers: publishing.Headers, Body: body}
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	itemService := service.NewMockItemService(ctrl)
+	itemService.EXPECT().ProcessItemCommand(gomock.Any(), protoEq{addItem}).Return(nil)
+	a := NewApp(Configurations{}, itemService, WithVerifier(verifier), WithLimiter(limiter))
+	var gotReason error
+	a.deadLetter = func(d amqp.Delivery, reason error) error {
+		gotReason = reason
+		return nil
+	}
+
+	a.handle(signed())
+	if gotReason != nil {
+		t.Fatalf("Message is dead-lettered: %v", gotReason)
+	}
+
+	// quota allows the message again after the signature expires, so it is not requeued
+	d := signed()
+	a.handle(d)
+	if !errors.Is(gotReason, ErrInvalidMessage) || !strings.Contains(gotReason.Error(), limits.ErrQuotaExceeded.Error()) {
+		t.Errorf("Dead-letter reason = %v, want exceeded quota", gotReason)
+	}
+	if acknowledger := d.Acknowledger.(*fakeAcknowledger); acknowledger.acked != 1 || acknowledger.nacked != 0 {
+		t.Errorf("Message is acked %d and nacked %d times, want acked once", acknowledger.acked, acknowledger.nacked)
+	}
+}
+
+func TestApp_FairKey(t *testing.T) {
+	d := amqp.Delivery{Type: models.MessageTypeBatch, Headers: amqp.Table{clientIDKey: "client-1"}}
+	tests := []struct {
+		fairness string
+		want     string
+	}{
+		{fairness: "", want: ""},
+		{fairness: FairnessClient, want: "client-1"},
+		{fairness: FairnessType, want: models.MessageTypeBatch},
+	}
+	for _, tt := range tests {
+		a := NewApp(Configurations{WorkerPoolConfig: WorkerPoolConfig{Fairness: tt.fairness}}, nil)
+		if got := a.fairKey(d); got != tt.want {
+			t.Errorf("Key by %q = %q, want %q", tt.fairness, got, tt.want)
+		}
+	}
+}
+
+func TestApp_Priority(t *testing.T) {
+	a := NewApp(Configurations{RabbitMQConfig: RabbitMQConfig{MaxPriority: 5}}, nil)
+	for priority, want := range map[uint8]uint8{0: 0, 5: 5, 9: 5} {
+		if got := a.priority(amqp.Delivery{Priority: priority}); got != want {
+			t.Errorf("Priority of %d = %d, want %d", priority, got, want)
+		}
+	}
+}
+
+func TestApp_Handle_DeadLetter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	a := NewApp(Configurations{}, service.NewMockItemService(ctrl))
+	var gotReason error
+	a.deadLetter = func(d amqp.Delivery, reason error) error {
+		gotReason = reason
+		return nil
+	}
+
+	a.handle(amqp.Delivery{MessageId: "1", ContentType: "text/csv", Body: []byte("1,A")})
+
+	if !errors.Is(gotReason, ErrInvalidMessage) || !strings.Contains(gotReason.Error(), `unsupported content type: "text/csv"`) {
+		t.Errorf("Dead-letter reason = %v", gotReason)
+	}
+	if errs := a.Activity().RecentErrors(); len(errs) != 1 {
+		t.Errorf("Recent errors = %v", errs)
+	}
+}
+
+func TestApp_Handle_Rejected(t *testing.T) {
+	addItem := &models.Command{Type: models.CommandType_AddItem, ItemID: 1, ItemPayload: "A"}
+	body, err := proto.Marshal(addItem)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		err            error
+		wantDeadLetter bool
+	}{
+		{
+			name:           "should dead-letter command when storage capacity is exceeded",
+			err:            repository.ErrCapacityExceeded,
+			wantDeadLetter: true,
+		},
+		{
+			name:           "should dead-letter command when version is conflicted",
+			err:            fmt.Errorf("cannot update: %w", repository.ErrVersionConflict),
+			wantDeadLetter: true,
+		},
+		{
+			name:           "should dead-letter command when updated item is not found",
+			err:            repository.ErrItemNotFound,
+			wantDeadLetter: true,
+		},
+		{
+			name:           "should dead-letter command when storage is read-only",
+			err:            fmt.Errorf("%w: AddItem command is not allowed", service.ErrReadOnly),
+			wantDeadLetter: true,
+		},
+		{
+			name: "should keep command unacked when it fails by other error",
+			err:  errors.New("connection lost"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			itemService := service.NewMockItemService(ctrl)
+			itemService.EXPECT().ProcessItemCommand(gomock.Any(), protoEq{addItem}).Return(tt.err)
+			a := NewApp(Configurations{}, itemService)
+			var gotReason error
+			a.deadLetter = func(d amqp.Delivery, reason error) error {
+				gotReason = reason
+				return nil
+			}
+			acknowledger := &fakeAcknowledger{}
+
+			a.handle(amqp.Delivery{Acknowledger: acknowledger, DeliveryTag: 1, Body: body})
+
+			if tt.wantDeadLetter {
+				if !errors.Is(gotReason, tt.err) {
+					t.Errorf("Dead-letter reason = %v, want %v", gotReason, tt.err)
+				}
+				if acknowledger.acked != 1 {
+					t.Errorf("Message is acked %d times, want once", acknowledger.acked)
+				}
+				return
+			}
+			if gotReason != nil || acknowledger.acked != 0 || acknowledger.nacked != 0 {
+				t.Errorf("Message is dead-lettered with %v, acked %d and nacked %d times, want unacked",
+					gotReason, acknowledger.acked, acknowledger.nacked)
+			}
+		})
+	}
+}
+
+// fakeAcknowledger counts acknowledgements of deliveries.
+type fakeAcknowledger struct {
+	acked, nacked int
+}
+
+func (f *fakeAcknowledger) Ack(tag uint64, multiple bool) error {
+	f.acked++
+	return nil
+}
+
+func (f *fakeAcknowledger) Nack(tag uint64, multiple bool, requeue bool) error {
+	f.nacked++
+	return nil
+}
+
+func (f *fakeAcknowledger) Reject(tag uint64, requeue bool) error {
+	f.nacked++
+	return nil
+}
+
+// protoEq matches protobuf messages which are equal to the given one.
+type protoEq struct {
+	msg proto.Message
+}
+
+func (p protoEq) Matches(x interface{}) bool {
+	msg, ok := x.(proto.Message)
+	return ok && proto.Equal(p.msg, msg)
+}
+
+func (p protoEq) String() string {
+	return fmt.Sprintf("is equal to %v", p.msg)
+}
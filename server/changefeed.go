@@ -0,0 +1,148 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/repository"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	changeFeedRetryInterval = 5 * time.Second
+	// changeLogIDKey header is set to the id of the change log, so consumers can detect server restarts
+	// when sequence numbers start from the beginning.
+	changeLogIDKey = "X-Change-Log-ID"
+)
+
+// ChangeFeed publishes change events of the registry to the fanout exchange, so every subscriber bound to the exchange
+// with its own queue receives all events in the order they are applied.
+type ChangeFeed struct {
+	config   Configurations
+	registry repository.Registry
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+func NewChangeFeed(config Configurations, registry repository.Registry) *ChangeFeed {
+	return &ChangeFeed{
+		config:   config,
+		registry: registry,
+		done:     make(chan struct{}),
+	}
+}
+
+func (c *ChangeFeed) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+
+	go func() {
+		defer close(c.done)
+
+		// events are published from the moment of start, on failures publishing is resumed from the first event
+		// which is not published yet while it is retained by the change log
+		nextSeq := c.registry.Sequence() + 1
+		var err error
+		for {
+			nextSeq, err = c.publish(ctx, nextSeq)
+			if ctx.Err() != nil {
+				return
+			}
+			log.Errorf("Change feed is interrupted at sequence %d: %v", nextSeq, err)
+			if errors.Is(err, repository.ErrSequenceNotAvailable) {
+				// events which are not retained anymore are lost for the feed
+				lastSeq := c.registry.Sequence()
+				log.Errorf("Change events from %d to %d are not published", nextSeq, lastSeq)
+				nextSeq = lastSeq + 1
+			}
+
+			select {
+			case <-time.After(changeFeedRetryInterval):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (c *ChangeFeed) Quit() {
+	if c.cancel == nil {
+		return
+	}
+	c.cancel()
+	<-c.done
+}
+
+// publish sends events starting from nextSeq until ctx is done or an error occurs.
+// It returns the sequence of the first event which is not published.
+func (c *ChangeFeed) publish(ctx context.Context, nextSeq uint64) (uint64, error) {
+	conn, err := c.config.RabbitMQConfig.Dial()
+	if err != nil {
+		return nextSeq, err
+	}
+	defer conn.Close()
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return nextSeq, err
+	}
+	defer ch.Close()
+
+	exchange := c.config.ChangeFeedConfig.Exchange
+	err = ch.ExchangeDeclare(
+		exchange,
+		amqp.ExchangeFanout,
+		true,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		return nextSeq, err
+	}
+
+	events, err := c.registry.Watch(ctx, nextSeq)
+	if err != nil {
+		return nextSeq, err
+	}
+
+	for event := range events {
+		body, err := proto.Marshal(event)
+		if err != nil {
+			return nextSeq, err
+		}
+
+		err = ch.PublishWithContext(ctx,
+			exchange,
+			"",
+			false,
+			false,
+			amqp.Publishing{
+				Headers: map[string]interface{}{
+					traceIDKey:     event.TraceID,
+					changeLogIDKey: c.registry.ChangeLogID(),
+				},
+				ContentType:  models.ContentTypeProtobuf,
+				DeliveryMode: amqp.Persistent,
+				Type:         models.MessageTypeChangeEvent,
+				MessageId:    strconv.FormatUint(event.Sequence, 10),
+				Timestamp:    time.Unix(0, event.Timestamp),
+				Body:         body,
+			})
+		if err != nil {
+			return nextSeq, err
+		}
+		nextSeq = event.Sequence + 1
+	}
+
+	if ctx.Err() != nil {
+		return nextSeq, ctx.Err()
+	}
+	return nextSeq, fmt.Errorf("%w: feed fell behind the change log", repository.ErrSequenceNotAvailable)
+}
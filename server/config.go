@@ -1,12 +1,471 @@
 package server
 
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/broker"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/logging"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/limits"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/repository"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/signing"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/tracing"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/validation"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
 type Configurations struct {
 	RabbitMQConfig RabbitMQConfig
+	StorageConfig  StorageConfig
+	MetricsConfig  MetricsConfig
+	// ChangeFeedConfig enables publishing of change events when the exchange is set.
+	ChangeFeedConfig  ChangeFeedConfig
+	ReplicationConfig ReplicationConfig
+	ShardingConfig    ShardingConfig
+	RaftConfig        RaftConfig
+	AdminConfig       AdminConfig
+	QueryConfig       QueryConfig
+	SchedulerConfig   SchedulerConfig
+	AuthConfig        AuthConfig
+	PolicyConfig      PolicyConfig
+	LimitsConfig      LimitsConfig
+	WorkerPoolConfig  WorkerPoolConfig
+	// TracingConfig exports spans of processed messages, see tracing.Config.
+	TracingConfig tracing.Config
+	// LoggingConfig sets the level, format and outputs of logs, see logging.Config.
+	LoggingConfig logging.Config
+}
+
+// DefaultConfigurations returns the configuration which is used for settings missing in the config file, env and flags.
+func DefaultConfigurations() Configurations {
+	return Configurations{
+		RabbitMQConfig: RabbitMQConfig{
+			URL:       "localhost:5672",
+			User:      "guest",
+			Password:  "guest",
+			QueueName: "items_queue",
+		},
+		StorageConfig: StorageConfig{
+			ExpiryCheckInterval: time.Second,
+			DataDir:             "./data",
+			ChangeLogRetention:  10000,
+			CollectionConfig:    CollectionConfig{EvictionPolicy: string(repository.EvictionPolicyReject)},
+		},
+		ReplicationConfig: ReplicationConfig{Role: RoleStandalone},
+		ShardingConfig:    ShardingConfig{Exchange: "items_shards"},
+		RaftConfig:        RaftConfig{BindAddr: "127.0.0.1:7001", ApplyTimeout: 5 * time.Second},
+		AuthConfig:        AuthConfig{Window: 5 * time.Minute},
+		PolicyConfig:      PolicyConfig{ReloadInterval: 5 * time.Second},
+		LimitsConfig: LimitsConfig{
+			Default: limits.Limits{QuotaPeriod: 24 * time.Hour, Action: limits.ActionRequeue},
+		},
+		WorkerPoolConfig: WorkerPoolConfig{Workers: 5, Quantum: 1, MaxQueued: 1000},
+		TracingConfig:    tracing.Config{Endpoint: "localhost:4317", SampleRatio: 1},
+		LoggingConfig:    logging.Config{Level: "info", Format: logging.FormatText, Outputs: []string{logging.OutputStdout}},
+	}
+}
+
+// Validate checks the configuration and returns all its problems in validation.Error.
+func (c Configurations) Validate() error {
+	var errs validation.Errors
+	errs.Add("rabbitmqconfig", c.RabbitMQConfig.Validate())
+	errs.Add("storageconfig", c.StorageConfig.Validate())
+	c.validateReplication(&errs)
+	errs.Add("shardingconfig", c.ShardingConfig.Validate())
+	c.validateRaft(&errs)
+	if c.AdminConfig.ListenAddr != "" && c.AdminConfig.Token == "" {
+		errs.Addf("adminconfig.token", "is required for the admin API")
+	}
+	errs.Add("queryconfig", c.QueryConfig.Validate())
+	errs.Add("authconfig", c.AuthConfig.Validate())
+	if c.PolicyConfig.ReloadInterval < 0 {
+		errs.Addf("policyconfig.reloadinterval", "cannot be negative")
+	}
+	errs.Add("limitsconfig", c.LimitsConfig.Validate())
+	errs.Add("workerpoolconfig", c.WorkerPoolConfig.Validate())
+	errs.Add("tracingconfig", c.TracingConfig.Validate())
+	errs.Add("loggingconfig", c.LoggingConfig.Validate())
+	return errs.Err()
+}
+
+func (c Configurations) validateReplication(errs *validation.Errors) {
+	switch c.ReplicationConfig.Role {
+	case "", RoleStandalone:
+		return
+	case RolePrimary:
+		if c.ReplicationConfig.Token == "" {
+			errs.Addf("replicationconfig.token", "is required for primary")
+		}
+		if c.ReplicationConfig.ListenAddr == "" {
+			errs.Addf("replicationconfig.listenaddr", "is required for primary to serve snapshots")
+		}
+	case RoleReplica:
+		if c.ReplicationConfig.Token == "" {
+			errs.Addf("replicationconfig.token", "is required for replica")
+		}
+		if c.ReplicationConfig.PrimaryURL == "" {
+			errs.Addf("replicationconfig.primaryurl", "is required for replica")
+		}
+	default:
+		errs.Addf("replicationconfig.role", "unknown role %q", c.ReplicationConfig.Role)
+		return
+	}
+
+	if c.ChangeFeedConfig.Exchange == "" {
+		errs.Addf("changefeedconfig.exchange", "is required for %s", c.ReplicationConfig.Role)
+	}
+}
+
+func (c Configurations) validateRaft(errs *validation.Errors) {
+	config := c.RaftConfig
+	if config.NodeID == "" {
+		return
+	}
+	if role := c.ReplicationConfig.Role; role != "" && role != RoleStandalone {
+		errs.Addf("raftconfig.nodeid", "Raft replication cannot be used with %s role", role)
+	}
+	if config.BindAddr == "" {
+		errs.Addf("raftconfig.bindaddr", "is required for Raft replication")
+	}
+	for i, peer := range config.Peers {
+		if id, addr, ok := strings.Cut(peer, "="); !ok || id == "" || addr == "" {
+			errs.Addf(fmt.Sprintf("raftconfig.peers[%d]", i), "invalid peer %q, expected <id>=<addr>", peer)
+		}
+	}
+	if config.ListenAddr != "" && config.Token == "" {
+		errs.Addf("raftconfig.token", "is required for the Raft API")
+	}
+	if config.ApplyTimeout < 0 {
+		errs.Addf("raftconfig.applytimeout", "cannot be negative")
+	}
 }
 
 type RabbitMQConfig struct {
 	URL       string
 	User      string
-	Password  string
+	Password  string `secret:"true"`
 	QueueName string
+	// TLS and ExternalAuth secure the connection, see broker.Config.
+	TLS          broker.TLSConfig
+	ExternalAuth bool
+	// DeadLetterQueue receives messages which cannot be decoded, "<queue>.dead-letter" by default.
+	DeadLetterQueue string
+	// MaxPriority declares the queue with priorities from 0 to MaxPriority, it must be the same for clients.
+	// Zero declares the queue without priorities.
+	MaxPriority uint8
+	// Prefetch limits the number of messages delivered to the server and not acked yet, zero means no limit.
+	// The broker orders only messages which are not delivered, so priorities need the limit.
+	Prefetch int
+}
+
+func (c RabbitMQConfig) Validate() error {
+	var errs validation.Errors
+	errs.Add("", c.brokerConfig().Validate())
+	if c.QueueName == "" {
+		errs.Addf("queuename", "is required")
+	}
+	if c.Prefetch < 0 {
+		errs.Addf("prefetch", "cannot be negative")
+	}
+	return errs.Err()
+}
+
+// Dial opens the connection to RabbitMQ.
+func (c RabbitMQConfig) Dial() (*amqp.Connection, error) {
+	return broker.Dial(c.brokerConfig())
+}
+
+func (c RabbitMQConfig) brokerConfig() broker.Config {
+	return broker.Config{
+		URL:          c.URL,
+		User:         c.User,
+		Password:     c.Password,
+		TLS:          c.TLS,
+		ExternalAuth: c.ExternalAuth,
+	}
+}
+
+type StorageConfig struct {
+	// ExpiryCheckInterval is how often expired items are removed from the storage, e.g. "1s".
+	ExpiryCheckInterval time.Duration
+	// DataDir is where persistent collections are saved.
+	DataDir string
+	// ChangeLogRetention is the number of the last change events kept in memory, 10000 by default.
+	ChangeLogRetention int
+	// CollectionConfig is used for collections which are not listed in Collections.
+	CollectionConfig `mapstructure:",squash"`
+	Collections      map[string]CollectionConfig
+}
+
+func (c StorageConfig) Validate() error {
+	var errs validation.Errors
+	if c.ExpiryCheckInterval < 0 {
+		errs.Addf("expirycheckinterval", "cannot be negative")
+	}
+	if c.ChangeLogRetention < 0 {
+		errs.Addf("changelogretention", "cannot be negative")
+	}
+	errs.Add("", c.CollectionConfig.validate(c.DataDir))
+	for name, collectionConfig := range c.Collections {
+		errs.Add("collections."+name, collectionConfig.validate(c.DataDir))
+	}
+	return errs.Err()
+}
+
+type CollectionConfig struct {
+	// MaxItems and MaxBytes limit the collection size, zero means no limit.
+	MaxItems int
+	MaxBytes int64
+	// EvictionPolicy is applied when the limits are reached: reject (default), fifo or lru.
+	EvictionPolicy string
+	// DefaultTTL is set to items added without TTL, e.g. "10m". Zero means such items never expire.
+	DefaultTTL time.Duration
+	// Persistent collections are saved to DataDir on shutdown and loaded on start.
+	Persistent bool
+}
+
+func (c CollectionConfig) validate(dataDir string) error {
+	var errs validation.Errors
+	if c.MaxItems < 0 {
+		errs.Addf("maxitems", "cannot be negative")
+	}
+	if c.MaxBytes < 0 {
+		errs.Addf("maxbytes", "cannot be negative")
+	}
+	if _, err := repository.ParseEvictionPolicy(c.EvictionPolicy); err != nil {
+		errs.Add("evictionpolicy", err)
+	}
+	if c.DefaultTTL < 0 {
+		errs.Addf("defaultttl", "cannot be negative")
+	}
+	if c.Persistent && dataDir == "" {
+		errs.Addf("persistent", "requires datadir")
+	}
+	return errs.Err()
+}
+
+type MetricsConfig struct {
+	// ListenAddr enables metrics endpoint /debug/vars when set, e.g. ":9090".
+	ListenAddr string
+}
+
+type ChangeFeedConfig struct {
+	// Exchange is the name of the fanout exchange change events are published to.
+	Exchange string
+}
+
+type ReplicationConfig struct {
+	// Role is standalone (default), primary or replica. Primary and replicas use the change feed exchange.
+	Role string
+	// ListenAddr is where snapshots are served for replicas, required for primary, e.g. ":9091".
+	ListenAddr string
+	// PrimaryURL is the address of the primary snapshot server, required for replica, e.g. "http://primary:9091".
+	PrimaryURL string
+	// Token is required in "Authorization: Bearer <token>" header of snapshot requests, primary and replicas
+	// must have the same one.
+	Token string `secret:"true"`
+}
+
+type ShardingConfig struct {
+	// Exchange routes commands to shard queues by routing keys "shard-<n>".
+	Exchange string
+	// Shards is the total number of shards, zero disables sharding.
+	Shards int
+	// Shard is the number of the shard consumed by this server, from 0 to Shards-1.
+	Shard int
+}
+
+func (c ShardingConfig) Validate() error {
+	var errs validation.Errors
+	if c.Shards == 0 {
+		return nil
+	}
+	if c.Shards < 0 {
+		errs.Addf("shards", "cannot be negative")
+		return errs.Err()
+	}
+	if c.Exchange == "" {
+		errs.Addf("exchange", "is required for sharding")
+	}
+	if c.Shard < 0 || c.Shard >= c.Shards {
+		errs.Addf("shard", "%d is out of range [0, %d)", c.Shard, c.Shards)
+	}
+	return errs.Err()
+}
+
+type RaftConfig struct {
+	// NodeID enables Raft replication of the storage when set. It must be unique in the cluster.
+	NodeID string
+	// BindAddr is the address of the Raft transport, e.g. "127.0.0.1:7001".
+	BindAddr string
+	// AdvertiseAddr is the transport address other nodes connect to, BindAddr by default.
+	AdvertiseAddr string
+	// DataDir keeps the Raft log and snapshots, empty means they are kept in memory only.
+	DataDir string
+	// Bootstrap creates the cluster of this node and Peers on the first start, it should be set on one node only.
+	Bootstrap bool
+	// Peers are initial members of the cluster in the format "<id>=<addr>", e.g. "node2=127.0.0.1:7002".
+	Peers []string
+	// ListenAddr enables endpoints of the cluster status and membership changes when set, e.g. ":9092".
+	ListenAddr string
+	// Token is required in "Authorization: Bearer <token>" header of requests to ListenAddr.
+	Token string `secret:"true"`
+	// ApplyTimeout limits how long the leader waits until the command is committed, 5s by default.
+	ApplyTimeout time.Duration
+}
+
+type AdminConfig struct {
+	// ListenAddr enables the admin API when set, e.g. ":9093".
+	ListenAddr string
+	// Token is required in "Authorization: Bearer <token>" header of admin requests.
+	Token string `secret:"true"`
+}
+
+type QueryConfig struct {
+	// ListenAddr enables the gRPC query API when set, e.g. ":9094".
+	ListenAddr string
+	// Clients are tokens of the clients allowed to query, requests are authorized by the policy of their client id.
+	Clients []QueryClient
+	// CertFile and KeyFile are the PEM certificate and its key the API is served with over TLS, it is served
+	// in plaintext when they are empty.
+	CertFile string
+	KeyFile  string
+}
+
+// Validate checks that the clients have tokens when the API is enabled.
+func (c QueryConfig) Validate() error {
+	var errs validation.Errors
+	if c.ListenAddr != "" && len(c.Clients) == 0 {
+		errs.Addf("clients", "are required for the query API")
+	}
+	ids := make(map[string]bool, len(c.Clients))
+	for i, client := range c.Clients {
+		field := fmt.Sprintf("clients[%d]", i)
+		validateClientID(&errs, field, client.ID, ids)
+		if client.Token == "" {
+			errs.Addf(field+".token", "is required")
+		}
+	}
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		errs.Addf("certfile", "certificate requires both certfile and keyfile")
+	}
+	return errs.Err()
+}
+
+// QueryClient is sent in "authorization: Bearer <token>" metadata of query requests.
+type QueryClient struct {
+	ID    string
+	Token string `secret:"true"`
+}
+
+type SchedulerConfig struct {
+	// File keeps delayed commands which are not processed yet, they are kept in memory only when it is empty.
+	File string
+}
+
+type AuthConfig struct {
+	// Required rejects messages which are not signed, otherwise they are processed as anonymous.
+	// Signed messages are verified when Required or Clients are set.
+	Required bool
+	// Window is how far the timestamp of a signed message can be from the server time, 5m by default.
+	// Nonces are remembered for this time, so replayed messages are rejected.
+	Window time.Duration
+	// Clients are keys of the clients allowed to send signed messages.
+	Clients []ClientKey
+}
+
+func (c AuthConfig) Validate() error {
+	var errs validation.Errors
+	if c.Required && len(c.Clients) == 0 {
+		errs.Addf("clients", "are required for required authentication")
+	}
+	if c.Window < 0 {
+		errs.Addf("window", "cannot be negative")
+	}
+	ids := make(map[string]bool, len(c.Clients))
+	for i, client := range c.Clients {
+		field := fmt.Sprintf("clients[%d]", i)
+		validateClientID(&errs, field, client.ID, ids)
+		errs.Add(field, client.Key.Validate())
+	}
+	return errs.Err()
+}
+
+type ClientKey struct {
+	ID          string
+	signing.Key `mapstructure:",squash"`
+}
+
+// validateClientID checks that the client id is set and is not listed twice.
+func validateClientID(errs *validation.Errors, field, id string, ids map[string]bool) {
+	switch {
+	case id == "":
+		errs.Addf(field+".id", "is required")
+	case ids[id]:
+		errs.Addf(field+".id", "duplicated client %s", id)
+	}
+	ids[id] = true
+}
+
+type PolicyConfig struct {
+	// File is the YAML policy of allowed commands by client id, all commands are allowed when it is empty.
+	File string
+	// ReloadInterval is how often the file is checked for changes, 5s by default.
+	ReloadInterval time.Duration
+}
+
+type LimitsConfig struct {
+	// Default limits are applied to clients which are not listed in Clients, including messages without client id.
+	Default limits.Limits
+	Clients []ClientLimits
+}
+
+func (c LimitsConfig) Validate() error {
+	var errs validation.Errors
+	errs.Add("default", c.Default.Validate())
+	ids := make(map[string]bool, len(c.Clients))
+	for i, client := range c.Clients {
+		field := fmt.Sprintf("clients[%d]", i)
+		validateClientID(&errs, field, client.ID, ids)
+		errs.Add(field, client.Limits.Validate())
+	}
+	return errs.Err()
+}
+
+type ClientLimits struct {
+	ID            string
+	limits.Limits `mapstructure:",squash"`
+}
+
+type WorkerPoolConfig struct {
+	// Workers is the number of messages processed at the same time, 5 by default.
+	Workers int
+	// Fairness enables fair queuing of messages by client id (client) or message type (type), messages are
+	// processed in the order they are received when it is empty.
+	Fairness string
+	// Quantum is the cost of messages a key can process in its turn, a message costs 1 per started KiB of the body.
+	// 1 by default.
+	Quantum int
+	// MaxQueued is the number of messages waiting for workers, 1000 by default. Receiving is paused when it is reached.
+	MaxQueued int
+}
+
+func (c WorkerPoolConfig) Validate() error {
+	var errs validation.Errors
+	switch c.Fairness {
+	case "", FairnessClient, FairnessType:
+	default:
+		errs.Addf("fairness", "unknown fairness %q", c.Fairness)
+	}
+	if c.Workers < 0 {
+		errs.Addf("workers", "cannot be negative")
+	}
+	if c.Quantum < 0 {
+		errs.Addf("quantum", "cannot be negative")
+	}
+	if c.MaxQueued < 0 {
+		errs.Addf("maxqueued", "cannot be negative")
+	}
+	return errs.Err()
 }
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/signing"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigurations_Validate(t *testing.T) {
+	tests := []struct {
+		name       string
+		configure  func(c *Configurations)
+		wantFields []string
+	}{
+		{
+			name:      "should accept defaults",
+			configure: func(c *Configurations) {},
+		},
+		{
+			name: "should accept Raft replication",
+			configure: func(c *Configurations) {
+				c.RaftConfig.NodeID = "node1"
+				c.RaftConfig.Peers = []string{"node2=127.0.0.1:7002"}
+				c.RaftConfig.ListenAddr = ":9201"
+				c.RaftConfig.Token = "secret"
+			},
+		},
+		{
+			name: "should report all problems",
+			configure: func(c *Configurations) {
+				c.RabbitMQConfig.URL = ""
+				c.RabbitMQConfig.Prefetch = -1
+				c.StorageConfig.Collections = map[string]CollectionConfig{"users": {EvictionPolicy: "random"}}
+				c.ReplicationConfig.Role = RolePrimary
+				c.AdminConfig.ListenAddr = ":9093"
+				c.QueryConfig.ListenAddr = ":9094"
+				c.AuthConfig.Clients = []ClientKey{
+					{ID: "client1", Key: signing.Key{Algorithm: signing.AlgorithmHMAC, Key: "c2VjcmV0"}},
+					{ID: "client1", Key: signing.Key{Algorithm: "rsa"}},
+				}
+				c.WorkerPoolConfig.Fairness = "random"
+				c.LoggingConfig.Level = "loud"
+			},
+			wantFields: []string{
+				"rabbitmqconfig.url",
+				"rabbitmqconfig.prefetch",
+				"storageconfig.collections.users.evictionpolicy",
+				"replicationconfig.token",
+				"replicationconfig.listenaddr",
+				"changefeedconfig.exchange",
+				"adminconfig.token",
+				"queryconfig.clients",
+				"authconfig.clients[1].id",
+				"authconfig.clients[1]",
+				"workerpoolconfig.fairness",
+				"loggingconfig.level",
+			},
+		},
+		{
+			name: "should check Raft replication",
+			configure: func(c *Configurations) {
+				c.RaftConfig.NodeID = "node1"
+				c.RaftConfig.Peers = []string{"node2"}
+				c.RaftConfig.ListenAddr = ":9201"
+				c.ReplicationConfig = ReplicationConfig{Role: RoleReplica, PrimaryURL: "http://primary:9091", Token: "secret"}
+				c.ChangeFeedConfig.Exchange = "changes"
+			},
+			wantFields: []string{"raftconfig.nodeid", "raftconfig.peers[0]", "raftconfig.token"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configuration := DefaultConfigurations()
+			tt.configure(&configuration)
+
+			err := configuration.Validate()
+			if tt.wantFields == nil {
+				assert.NoError(t, err)
+				return
+			}
+			var validationErr *validation.Error
+			require.ErrorAs(t, err, &validationErr)
+			fields := make([]string, 0, len(validationErr.Problems))
+			for _, problem := range validationErr.Problems {
+				fields = append(fields, problem.Field)
+			}
+			assert.Equal(t, tt.wantFields, fields)
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package consensus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/repository"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/service"
+	"github.com/hashicorp/raft"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	traceIDKey = "X-Trace-ID"
+
+	// entryTypeRemoveExpiredItems is proposed by the leader instead of running the reaper on every node.
+	entryTypeRemoveExpiredItems = "RemoveExpiredItems"
+)
+
+// applyResult is the response of the applied log entry.
+type applyResult struct {
+	items []models.Item
+	err   error
+}
+
+// fsm applies committed log entries to the registry. Every node applies the same entries in the same order
+// with the clock set to the timestamp of the entry, so registries of all nodes are identical.
+type fsm struct {
+	registry repository.Registry
+	service  service.ItemService
+	// now is the timestamp of the last applied entry in Unix nanoseconds
+	now atomic.Int64
+}
+
+// newFSM creates the state machine together with its registry which uses the clock of the log.
+func newFSM(config repository.RegistryConfig) (*fsm, error) {
+	f := &fsm{}
+
+	// the raft log and snapshots are the only persistence of the state machine
+	config.DataDir = ""
+	config.Now = f.clock
+	registry, err := repository.NewRegistry(config)
+	if err != nil {
+		return nil, err
+	}
+
+	f.registry = registry
+	f.service = service.New(registry, service.WithClock(f.clock))
+	return f, nil
+}
+
+func (f *fsm) clock() time.Time {
+	return time.Unix(0, f.now.Load())
+}
+
+func (f *fsm) Apply(l *raft.Log) interface{} {
+	entry := new(models.LogEntry)
+	if err := proto.Unmarshal(l.Data, entry); err != nil {
+		return &applyResult{err: fmt.Errorf("cannot unmarshal log entry %d: %w", l.Index, err)}
+	}
+
+	f.now.Store(entry.Timestamp)
+	ctx := context.WithValue(context.Background(), traceIDKey, entry.TraceID)
+
+	switch entry.Type {
+	case models.MessageTypeCommand:
+		command := new(models.Command)
+		if err := proto.Unmarshal(entry.Body, command); err != nil {
+			return &applyResult{err: err}
+		}
+
+		switch command.Type {
+		case models.CommandType_GetItem, models.CommandType_GetAllItems:
+			// reads are not proposed, every node serves them locally
+			items, err := f.service.QueryItems(ctx, command)
+			return &applyResult{items: items, err: err}
+		default:
+			return &applyResult{err: f.service.ProcessItemCommand(ctx, command)}
+		}
+	case models.MessageTypeBatch:
+		batch := new(models.Batch)
+		if err := proto.Unmarshal(entry.Body, batch); err != nil {
+			return &applyResult{err: err}
+		}
+
+		items, err := f.service.ProcessBatch(ctx, batch)
+		return &applyResult{items: items, err: err}
+	case entryTypeRemoveExpiredItems:
+		repository.RemoveExpiredItems(ctx, f.registry)
+		return &applyResult{}
+	default:
+		return &applyResult{err: fmt.Errorf("unknown log entry type: %s", entry.Type)}
+	}
+}
+
+// Snapshot is called between Apply calls, so the registry snapshot is consistent with the log index.
+func (f *fsm) Snapshot() (raft.FSMSnapshot, error) {
+	return &fsmSnapshot{
+		Timestamp: f.now.Load(),
+		Registry:  f.registry.Snapshot(),
+	}, nil
+}
+
+func (f *fsm) Restore(rc io.ReadCloser) error {
+	defer rc.Close()
+
+	snapshot := new(fsmSnapshot)
+	if err := json.NewDecoder(rc).Decode(snapshot); err != nil {
+		return fmt.Errorf("cannot decode snapshot: %w", err)
+	}
+
+	f.now.Store(snapshot.Timestamp)
+	return f.registry.Restore(snapshot.Registry)
+}
+
+type fsmSnapshot struct {
+	// Timestamp of the last entry applied before the snapshot in Unix nanoseconds.
+	Timestamp int64                       `json:"timestamp"`
+	Registry  *repository.ReplicaSnapshot `json:"registry"`
+}
+
+func (s *fsmSnapshot) Persist(sink raft.SnapshotSink) error {
+	if err := json.NewEncoder(sink).Encode(s); err != nil {
+		sink.Cancel()
+		return fmt.Errorf("cannot write snapshot: %w", err)
+	}
+	return sink.Close()
+}
+
+func (s *fsmSnapshot) Release() {}
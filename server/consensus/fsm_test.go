@@ -0,0 +1,120 @@
+package consensus
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/repository"
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func logEntry(t *testing.T, entryType string, msg proto.Message, timestamp time.Time) *raft.Log {
+	entry := &models.LogEntry{Type: entryType, Timestamp: timestamp.UnixNano()}
+	if msg != nil {
+		var err error
+		entry.Body, err = proto.Marshal(msg)
+		require.NoError(t, err)
+	}
+
+	data, err := proto.Marshal(entry)
+	require.NoError(t, err)
+	return &raft.Log{Data: data}
+}
+
+func Test_fsm_Apply(t *testing.T) {
+	f, err := newFSM(repository.RegistryConfig{})
+	require.NoError(t, err)
+
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	add := &models.Command{Type: models.CommandType_AddItem, ItemID: 1, ItemPayload: "A", ItemTTLSeconds: 10}
+	get := &models.Command{Type: models.CommandType_GetItem, ItemID: 1}
+
+	result := f.Apply(logEntry(t, models.MessageTypeCommand, add, now)).(*applyResult)
+	require.NoError(t, result.err)
+
+	// expiration is calculated from the timestamp of the entry
+	result = f.Apply(logEntry(t, models.MessageTypeCommand, get, now.Add(time.Second))).(*applyResult)
+	require.NoError(t, result.err)
+	require.Len(t, result.items, 1)
+	assert.True(t, now.Add(10*time.Second).Equal(result.items[0].ExpiresAt))
+	assert.Equal(t, uint64(now.UnixNano()), result.items[0].Sequence)
+
+	batch := &models.Batch{Commands: []*models.Command{{Type: models.CommandType_UpdateItem, ItemID: 1, ItemPayload: "B", ExpectedVersion: 2}}}
+	result = f.Apply(logEntry(t, models.MessageTypeBatch, batch, now.Add(2*time.Second))).(*applyResult)
+	assert.ErrorIs(t, result.err, repository.ErrVersionConflict)
+
+	result = f.Apply(logEntry(t, entryTypeRemoveExpiredItems, nil, now.Add(11*time.Second))).(*applyResult)
+	require.NoError(t, result.err)
+	result = f.Apply(logEntry(t, models.MessageTypeCommand, get, now.Add(12*time.Second))).(*applyResult)
+	require.NoError(t, result.err)
+	assert.Empty(t, result.items)
+
+	result = f.Apply(logEntry(t, "Unknown", nil, now)).(*applyResult)
+	assert.Error(t, result.err)
+	result = f.Apply(&raft.Log{Data: []byte("not a log entry")}).(*applyResult)
+	assert.Error(t, result.err)
+}
+
+// stateOf returns all collections of the registry in JSON, so the states are compared regardless of time locations.
+func stateOf(t *testing.T, registry repository.Registry) string {
+	state, err := json.Marshal(registry.Snapshot().Collections)
+	require.NoError(t, err)
+	return string(state)
+}
+
+type bufferSink struct {
+	bytes.Buffer
+}
+
+func (s *bufferSink) ID() string    { return "test" }
+func (s *bufferSink) Cancel() error { return nil }
+func (s *bufferSink) Close() error  { return nil }
+
+func Test_fsm_SnapshotRestore(t *testing.T) {
+	config := repository.RegistryConfig{
+		Defaults: repository.CollectionConfig{Limits: repository.Limits{MaxItems: 2, Policy: repository.EvictionPolicyLRU}},
+	}
+	primary, err := newFSM(config)
+	require.NoError(t, err)
+	restored, err := newFSM(config)
+	require.NoError(t, err)
+
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	commands := []*models.Command{
+		{Type: models.CommandType_AddItem, ItemID: 1, ItemPayload: "A"},
+		{Type: models.CommandType_AddItem, ItemID: 2, ItemPayload: "B", ItemTTLSeconds: 60},
+		{Type: models.CommandType_GetItem, ItemID: 1},
+	}
+	for idx, command := range commands {
+		result := primary.Apply(logEntry(t, models.MessageTypeCommand, command, now.Add(time.Duration(idx)*time.Second))).(*applyResult)
+		require.NoError(t, result.err)
+	}
+
+	snapshot, err := primary.Snapshot()
+	require.NoError(t, err)
+	sink := &bufferSink{}
+	require.NoError(t, snapshot.Persist(sink))
+	require.NoError(t, restored.Restore(io.NopCloser(&sink.Buffer)))
+	assert.Equal(t, stateOf(t, primary.registry), stateOf(t, restored.registry))
+
+	// the least recently accessed item 2 is evicted from both state machines
+	add := &models.Command{Type: models.CommandType_AddItem, ItemID: 3, ItemPayload: "C"}
+	for _, f := range []*fsm{primary, restored} {
+		result := f.Apply(logEntry(t, models.MessageTypeCommand, add, now.Add(time.Minute))).(*applyResult)
+		require.NoError(t, result.err)
+	}
+	assert.Equal(t, stateOf(t, primary.registry), stateOf(t, restored.registry))
+
+	result := restored.Apply(logEntry(t, models.MessageTypeCommand, &models.Command{Type: models.CommandType_GetAllItems}, now.Add(time.Minute))).(*applyResult)
+	require.NoError(t, result.err)
+	require.Len(t, result.items, 2)
+	assert.Equal(t, int64(1), result.items[0].ID)
+	assert.Equal(t, int64(3), result.items[1].ID)
+}
@@ -0,0 +1,36 @@
+package consensus
+
+import (
+	"context"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/repository"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/service"
+)
+
+type itemServiceImpl struct {
+	node *Node
+	// reader serves queries from the registry of the node
+	reader service.ItemService
+}
+
+// NewItemService creates service which proposes commands to the Raft log of the node and returns
+// after they are committed and applied. It returns ErrNotLeader for commands when the node is not the leader.
+// Queries are served by every node from its registry, followers may not see the latest committed changes yet.
+func NewItemService(node *Node) service.ItemService {
+	return &itemServiceImpl{node: node, reader: service.NewReadOnly(node.Registry())}
+}
+
+func (i *itemServiceImpl) ProcessItemCommand(ctx context.Context, command *models.Command) error {
+	_, err := i.node.propose(ctx, models.MessageTypeCommand, command)
+	return err
+}
+
+func (i *itemServiceImpl) ProcessBatch(ctx context.Context, batch *models.Batch) ([]models.Item, error) {
+	return i.node.propose(ctx, models.MessageTypeBatch, batch)
+}
+
+func (i *itemServiceImpl) QueryItems(ctx context.Context, command *models.Command) ([]models.Item, error) {
+	// local reads do not change the LRU order, it must be the same on every node
+	return i.reader.QueryItems(repository.WithoutAccess(ctx), command)
+}
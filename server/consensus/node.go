@@ -0,0 +1,401 @@
+package consensus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/logging"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/repository"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/raft"
+	raftboltdb "github.com/hashicorp/raft-boltdb/v2"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/proto"
+)
+
+var log = logging.Logger("server/consensus")
+
+const (
+	defaultApplyTimeout        = 5 * time.Second
+	defaultExpiryCheckInterval = time.Second
+
+	transportMaxPool   = 3
+	transportTimeout   = 10 * time.Second
+	snapshotsRetained  = 2
+	membershipTimeout  = 10 * time.Second
+	raftLogFileName    = "raft.db"
+	leaderNotifyBuffer = 1
+)
+
+// ErrNotLeader is returned for proposals and membership changes made on a follower.
+var ErrNotLeader = errors.New("node is not the leader")
+
+type Config struct {
+	// ID identifies the node in the cluster.
+	ID string
+	// BindAddr is the address of the Raft transport, e.g. "127.0.0.1:7001".
+	BindAddr string
+	// AdvertiseAddr is the transport address other nodes connect to, BindAddr by default.
+	AdvertiseAddr string
+	// DataDir keeps the Raft log and snapshots, empty means they are kept in memory only.
+	DataDir string
+	// Bootstrap creates the cluster of this node and Peers when the node has no state yet.
+	// It should be set on one node only, other nodes join the cluster by membership changes.
+	Bootstrap bool
+	Peers     []Peer
+	// ApplyTimeout limits how long the leader waits until the proposed entry is committed.
+	ApplyTimeout time.Duration
+	// ExpiryCheckInterval is how often the leader proposes removing expired items.
+	ExpiryCheckInterval time.Duration
+}
+
+type Peer struct {
+	ID   string `json:"id"`
+	Addr string `json:"addr"`
+}
+
+// Node is a member of the Raft cluster which replicates the item store. Commands are proposed to the log
+// by the leader and applied to the registry of every node after they are committed.
+type Node struct {
+	config    Config
+	raft      *raft.Raft
+	fsm       *fsm
+	transport raft.Transport
+	closers   []io.Closer
+	notifyCh  chan bool
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+// NewNode starts the node which uses TCP transport and keeps the log in DataDir.
+func NewNode(config Config, registryConfig repository.RegistryConfig) (*Node, error) {
+	advertiseAddr := config.AdvertiseAddr
+	if advertiseAddr == "" {
+		advertiseAddr = config.BindAddr
+	}
+	advertise, err := net.ResolveTCPAddr("tcp", advertiseAddr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid advertise address: %w", err)
+	}
+
+	transport, err := raft.NewTCPTransportWithLogger(config.BindAddr, advertise, transportMaxPool, transportTimeout, newLogger("raft-transport"))
+	if err != nil {
+		return nil, err
+	}
+
+	stores, err := newStores(config.DataDir)
+	if err != nil {
+		transport.Close()
+		return nil, err
+	}
+	stores.closers = append(stores.closers, transport)
+
+	return newNode(config, registryConfig, raftConfig(config.ID), transport, stores)
+}
+
+// stores of the Raft log, its state and snapshots.
+type stores struct {
+	logs      raft.LogStore
+	stable    raft.StableStore
+	snapshots raft.SnapshotStore
+	closers   []io.Closer
+}
+
+func newStores(dataDir string) (stores, error) {
+	if dataDir == "" {
+		logs := raft.NewInmemStore()
+		return stores{logs: logs, stable: logs, snapshots: raft.NewInmemSnapshotStore()}, nil
+	}
+
+	if err := os.MkdirAll(dataDir, 0o755); err != nil {
+		return stores{}, err
+	}
+
+	snapshots, err := raft.NewFileSnapshotStoreWithLogger(dataDir, snapshotsRetained, newLogger("raft-snapshots"))
+	if err != nil {
+		return stores{}, err
+	}
+
+	boltStore, err := raftboltdb.NewBoltStore(filepath.Join(dataDir, raftLogFileName))
+	if err != nil {
+		return stores{}, err
+	}
+	return stores{logs: boltStore, stable: boltStore, snapshots: snapshots, closers: []io.Closer{boltStore}}, nil
+}
+
+func raftConfig(id string) *raft.Config {
+	config := raft.DefaultConfig()
+	config.LocalID = raft.ServerID(id)
+	config.Logger = newLogger("raft")
+	return config
+}
+
+// newLogger returns the logger of Raft library which writes to the output of logs in their format.
+func newLogger(name string) hclog.Logger {
+	_, jsonFormat := log.Formatter.(*logrus.JSONFormatter)
+	return hclog.New(&hclog.LoggerOptions{
+		Name:       name,
+		Output:     log.Out,
+		Level:      hclog.Info,
+		JSONFormat: jsonFormat,
+	})
+}
+
+func newNode(config Config, registryConfig repository.RegistryConfig, raftConfig *raft.Config, transport raft.Transport, stores stores) (*Node, error) {
+	if config.ApplyTimeout <= 0 {
+		config.ApplyTimeout = defaultApplyTimeout
+	}
+	if config.ExpiryCheckInterval <= 0 {
+		config.ExpiryCheckInterval = defaultExpiryCheckInterval
+	}
+
+	closeAll := func() {
+		for _, closer := range stores.closers {
+			closer.Close()
+		}
+	}
+
+	fsm, err := newFSM(registryConfig)
+	if err != nil {
+		closeAll()
+		return nil, err
+	}
+
+	n := &Node{
+		config:    config,
+		fsm:       fsm,
+		transport: transport,
+		closers:   stores.closers,
+		notifyCh:  make(chan bool, leaderNotifyBuffer),
+		done:      make(chan struct{}),
+	}
+	raftConfig.NotifyCh = n.notifyCh
+
+	if config.Bootstrap {
+		if err := n.bootstrap(raftConfig, transport, stores); err != nil {
+			closeAll()
+			return nil, err
+		}
+	}
+
+	n.raft, err = raft.NewRaft(raftConfig, fsm, stores.logs, stores.stable, stores.snapshots, transport)
+	if err != nil {
+		closeAll()
+		return nil, err
+	}
+	return n, nil
+}
+
+func (n *Node) bootstrap(raftConfig *raft.Config, transport raft.Transport, stores stores) error {
+	exists, err := raft.HasExistingState(stores.logs, stores.stable, stores.snapshots)
+	if err != nil {
+		return err
+	}
+	if exists {
+		log.Info("Raft state exists, bootstrap is skipped")
+		return nil
+	}
+
+	servers := []raft.Server{{ID: raftConfig.LocalID, Address: transport.LocalAddr()}}
+	for _, peer := range n.config.Peers {
+		if raft.ServerID(peer.ID) == raftConfig.LocalID {
+			continue
+		}
+		servers = append(servers, raft.Server{ID: raft.ServerID(peer.ID), Address: raft.ServerAddress(peer.Addr)})
+	}
+	return raft.BootstrapCluster(raftConfig, stores.logs, stores.stable, stores.snapshots, transport, raft.Configuration{Servers: servers})
+}
+
+// Registry returns the registry the committed entries are applied to. It should be used for reads only.
+func (n *Node) Registry() repository.Registry {
+	return n.fsm.registry
+}
+
+// Start runs lead while the node is the leader. Its context is cancelled when the leadership is lost,
+// lead is started again when the node becomes the leader next time.
+func (n *Node) Start(lead func(ctx context.Context)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	n.cancel = cancel
+
+	go func() {
+		defer close(n.done)
+
+		var stopLeading func()
+		defer func() {
+			if stopLeading != nil {
+				stopLeading()
+			}
+		}()
+
+		for {
+			select {
+			case isLeader := <-n.notifyCh:
+				switch {
+				case isLeader && stopLeading == nil:
+					log.Infof("Node %s became the leader", n.config.ID)
+					stopLeading = n.lead(ctx, lead)
+				case !isLeader && stopLeading != nil:
+					log.Infof("Node %s lost the leadership", n.config.ID)
+					stopLeading()
+					stopLeading = nil
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// lead runs lead and removing of expired items, the returned function stops them and waits until they finish.
+func (n *Node) lead(ctx context.Context, lead func(ctx context.Context)) func() {
+	ctx, cancel := context.WithCancel(ctx)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		lead(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		n.removeExpiredItems(ctx)
+	}()
+
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+func (n *Node) removeExpiredItems(ctx context.Context) {
+	ticker := time.NewTicker(n.config.ExpiryCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := n.propose(ctx, entryTypeRemoveExpiredItems, nil); err != nil {
+				log.Errorf("Cannot propose removing of expired items: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// propose appends the entry to the log and returns the result of applying it after it is committed.
+func (n *Node) propose(ctx context.Context, entryType string, msg proto.Message) ([]models.Item, error) {
+	if n.raft.State() != raft.Leader {
+		return nil, n.notLeader()
+	}
+
+	entry := &models.LogEntry{Type: entryType}
+	entry.TraceID, _ = ctx.Value(traceIDKey).(string)
+	// timestamps do not go back when the leader is changed, so the clock of the log is monotonic
+	entry.Timestamp = time.Now().UnixNano()
+	if last := n.fsm.now.Load(); entry.Timestamp < last {
+		entry.Timestamp = last
+	}
+	if msg != nil {
+		var err error
+		if entry.Body, err = proto.Marshal(msg); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := proto.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	future := n.raft.Apply(data, n.config.ApplyTimeout)
+	if err := future.Error(); err != nil {
+		if errors.Is(err, raft.ErrNotLeader) || errors.Is(err, raft.ErrLeadershipLost) {
+			return nil, fmt.Errorf("%w: %v", ErrNotLeader, err)
+		}
+		return nil, err
+	}
+
+	result := future.Response().(*applyResult)
+	return result.items, result.err
+}
+
+func (n *Node) notLeader() error {
+	if addr, id := n.raft.LeaderWithID(); id != "" {
+		return fmt.Errorf("%w: leader is %s (%s)", ErrNotLeader, id, addr)
+	}
+	return fmt.Errorf("%w: leader is unknown", ErrNotLeader)
+}
+
+// AddVoter adds the node to the cluster or updates its address. It is allowed on the leader only.
+func (n *Node) AddVoter(peer Peer) error {
+	if n.raft.State() != raft.Leader {
+		return n.notLeader()
+	}
+	return n.raft.AddVoter(raft.ServerID(peer.ID), raft.ServerAddress(peer.Addr), 0, membershipTimeout).Error()
+}
+
+// RemoveServer removes the node from the cluster. It is allowed on the leader only.
+func (n *Node) RemoveServer(id string) error {
+	if n.raft.State() != raft.Leader {
+		return n.notLeader()
+	}
+	return n.raft.RemoveServer(raft.ServerID(id), 0, membershipTimeout).Error()
+}
+
+// Snapshot takes snapshot of the state machine and compacts the log.
+func (n *Node) Snapshot() error {
+	return n.raft.Snapshot().Error()
+}
+
+type Status struct {
+	ID           string `json:"id"`
+	State        string `json:"state"`
+	Leader       Peer   `json:"leader"`
+	Servers      []Peer `json:"servers"`
+	AppliedIndex uint64 `json:"applied_index"`
+}
+
+func (n *Node) Status() (Status, error) {
+	future := n.raft.GetConfiguration()
+	if err := future.Error(); err != nil {
+		return Status{}, err
+	}
+
+	addr, id := n.raft.LeaderWithID()
+	status := Status{
+		ID:           n.config.ID,
+		State:        n.raft.State().String(),
+		Leader:       Peer{ID: string(id), Addr: string(addr)},
+		Servers:      make([]Peer, 0, len(future.Configuration().Servers)),
+		AppliedIndex: n.raft.AppliedIndex(),
+	}
+	for _, server := range future.Configuration().Servers {
+		status.Servers = append(status.Servers, Peer{ID: string(server.ID), Addr: string(server.Address)})
+	}
+	return status, nil
+}
+
+// Quit stops leading and shuts the node down.
+func (n *Node) Quit() error {
+	if n.cancel != nil {
+		n.cancel()
+		<-n.done
+	}
+
+	err := n.raft.Shutdown().Error()
+	for _, closer := range n.closers {
+		if closeErr := closer.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
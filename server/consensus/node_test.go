@@ -0,0 +1,256 @@
+package consensus
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/repository"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const waitTimeout = 5 * time.Second
+
+// testCluster runs nodes on in-memory transports, so partitions are simulated by disconnecting transports.
+type testCluster struct {
+	t          *testing.T
+	nodes      map[string]*Node
+	transports map[string]*raft.InmemTransport
+	// leading is true while lead of the node is running
+	leading map[string]*atomic.Bool
+}
+
+func newTestCluster(t *testing.T, size int) *testCluster {
+	c := &testCluster{
+		t:          t,
+		nodes:      make(map[string]*Node),
+		transports: make(map[string]*raft.InmemTransport),
+		leading:    make(map[string]*atomic.Bool),
+	}
+
+	peers := make([]Peer, 0, size)
+	for i := 1; i <= size; i++ {
+		id := fmt.Sprintf("node%d", i)
+		peers = append(peers, Peer{ID: id, Addr: id})
+	}
+	for idx, peer := range peers {
+		c.addNode(peer.ID, idx == 0, peers)
+	}
+	return c
+}
+
+func (c *testCluster) addNode(id string, bootstrap bool, peers []Peer) *Node {
+	_, transport := raft.NewInmemTransport(raft.ServerAddress(id))
+	for otherID, other := range c.transports {
+		transport.Connect(raft.ServerAddress(otherID), other)
+		other.Connect(raft.ServerAddress(id), transport)
+	}
+
+	config := raftConfig(id)
+	config.HeartbeatTimeout = 50 * time.Millisecond
+	config.ElectionTimeout = 50 * time.Millisecond
+	config.LeaderLeaseTimeout = 50 * time.Millisecond
+	config.CommitTimeout = 5 * time.Millisecond
+	// log is compacted to the snapshot, so new nodes are restored from it
+	config.TrailingLogs = 1
+	config.Logger = hclog.NewNullLogger()
+
+	logs := raft.NewInmemStore()
+	node, err := newNode(
+		Config{ID: id, Bootstrap: bootstrap, Peers: peers, ApplyTimeout: 500 * time.Millisecond, ExpiryCheckInterval: 20 * time.Millisecond},
+		repository.RegistryConfig{},
+		config,
+		transport,
+		stores{logs: logs, stable: logs, snapshots: raft.NewInmemSnapshotStore()},
+	)
+	require.NoError(c.t, err)
+
+	leading := new(atomic.Bool)
+	node.Start(func(ctx context.Context) {
+		leading.Store(true)
+		<-ctx.Done()
+		leading.Store(false)
+	})
+	c.t.Cleanup(func() {
+		assert.NoError(c.t, node.Quit())
+	})
+
+	c.nodes[id] = node
+	c.transports[id] = transport
+	c.leading[id] = leading
+	return node
+}
+
+// leader waits until exactly one of the nodes is the leader and returns its id.
+func (c *testCluster) leader(ids ...string) string {
+	var leader string
+	require.Eventually(c.t, func() bool {
+		leaders := 0
+		for _, id := range ids {
+			if c.nodes[id].raft.State() == raft.Leader {
+				leader = id
+				leaders++
+			}
+		}
+		return leaders == 1
+	}, waitTimeout, 10*time.Millisecond)
+	return leader
+}
+
+func (c *testCluster) ids() []string {
+	ids := make([]string, 0, len(c.nodes))
+	for id := range c.nodes {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (c *testCluster) partition(id string) {
+	for otherID, other := range c.transports {
+		if otherID != id {
+			c.transports[id].Disconnect(raft.ServerAddress(otherID))
+			other.Disconnect(raft.ServerAddress(id))
+		}
+	}
+}
+
+func (c *testCluster) heal(id string) {
+	for otherID, other := range c.transports {
+		if otherID != id {
+			c.transports[id].Connect(raft.ServerAddress(otherID), other)
+			other.Connect(raft.ServerAddress(id), c.transports[id])
+		}
+	}
+}
+
+// waitConverged waits until the nodes have the same state as the given one.
+func (c *testCluster) waitConverged(leader string, ids ...string) {
+	want := stateOf(c.t, c.nodes[leader].Registry())
+	require.Eventually(c.t, func() bool {
+		for _, id := range ids {
+			if stateOf(c.t, c.nodes[id].Registry()) != want {
+				return false
+			}
+		}
+		return true
+	}, waitTimeout, 10*time.Millisecond)
+}
+
+func getItem(t *testing.T, node *Node, itemID int64) []models.Item {
+	items, err := NewItemService(node).QueryItems(context.Background(), &models.Command{Type: models.CommandType_GetItem, ItemID: itemID})
+	require.NoError(t, err)
+	return items
+}
+
+func TestNode_Replication(t *testing.T) {
+	c := newTestCluster(t, 3)
+	leader := c.leader(c.ids()...)
+	assert.Eventually(t, c.leading[leader].Load, waitTimeout, 10*time.Millisecond)
+
+	ctx := context.Background()
+	itemService := NewItemService(c.nodes[leader])
+	require.NoError(t, itemService.ProcessItemCommand(ctx, &models.Command{Type: models.CommandType_AddItem, ItemID: 1, ItemPayload: "A"}))
+	require.NoError(t, itemService.ProcessItemCommand(ctx, &models.Command{Type: models.CommandType_AddItem, ItemID: 2, ItemPayload: "B", ItemTTLSeconds: 1}))
+	_, err := itemService.ProcessBatch(ctx, &models.Batch{Commands: []*models.Command{
+		{Type: models.CommandType_UpdateItem, ItemID: 1, ItemPayload: "AA", ExpectedVersion: 1},
+		{Type: models.CommandType_AddItem, ItemID: 3, ItemPayload: "C"},
+	}})
+	require.NoError(t, err)
+	err = itemService.ProcessItemCommand(ctx, &models.Command{Type: models.CommandType_UpdateItem, ItemID: 1, ItemPayload: "X", ExpectedVersion: 1})
+	assert.ErrorIs(t, err, repository.ErrVersionConflict)
+
+	items := getItem(t, c.nodes[leader], 1)
+	require.Len(t, items, 1)
+	assert.Equal(t, "AA", items[0].Payload)
+	c.waitConverged(leader, c.ids()...)
+
+	for id, node := range c.nodes {
+		if id == leader {
+			continue
+		}
+		err := NewItemService(node).ProcessItemCommand(ctx, &models.Command{Type: models.CommandType_AddItem, ItemID: 4})
+		assert.ErrorIs(t, err, ErrNotLeader)
+		assert.False(t, c.leading[id].Load())
+
+		// followers serve queries from their registries
+		items := getItem(t, node, 1)
+		require.Len(t, items, 1)
+		assert.Equal(t, "AA", items[0].Payload)
+	}
+
+	// expired item is removed from all nodes by the leader
+	require.Eventually(t, func() bool {
+		return len(getItem(t, c.nodes[leader], 2)) == 0
+	}, waitTimeout, 50*time.Millisecond)
+	c.waitConverged(leader, c.ids()...)
+}
+
+func TestNode_Partition(t *testing.T) {
+	c := newTestCluster(t, 3)
+	oldLeader := c.leader(c.ids()...)
+
+	ctx := context.Background()
+	require.NoError(t, NewItemService(c.nodes[oldLeader]).ProcessItemCommand(ctx, &models.Command{Type: models.CommandType_AddItem, ItemID: 1, ItemPayload: "A"}))
+
+	c.partition(oldLeader)
+	var majority []string
+	for _, id := range c.ids() {
+		if id != oldLeader {
+			majority = append(majority, id)
+		}
+	}
+	newLeader := c.leader(majority...)
+
+	// the isolated leader cannot commit and steps down
+	err := NewItemService(c.nodes[oldLeader]).ProcessItemCommand(ctx, &models.Command{Type: models.CommandType_AddItem, ItemID: 2, ItemPayload: "B"})
+	assert.Error(t, err)
+	assert.Eventually(t, func() bool {
+		return !c.leading[oldLeader].Load()
+	}, waitTimeout, 10*time.Millisecond)
+
+	require.NoError(t, NewItemService(c.nodes[newLeader]).ProcessItemCommand(ctx, &models.Command{Type: models.CommandType_AddItem, ItemID: 3, ItemPayload: "C"}))
+	assert.Empty(t, getItem(t, c.nodes[newLeader], 2))
+
+	// the old leader discards not committed entry and catches up
+	c.heal(oldLeader)
+	c.waitConverged(newLeader, c.ids()...)
+	assert.Len(t, getItem(t, c.nodes[c.leader(c.ids()...)], 3), 1)
+}
+
+func TestNode_SnapshotAndMembership(t *testing.T) {
+	c := newTestCluster(t, 3)
+	leader := c.leader(c.ids()...)
+
+	ctx := context.Background()
+	itemService := NewItemService(c.nodes[leader])
+	for i := int64(1); i <= 5; i++ {
+		require.NoError(t, itemService.ProcessItemCommand(ctx, &models.Command{Type: models.CommandType_AddItem, ItemID: i, ItemPayload: "A"}))
+	}
+	require.NoError(t, c.nodes[leader].Snapshot())
+
+	// new node gets the state from the snapshot of the leader
+	c.addNode("node4", false, nil)
+	require.NoError(t, c.nodes[leader].AddVoter(Peer{ID: "node4", Addr: "node4"}))
+	c.waitConverged(leader, "node4")
+
+	status, err := c.nodes[leader].Status()
+	require.NoError(t, err)
+	assert.Equal(t, leader, status.ID)
+	assert.Equal(t, raft.Leader.String(), status.State)
+	assert.Equal(t, leader, status.Leader.ID)
+	assert.Len(t, status.Servers, 4)
+
+	assert.ErrorIs(t, c.nodes["node4"].AddVoter(Peer{ID: "node5", Addr: "node5"}), ErrNotLeader)
+	assert.ErrorIs(t, c.nodes["node4"].RemoveServer(leader), ErrNotLeader)
+
+	require.NoError(t, c.nodes[leader].RemoveServer("node4"))
+	status, err = c.nodes[leader].Status()
+	require.NoError(t, err)
+	assert.Len(t, status.Servers, 3)
+}
@@ -0,0 +1,107 @@
+package consensus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	statusPath  = "/raft/status"
+	membersPath = "/raft/members/"
+)
+
+// Server serves status of the node on GET /raft/status and changes membership of the cluster:
+// POST /raft/members/ with {"id": ..., "addr": ...} adds the voter, DELETE /raft/members/{id} removes the node.
+// Membership changes are served by the leader only, followers respond with 503 Service Unavailable.
+type Server struct {
+	httpServer *http.Server
+}
+
+// NewServer returns the server of the node, every request is passed to the endpoints through authenticate.
+func NewServer(addr string, node *Node, authenticate func(next http.Handler) http.Handler) *Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc(statusPath, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		status, err := node.Status()
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, status)
+	})
+	mux.HandleFunc(membersPath, func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		switch r.Method {
+		case http.MethodPost:
+			var peer Peer
+			if err := json.NewDecoder(r.Body).Decode(&peer); err != nil || peer.ID == "" || peer.Addr == "" {
+				http.Error(w, "id and addr of the node are required", http.StatusBadRequest)
+				return
+			}
+			err = node.AddVoter(peer)
+		case http.MethodDelete:
+			id := strings.TrimPrefix(r.URL.Path, membersPath)
+			if id == "" {
+				http.Error(w, "id of the node is required", http.StatusBadRequest)
+				return
+			}
+			err = node.RemoveServer(id)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	return &Server{
+		httpServer: &http.Server{
+			Addr:              addr,
+			Handler:           authenticate(mux),
+			ReadHeaderTimeout: 5 * time.Second,
+		},
+	}
+}
+
+func (s *Server) Start() {
+	go func() {
+		log.Infof("Raft membership is served on %s", s.httpServer.Addr)
+		if err := s.httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("Raft membership server stopped: %v", err)
+		}
+	}()
+}
+
+func (s *Server) Quit() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return s.httpServer.Shutdown(ctx)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Errorf("Cannot write response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, ErrNotLeader) {
+		status = http.StatusServiceUnavailable
+	}
+	http.Error(w, err.Error(), status)
+}
@@ -0,0 +1,52 @@
+package consensus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_Authenticate(t *testing.T) {
+	c := newTestCluster(t, 2)
+	leader := c.leader(c.ids()...)
+
+	authenticate := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	httpServer := httptest.NewServer(NewServer("", c.nodes[leader], authenticate).httpServer.Handler)
+	defer httpServer.Close()
+
+	do := func(method, path, token string) int {
+		req, err := http.NewRequest(method, httpServer.URL+path, nil)
+		require.NoError(t, err)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	follower := "node1"
+	if leader == follower {
+		follower = "node2"
+	}
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, statusPath, ""))
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodDelete, membersPath+follower, "wrong"))
+	status, err := c.nodes[leader].Status()
+	require.NoError(t, err)
+	assert.Len(t, status.Servers, 2, "membership is not changed by unauthenticated requests")
+
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, statusPath, "secret"))
+	assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, membersPath+follower, "secret"))
+}
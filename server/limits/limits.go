@@ -0,0 +1,322 @@
+package limits
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/metrics"
+)
+
+// Actions applied to messages which exceed the limits.
+const (
+	// ActionRequeue returns the message to the queue when the limit allows it again.
+	ActionRequeue = "requeue"
+	// ActionReject moves the message to the dead-letter queue.
+	ActionReject = "reject"
+)
+
+const defaultQuotaPeriod = 24 * time.Hour
+
+var (
+	ErrRateLimited   = errors.New("rate limit exceeded")
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	ErrInvalidLimits = errors.New("invalid limits")
+)
+
+// Limits of a client, zero values mean no limit.
+type Limits struct {
+	// Rate is the number of messages per second, messages over it are limited.
+	Rate float64
+	// Burst is the number of messages which can be received at once, Rate rounded up by default.
+	Burst int
+	// QuotaPeriod is the period of quotas, 24h by default.
+	QuotaPeriod time.Duration
+	// MaxItems is the number of items the client can add (AddItem commands) in the quota period.
+	MaxItems int64
+	// MaxBytes is the size of payloads the client can add or update in the quota period.
+	MaxBytes int64
+	// Action is applied to messages over the limits: requeue (default) or reject.
+	Action string
+}
+
+// Validate checks that the limits are not negative and the action is known.
+func (l Limits) Validate() error {
+	_, err := l.withDefaults()
+	return err
+}
+
+func (l Limits) withDefaults() (Limits, error) {
+	if l.Rate < 0 || l.Burst < 0 || l.QuotaPeriod < 0 || l.MaxItems < 0 || l.MaxBytes < 0 {
+		return Limits{}, fmt.Errorf("%w: limits cannot be negative", ErrInvalidLimits)
+	}
+	if l.Burst == 0 {
+		l.Burst = int(math.Ceil(l.Rate))
+	}
+	if l.QuotaPeriod == 0 {
+		l.QuotaPeriod = defaultQuotaPeriod
+	}
+	switch l.Action {
+	case "":
+		l.Action = ActionRequeue
+	case ActionRequeue, ActionReject:
+	default:
+		return Limits{}, fmt.Errorf("%w: unknown action %q", ErrInvalidLimits, l.Action)
+	}
+	return l, nil
+}
+
+// ExceededError is returned for messages over the limits of the client.
+type ExceededError struct {
+	// Err is ErrRateLimited or ErrQuotaExceeded.
+	Err      error
+	ClientID string
+	// Action should be applied to the message.
+	Action string
+	// RetryAfter is when the limit allows the message again.
+	RetryAfter time.Duration
+}
+
+func (e *ExceededError) Error() string {
+	if e.RetryAfter == 0 {
+		return fmt.Sprintf("%v for client %q", e.Err, e.ClientID)
+	}
+	return fmt.Sprintf("%v for client %q, retry after %s", e.Err, e.ClientID, e.RetryAfter)
+}
+
+func (e *ExceededError) Unwrap() error {
+	return e.Err
+}
+
+// Usage is the state of the limits of a client.
+type Usage struct {
+	ClientID string `json:"client_id"`
+	// Tokens is the number of messages which can be received now.
+	Tokens float64 `json:"tokens"`
+	// Items and Bytes are used in the current quota period which started at PeriodStartedAt.
+	Items           int64     `json:"items"`
+	Bytes           int64     `json:"bytes"`
+	PeriodStartedAt time.Time `json:"period_started_at"`
+	Messages        int64     `json:"messages_total"`
+	Limited         int64     `json:"limited_total"`
+}
+
+type usage struct {
+	tokens          float64
+	updatedAt       time.Time
+	items           int64
+	bytes           int64
+	periodStartedAt time.Time
+	messages        int64
+	limited         int64
+	quotaItems      *expvar.Int
+	quotaBytes      *expvar.Int
+}
+
+// Limiter keeps token buckets and quotas of clients.
+type Limiter struct {
+	defaults Limits
+	clients  map[string]Limits
+	usage    map[string]*usage
+	now      func() time.Time
+	mx       sync.Mutex
+}
+
+type Option func(l *Limiter)
+
+// WithClock sets the source of the current time, time.Now by default.
+func WithClock(now func() time.Time) Option {
+	return func(l *Limiter) {
+		l.now = now
+	}
+}
+
+// New returns the limiter with limits by client id, defaults are applied to other clients.
+func New(defaults Limits, clients map[string]Limits, opts ...Option) (*Limiter, error) {
+	l := &Limiter{
+		usage: make(map[string]*usage),
+		now:   time.Now,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	if err := l.SetLimits(defaults, clients); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// SetLimits replaces limits of clients, usage of clients is kept and is checked against the new limits.
+// Limits are not changed when any of them is invalid.
+func (l *Limiter) SetLimits(defaults Limits, clients map[string]Limits) error {
+	defaults, err := defaults.withDefaults()
+	if err != nil {
+		return err
+	}
+	clientLimits := make(map[string]Limits, len(clients))
+	for clientID, limits := range clients {
+		if clientLimits[clientID], err = limits.withDefaults(); err != nil {
+			return fmt.Errorf("client %s: %w", clientID, err)
+		}
+	}
+
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	l.defaults, l.clients = defaults, clientLimits
+	return nil
+}
+
+// Allow counts the message with the commands of the client and returns ExceededError when it is over the limits.
+// Limited messages do not use the limits. Message which is larger than the quota is never allowed, so it has
+// the reject action. Quota used by the message should be refunded when its commands are not processed.
+func (l *Limiter) Allow(clientID string, commands ...*models.Command) error {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	limits := l.limitsOf(clientID)
+	now := l.now()
+	u, ok := l.usage[clientID]
+	if !ok {
+		u = &usage{
+			tokens:          float64(limits.Burst),
+			updatedAt:       now,
+			periodStartedAt: now,
+			quotaItems:      metrics.Gauge(metrics.ClientQuotaItems, clientID),
+			quotaBytes:      metrics.Gauge(metrics.ClientQuotaBytes, clientID),
+		}
+		l.usage[clientID] = u
+	}
+	l.refill(u, limits, now)
+
+	u.messages++
+	metrics.ClientMessages.Add(clientID, 1)
+	exceeded := func(err error, retryAfter time.Duration) *ExceededError {
+		u.limited++
+		metrics.ClientLimited.Add(clientID, 1)
+		return &ExceededError{Err: err, ClientID: clientID, Action: limits.Action, RetryAfter: retryAfter}
+	}
+
+	if limits.Rate > 0 && u.tokens < 1 {
+		return exceeded(ErrRateLimited, time.Duration((1-u.tokens)/limits.Rate*float64(time.Second)))
+	}
+
+	items, bytes := quotaOf(commands)
+	if limits.MaxItems > 0 && items > limits.MaxItems || limits.MaxBytes > 0 && bytes > limits.MaxBytes {
+		// the message does not fit into any quota period, so it would be requeued forever
+		err := exceeded(fmt.Errorf("%w: message of %d items and %d bytes is larger than the quota", ErrQuotaExceeded,
+			items, bytes), 0)
+		err.Action = ActionReject
+		return err
+	}
+	periodEnd := u.periodStartedAt.Add(limits.QuotaPeriod).Sub(now)
+	if limits.MaxItems > 0 && u.items+items > limits.MaxItems {
+		return exceeded(fmt.Errorf("%w: %d of %d items are added", ErrQuotaExceeded, u.items, limits.MaxItems), periodEnd)
+	}
+	if limits.MaxBytes > 0 && u.bytes+bytes > limits.MaxBytes {
+		return exceeded(fmt.Errorf("%w: %d of %d bytes are used", ErrQuotaExceeded, u.bytes, limits.MaxBytes), periodEnd)
+	}
+
+	if limits.Rate > 0 {
+		u.tokens--
+	}
+	u.items += items
+	u.bytes += bytes
+	u.quotaItems.Set(u.items)
+	u.quotaBytes.Set(u.bytes)
+	return nil
+}
+
+// Refund returns the quota used by the commands of the allowed message which are not processed. The rate is not
+// refunded, since the message is received anyway.
+func (l *Limiter) Refund(clientID string, commands ...*models.Command) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	u, ok := l.usage[clientID]
+	if !ok {
+		return
+	}
+	l.refill(u, l.limitsOf(clientID), l.now())
+
+	// usage is not reduced below zero when a new period is started while the commands are processed
+	items, bytes := quotaOf(commands)
+	u.items = max64(u.items-items, 0)
+	u.bytes = max64(u.bytes-bytes, 0)
+	u.quotaItems.Set(u.items)
+	u.quotaBytes.Set(u.bytes)
+}
+
+// quotaOf returns the number of added items and the size of payloads of the commands.
+func quotaOf(commands []*models.Command) (items, bytes int64) {
+	for _, command := range commands {
+		switch command.Type {
+		case models.CommandType_AddItem:
+			items++
+			bytes += int64(len(command.ItemPayload))
+		case models.CommandType_UpdateItem:
+			bytes += int64(len(command.ItemPayload))
+		}
+	}
+	return items, bytes
+}
+
+func max64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// Usage returns usage of all clients which sent messages, sorted by client id.
+func (l *Limiter) Usage() []Usage {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	now := l.now()
+	result := make([]Usage, 0, len(l.usage))
+	for clientID, u := range l.usage {
+		limits := l.limitsOf(clientID)
+		l.refill(u, limits, now)
+		result = append(result, Usage{
+			ClientID:        clientID,
+			Tokens:          u.tokens,
+			Items:           u.items,
+			Bytes:           u.bytes,
+			PeriodStartedAt: u.periodStartedAt,
+			Messages:        u.messages,
+			Limited:         u.limited,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ClientID < result[j].ClientID })
+	return result
+}
+
+func (l *Limiter) limitsOf(clientID string) Limits {
+	if limits, ok := l.clients[clientID]; ok && clientID != "" {
+		return limits
+	}
+	return l.defaults
+}
+
+// refill adds tokens for the time since the last update and starts a new quota period when the current one is over.
+func (l *Limiter) refill(u *usage, limits Limits, now time.Time) {
+	if elapsed := now.Sub(u.updatedAt); elapsed > 0 {
+		u.tokens = math.Min(float64(limits.Burst), u.tokens+elapsed.Seconds()*limits.Rate)
+		u.updatedAt = now
+	}
+
+	if periods := now.Sub(u.periodStartedAt) / limits.QuotaPeriod; periods > 0 {
+		u.periodStartedAt = u.periodStartedAt.Add(periods * limits.QuotaPeriod)
+		u.items = 0
+		u.bytes = 0
+		u.quotaItems.Set(0)
+		u.quotaBytes.Set(0)
+	}
+}
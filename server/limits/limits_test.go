@@ -0,0 +1,135 @@
+package limits
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func addItem(payload string) *models.Command {
+	return &models.Command{Type: models.CommandType_AddItem, ItemID: 1, ItemPayload: payload}
+}
+
+func TestLimiter_Rate(t *testing.T) {
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	l, err := New(Limits{}, map[string]Limits{"producer": {Rate: 2, Burst: 3}}, WithClock(func() time.Time { return now }))
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, l.Allow("producer", addItem("A")), "burst")
+	}
+	err = l.Allow("producer", addItem("A"))
+	var exceeded *ExceededError
+	require.ErrorAs(t, err, &exceeded)
+	assert.ErrorIs(t, err, ErrRateLimited)
+	assert.Equal(t, ActionRequeue, exceeded.Action)
+	assert.Equal(t, 500*time.Millisecond, exceeded.RetryAfter)
+
+	// other clients are not limited
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, l.Allow("", addItem("A")))
+	}
+
+	now = now.Add(time.Second)
+	assert.NoError(t, l.Allow("producer", addItem("A")))
+	assert.NoError(t, l.Allow("producer", addItem("A")))
+	assert.ErrorIs(t, l.Allow("producer", addItem("A")), ErrRateLimited)
+
+	usage := l.Usage()
+	require.Len(t, usage, 2)
+	assert.Equal(t, Usage{ClientID: "", Items: 10, Bytes: 10, PeriodStartedAt: usage[0].PeriodStartedAt, Messages: 10}, usage[0])
+	assert.Equal(t, "producer", usage[1].ClientID)
+	assert.Equal(t, int64(7), usage[1].Messages)
+	assert.Equal(t, int64(2), usage[1].Limited)
+	assert.Equal(t, int64(5), usage[1].Items)
+}
+
+func TestLimiter_Quota(t *testing.T) {
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	l, err := New(Limits{QuotaPeriod: time.Hour, MaxItems: 2, MaxBytes: 10, Action: ActionReject}, nil,
+		WithClock(func() time.Time { return now }))
+	require.NoError(t, err)
+
+	require.NoError(t, l.Allow("producer", addItem("AAAA")))
+	// updates use bytes only
+	require.NoError(t, l.Allow("producer", &models.Command{Type: models.CommandType_UpdateItem, ItemID: 1, ItemPayload: "BBBB"}))
+	require.NoError(t, l.Allow("producer", &models.Command{Type: models.CommandType_RemoveItem, ItemID: 1}))
+
+	now = now.Add(15 * time.Minute)
+	err = l.Allow("producer", addItem("CCC"))
+	var exceeded *ExceededError
+	require.ErrorAs(t, err, &exceeded)
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+	assert.True(t, strings.Contains(err.Error(), "8 of 10 bytes"), err.Error())
+	assert.Equal(t, ActionReject, exceeded.Action)
+	assert.Equal(t, 45*time.Minute, exceeded.RetryAfter)
+
+	require.NoError(t, l.Allow("producer", addItem("C")))
+	assert.ErrorIs(t, l.Allow("producer", addItem("")), ErrQuotaExceeded, "items quota")
+
+	// all commands of the batch are counted
+	now = now.Add(time.Hour)
+	assert.ErrorIs(t, l.Allow("producer", addItem("A"), addItem("B"), addItem("C")), ErrQuotaExceeded)
+	assert.NoError(t, l.Allow("producer", addItem("A"), addItem("B")))
+
+	usage := l.Usage()
+	require.Len(t, usage, 1)
+	assert.Equal(t, int64(2), usage[0].Items)
+	assert.Equal(t, time.Date(2022, 10, 1, 13, 0, 0, 0, time.UTC), usage[0].PeriodStartedAt)
+}
+
+func TestLimiter_Refund(t *testing.T) {
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	l, err := New(Limits{QuotaPeriod: time.Hour, MaxItems: 2, MaxBytes: 10}, nil, WithClock(func() time.Time { return now }))
+	require.NoError(t, err)
+
+	require.NoError(t, l.Allow("producer", addItem("AAAA"), addItem("BBBB")))
+	assert.ErrorIs(t, l.Allow("producer", addItem("C")), ErrQuotaExceeded)
+	l.Refund("producer", addItem("BBBB"))
+	require.NoError(t, l.Allow("producer", addItem("C")))
+	assert.Equal(t, int64(2), l.Usage()[0].Items)
+	assert.Equal(t, int64(5), l.Usage()[0].Bytes)
+
+	// usage of the new period is not reduced below zero
+	now = now.Add(time.Hour)
+	l.Refund("producer", addItem("C"))
+	assert.Zero(t, l.Usage()[0].Items)
+	assert.Zero(t, l.Usage()[0].Bytes)
+
+	// message larger than the quota is rejected even with the requeue action
+	err = l.Allow("producer", addItem("A"), addItem("B"), addItem("C"))
+	var exceeded *ExceededError
+	require.ErrorAs(t, err, &exceeded)
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+	assert.Equal(t, ActionReject, exceeded.Action)
+	assert.Zero(t, l.Usage()[0].Items)
+}
+
+func TestNew_InvalidLimits(t *testing.T) {
+	_, err := New(Limits{Action: "drop"}, nil)
+	assert.ErrorIs(t, err, ErrInvalidLimits)
+
+	_, err = New(Limits{}, map[string]Limits{"producer": {Rate: -1}})
+	assert.ErrorIs(t, err, ErrInvalidLimits)
+}
+
+func TestLimiter_SetLimits(t *testing.T) {
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	l, err := New(Limits{MaxItems: 2}, nil, WithClock(func() time.Time { return now }))
+	require.NoError(t, err)
+
+	require.NoError(t, l.Allow("producer", addItem("A"), addItem("B")))
+	assert.ErrorIs(t, l.Allow("producer", addItem("C")), ErrQuotaExceeded)
+
+	assert.ErrorIs(t, l.SetLimits(Limits{}, map[string]Limits{"producer": {Action: "drop"}}), ErrInvalidLimits)
+	assert.ErrorIs(t, l.Allow("producer", addItem("C")), ErrQuotaExceeded, "invalid limits are not applied")
+
+	require.NoError(t, l.SetLimits(Limits{MaxItems: 2}, map[string]Limits{"producer": {MaxItems: 3}}))
+	assert.NoError(t, l.Allow("producer", addItem("C")))
+	assert.ErrorIs(t, l.Allow("producer", addItem("D")), ErrQuotaExceeded, "usage is kept")
+	assert.Equal(t, int64(3), l.Usage()[0].Items)
+}
@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"net/http"
+	"time"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/logging"
+)
+
+var log = logging.Logger("server/metrics")
+
+// All storage metrics are maps by collection name.
+var (
+	// StoredItems is the number of items currently kept in the storage.
+	StoredItems = expvar.NewMap("stored_items")
+	// StoredBytes is the total size of payloads currently kept in the storage.
+	StoredBytes = expvar.NewMap("stored_bytes")
+	// EvictedItems counts items evicted because of the capacity limits.
+	EvictedItems = expvar.NewMap("evicted_items_total")
+	// RejectedItems counts items which were not added because of the capacity limits.
+	RejectedItems = expvar.NewMap("rejected_items_total")
+	// ExpiredItems counts items removed because their TTL elapsed.
+	ExpiredItems = expvar.NewMap("expired_items_total")
+)
+
+// Replication metrics are set only on replicas.
+var (
+	// ReplicatedSequence is the sequence number of the last change of the primary applied by the replica.
+	ReplicatedSequence = expvar.NewInt("replicated_sequence")
+	// ReplicationLag is the time in seconds between the change on the primary and applying it on the replica.
+	ReplicationLag = expvar.NewFloat("replication_lag_seconds")
+)
+
+// Client metrics are maps by client id, messages without client id are counted under "".
+var (
+	// ClientMessages counts messages received from the client.
+	ClientMessages = expvar.NewMap("client_messages_total")
+	// ClientLimited counts messages which exceeded rate limits or quotas of the client.
+	ClientLimited = expvar.NewMap("client_limited_total")
+	// ClientQuotaItems is the number of items added by the client in the current quota period.
+	ClientQuotaItems = expvar.NewMap("client_quota_items")
+	// ClientQuotaBytes is the size of payloads added or updated by the client in the current quota period.
+	ClientQuotaBytes = expvar.NewMap("client_quota_bytes")
+)
+
+// Gauge returns a value which is kept in the map under the collection name.
+func Gauge(m *expvar.Map, collection string) *expvar.Int {
+	gauge := new(expvar.Int)
+	m.Set(collection, gauge)
+	return gauge
+}
+
+// DeleteCollection removes all storage metrics of the dropped collection.
+func DeleteCollection(collection string) {
+	for _, m := range []*expvar.Map{StoredItems, StoredBytes, EvictedItems, RejectedItems, ExpiredItems} {
+		m.Delete(collection)
+	}
+}
+
+// Server exposes the metrics in JSON format on /debug/vars.
+type Server struct {
+	httpServer *http.Server
+}
+
+func NewServer(addr string) *Server {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	return &Server{
+		httpServer: &http.Server{
+			Addr:              addr,
+			Handler:           mux,
+			ReadHeaderTimeout: 5 * time.Second,
+		},
+	}
+}
+
+func (s *Server) Start() {
+	go func() {
+		log.Infof("Metrics are served on %s/debug/vars", s.httpServer.Addr)
+		if err := s.httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("Metrics server stopped: %v", err)
+		}
+	}()
+}
+
+func (s *Server) Quit() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return s.httpServer.Shutdown(ctx)
+}
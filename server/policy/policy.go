@@ -0,0 +1,343 @@
+package policy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/logging"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/repository"
+	"gopkg.in/yaml.v3"
+)
+
+var log = logging.Logger("server/policy")
+
+const defaultReloadInterval = 5 * time.Second
+
+var (
+	ErrDenied        = errors.New("command is denied")
+	ErrInvalidPolicy = errors.New("invalid policy")
+)
+
+// Policy is the set of rules by client id. A command is allowed when any rule of its client allows it,
+// all other commands are denied.
+type Policy struct {
+	// Clients are rules of the clients by their id.
+	Clients map[string][]Rule `yaml:"clients"`
+	// Default rules are applied to clients which are not listed, including messages without client id.
+	Default []Rule `yaml:"default"`
+}
+
+// Rule allows commands which match all its conditions, an empty condition matches any command.
+type Rule struct {
+	// Commands are allowed command types, e.g. AddItem.
+	Commands []string `yaml:"commands"`
+	// Collections are allowed collection names, a name ending with "*" is a prefix, e.g. "cache-*".
+	// The default collection is "default".
+	Collections []string `yaml:"collections"`
+	// ItemIDs are allowed ranges of item ids. A rule with ranges allows only commands of single items:
+	// AddItem, UpdateItem, RemoveItem and GetItem.
+	ItemIDs []IDRange `yaml:"itemids"`
+}
+
+// IDRange is the range of item ids including both bounds.
+type IDRange struct {
+	Min int64 `yaml:"min"`
+	Max int64 `yaml:"max"`
+}
+
+// Parse reads the policy in YAML.
+func Parse(data []byte) (*Policy, error) {
+	var policy Policy
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&policy); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPolicy, err)
+	}
+
+	validate := func(rules []Rule) error {
+		for _, rule := range rules {
+			for _, commandType := range rule.Commands {
+				if _, ok := models.CommandType_value[commandType]; !ok {
+					return fmt.Errorf("%w: unknown command type %s", ErrInvalidPolicy, commandType)
+				}
+			}
+			for _, idRange := range rule.ItemIDs {
+				if idRange.Min > idRange.Max {
+					return fmt.Errorf("%w: item id range [%d, %d] is empty", ErrInvalidPolicy, idRange.Min, idRange.Max)
+				}
+			}
+		}
+		return nil
+	}
+	if err := validate(policy.Default); err != nil {
+		return nil, err
+	}
+	for clientID, rules := range policy.Clients {
+		if err := validate(rules); err != nil {
+			return nil, fmt.Errorf("client %s: %w", clientID, err)
+		}
+	}
+	return &policy, nil
+}
+
+// Authorize returns ErrDenied when no rule of the client allows the command.
+func (p *Policy) Authorize(clientID string, command *models.Command) error {
+	rules, ok := p.Clients[clientID]
+	if !ok || clientID == "" {
+		rules = p.Default
+	}
+
+	for _, rule := range rules {
+		if rule.allows(command) {
+			return nil
+		}
+	}
+
+	client := clientID
+	if client == "" {
+		client = "anonymous client"
+	}
+	return fmt.Errorf("%w: %s to collection %s is not allowed for %s",
+		ErrDenied, describe(command), collectionOf(command), client)
+}
+
+func (r Rule) allows(command *models.Command) bool {
+	if len(r.Commands) > 0 && !contains(r.Commands, command.Type.String()) {
+		return false
+	}
+
+	if len(r.Collections) > 0 {
+		collection := collectionOf(command)
+		matched := false
+		for _, pattern := range r.Collections {
+			if prefix := strings.TrimSuffix(pattern, "*"); prefix != pattern {
+				matched = strings.HasPrefix(collection, prefix)
+			} else {
+				matched = collection == pattern
+			}
+			if matched {
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if len(r.ItemIDs) > 0 {
+		if !isItemCommand(command.Type) {
+			return false
+		}
+		for _, idRange := range r.ItemIDs {
+			if command.ItemID >= idRange.Min && command.ItemID <= idRange.Max {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func isItemCommand(commandType models.CommandType) bool {
+	switch commandType {
+	case models.CommandType_AddItem, models.CommandType_UpdateItem, models.CommandType_RemoveItem, models.CommandType_GetItem:
+		return true
+	}
+	return false
+}
+
+func describe(command *models.Command) string {
+	if isItemCommand(command.Type) {
+		return fmt.Sprintf("%s of item %d", command.Type, command.ItemID)
+	}
+	return command.Type.String()
+}
+
+func collectionOf(command *models.Command) string {
+	if command.Collection == "" {
+		return repository.DefaultCollection
+	}
+	return command.Collection
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Engine authorizes commands by the policy of the file, the file is reloaded when it is changed.
+type Engine struct {
+	file     string
+	interval time.Duration
+	// policy is nil when the file is not set
+	policy  *Policy
+	modTime time.Time
+	mx      sync.RWMutex
+	// reset restarts the ticker when the interval is changed
+	reset chan struct{}
+	quit  chan struct{}
+	done  chan struct{}
+}
+
+// NewEngine loads the policy of the file, which is checked for changes every interval (5s by default).
+// All commands are allowed when the file is empty.
+func NewEngine(file string, interval time.Duration) (*Engine, error) {
+	e := &Engine{
+		reset: make(chan struct{}, 1),
+		quit:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	if err := e.SetFile(file, interval); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// SetFile loads the policy of another file and changes the interval of checks. The current policy is kept
+// when the new one cannot be loaded.
+func (e *Engine) SetFile(file string, interval time.Duration) error {
+	if interval <= 0 {
+		interval = defaultReloadInterval
+	}
+
+	var policy *Policy
+	var modTime time.Time
+	if file != "" {
+		var err error
+		if policy, modTime, err = readPolicy(file); err != nil {
+			return err
+		}
+	}
+
+	e.mx.Lock()
+	defer e.mx.Unlock()
+
+	e.file, e.policy, e.modTime = file, policy, modTime
+	if e.interval != interval {
+		e.interval = interval
+		select {
+		case e.reset <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Authorize returns ErrDenied when the current policy does not allow the command of the client.
+func (e *Engine) Authorize(clientID string, command *models.Command) error {
+	e.mx.RLock()
+	defer e.mx.RUnlock()
+
+	if e.policy == nil {
+		return nil
+	}
+	return e.policy.Authorize(clientID, command)
+}
+
+func (e *Engine) Start() {
+	go func() {
+		defer close(e.done)
+
+		ticker := time.NewTicker(e.currentInterval())
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				reloaded, err := e.reload()
+				if err != nil {
+					log.Errorf("Cannot reload policy, the previous one is used: %v", err)
+					continue
+				}
+				if reloaded {
+					log.Infof("Policy is reloaded from %s", e.currentFile())
+				}
+			case <-e.reset:
+				ticker.Reset(e.currentInterval())
+			case <-e.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Quit stops watching the file.
+func (e *Engine) Quit() {
+	close(e.quit)
+	<-e.done
+}
+
+func (e *Engine) currentInterval() time.Duration {
+	e.mx.RLock()
+	defer e.mx.RUnlock()
+
+	return e.interval
+}
+
+func (e *Engine) currentFile() string {
+	e.mx.RLock()
+	defer e.mx.RUnlock()
+
+	return e.file
+}
+
+// reload reads the file when it is modified since the last load. Invalid policy is not applied.
+func (e *Engine) reload() (bool, error) {
+	e.mx.RLock()
+	file, modTime := e.file, e.modTime
+	e.mx.RUnlock()
+	if file == "" {
+		return false, nil
+	}
+
+	info, err := os.Stat(file)
+	if err != nil {
+		return false, err
+	}
+	if info.ModTime().Equal(modTime) {
+		return false, nil
+	}
+
+	policy, modTime, err := readPolicy(file)
+	if err != nil {
+		return false, err
+	}
+
+	e.mx.Lock()
+	defer e.mx.Unlock()
+
+	// the file is not applied when it is replaced by SetFile meanwhile
+	if e.file != file {
+		return false, nil
+	}
+	e.policy = policy
+	e.modTime = modTime
+	return true, nil
+}
+
+// readPolicy reads the policy of the file and the time the file is modified.
+func readPolicy(file string) (*Policy, time.Time, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	policy, err := Parse(data)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return policy, info.ModTime(), nil
+}
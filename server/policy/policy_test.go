@@ -0,0 +1,179 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPolicy = `
+clients:
+  producer:
+    - commands: [AddItem, UpdateItem, RemoveItem]
+      collections: [sessions, "cache-*"]
+      itemids:
+        - {min: 1, max: 100}
+        - {min: 1000, max: 1000}
+    - commands: [GetItem, GetAllItems]
+  admin:
+    - {}
+default:
+  - commands: [GetAllItems]
+    collections: [default]
+`
+
+func TestPolicy_Authorize(t *testing.T) {
+	policy, err := Parse([]byte(testPolicy))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		clientID string
+		command  *models.Command
+		wantErr  error
+	}{
+		{
+			name:     "should allow command matching all conditions",
+			clientID: "producer",
+			command:  &models.Command{Type: models.CommandType_AddItem, ItemID: 100, Collection: "sessions"},
+		},
+		{
+			name:     "should allow collection by prefix",
+			clientID: "producer",
+			command:  &models.Command{Type: models.CommandType_RemoveItem, ItemID: 1000, Collection: "cache-eu"},
+		},
+		{
+			name:     "should deny item out of ranges",
+			clientID: "producer",
+			command:  &models.Command{Type: models.CommandType_AddItem, ItemID: 101, Collection: "sessions"},
+			wantErr:  ErrDenied,
+		},
+		{
+			name:     "should deny other collection",
+			clientID: "producer",
+			command:  &models.Command{Type: models.CommandType_AddItem, ItemID: 1, Collection: "cache"},
+			wantErr:  ErrDenied,
+		},
+		{
+			name:     "should deny other command type",
+			clientID: "producer",
+			command:  &models.Command{Type: models.CommandType_DropCollection, Collection: "sessions"},
+			wantErr:  ErrDenied,
+		},
+		{
+			name:     "should allow command by any rule of the client",
+			clientID: "producer",
+			command:  &models.Command{Type: models.CommandType_GetAllItems, Collection: "other"},
+		},
+		{
+			name:     "should allow everything by empty rule",
+			clientID: "admin",
+			command:  &models.Command{Type: models.CommandType_DropCollection, Collection: "sessions"},
+		},
+		{
+			name:     "should apply default rules to unknown client",
+			clientID: "unknown",
+			command:  &models.Command{Type: models.CommandType_GetAllItems},
+		},
+		{
+			name:    "should apply default rules to anonymous client",
+			command: &models.Command{Type: models.CommandType_RemoveItem, ItemID: 1},
+			wantErr: ErrDenied,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Authorize(tt.clientID, tt.command)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+	}{
+		{name: "should reject unknown command type", policy: "default: [{commands: [Truncate]}]"},
+		{name: "should reject empty id range", policy: "clients: {a: [{itemids: [{min: 2, max: 1}]}]}"},
+		{name: "should reject unknown field", policy: "default: [{collection: [sessions]}]"},
+		{name: "should reject invalid yaml", policy: "clients: ["},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.policy))
+			assert.ErrorIs(t, err, ErrInvalidPolicy)
+		})
+	}
+}
+
+func TestEngine_Reload(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "policy.yaml")
+	write := func(policy string, modTime time.Time) {
+		require.NoError(t, os.WriteFile(file, []byte(policy), 0o600))
+		require.NoError(t, os.Chtimes(file, modTime, modTime))
+	}
+	modTime := time.Now().Add(-time.Hour)
+	write("default: [{commands: [GetItem]}]", modTime)
+
+	e, err := NewEngine(file, time.Hour)
+	require.NoError(t, err)
+	getItem := &models.Command{Type: models.CommandType_GetItem, ItemID: 1}
+	addItem := &models.Command{Type: models.CommandType_AddItem, ItemID: 1}
+	assert.NoError(t, e.Authorize("", getItem))
+	assert.ErrorIs(t, e.Authorize("", addItem), ErrDenied)
+
+	write("default: [{commands: [AddItem]}]", modTime.Add(time.Second))
+	reloaded, err := e.reload()
+	require.NoError(t, err)
+	assert.True(t, reloaded)
+	assert.ErrorIs(t, e.Authorize("", getItem), ErrDenied)
+	assert.NoError(t, e.Authorize("", addItem))
+
+	// invalid policy is not applied
+	write("default: [{commands: [Truncate]}]", modTime.Add(2*time.Second))
+	_, err = e.reload()
+	assert.ErrorIs(t, err, ErrInvalidPolicy)
+	assert.NoError(t, e.Authorize("", addItem))
+
+	reloaded, err = e.reload()
+	assert.False(t, reloaded)
+	assert.ErrorIs(t, err, ErrInvalidPolicy, "invalid file is read again until it is fixed")
+
+	_, err = NewEngine(filepath.Join(t.TempDir(), "missing.yaml"), 0)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestEngine_SetFile(t *testing.T) {
+	dir := t.TempDir()
+	getItems := filepath.Join(dir, "get.yaml")
+	require.NoError(t, os.WriteFile(getItems, []byte("default: [{commands: [GetItem]}]"), 0o600))
+	invalid := filepath.Join(dir, "invalid.yaml")
+	require.NoError(t, os.WriteFile(invalid, []byte("default: [{commands: [Truncate]}]"), 0o600))
+	addItem := &models.Command{Type: models.CommandType_AddItem, ItemID: 1}
+
+	e, err := NewEngine("", 0)
+	require.NoError(t, err)
+	assert.NoError(t, e.Authorize("", addItem), "all commands are allowed without the file")
+
+	require.NoError(t, e.SetFile(getItems, time.Minute))
+	assert.ErrorIs(t, e.Authorize("", addItem), ErrDenied)
+
+	assert.ErrorIs(t, e.SetFile(invalid, time.Minute), ErrInvalidPolicy)
+	assert.ErrorIs(t, e.Authorize("", addItem), ErrDenied, "the current policy is kept")
+
+	require.NoError(t, e.SetFile("", 0))
+	assert.NoError(t, e.Authorize("", addItem))
+	reloaded, err := e.reload()
+	assert.NoError(t, err)
+	assert.False(t, reloaded)
+}
@@ -0,0 +1,290 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/consensus"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/policy"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/repository"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// QueryServer serves models.Query gRPC service. Get and List are processed by the item service like GetItem
+// and GetAllItems commands of the queue, Count and Watch read the registry without touching items.
+// Requests require "authorization: Bearer <token>" metadata with the token of a client and are authorized by
+// the policy of the client: Get as GetItem, List, Count and Watch as GetAllItems of the collection.
+type QueryServer struct {
+	models.UnimplementedQueryServer
+
+	addr        string
+	grpcServer  *grpc.Server
+	itemService service.ItemService
+	registry    repository.Registry
+	clients     []QueryClient
+	// policy authorizes requests of clients, all requests are allowed when it is not set
+	policy *policy.Engine
+}
+
+type QueryOption func(s *QueryServer)
+
+// QueryPolicy makes the server reject requests which are not allowed by the policy.
+func QueryPolicy(e *policy.Engine) QueryOption {
+	return func(s *QueryServer) {
+		s.policy = e
+	}
+}
+
+func NewQueryServer(config QueryConfig, itemService service.ItemService, registry repository.Registry,
+	opts ...QueryOption) (*QueryServer, error) {
+	s := &QueryServer{
+		addr:        config.ListenAddr,
+		itemService: itemService,
+		registry:    registry,
+		clients:     config.Clients,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	serverOpts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(s.authenticateUnary),
+		grpc.StreamInterceptor(s.authenticateStream),
+	}
+	if config.CertFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		serverOpts = append(serverOpts, grpc.Creds(creds))
+	}
+	s.grpcServer = grpc.NewServer(serverOpts...)
+	models.RegisterQueryServer(s.grpcServer, s)
+	reflection.Register(s.grpcServer)
+	return s, nil
+}
+
+func (s *QueryServer) Start() error {
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		log.Infof("Query API is served on %s", s.addr)
+		if err := s.grpcServer.Serve(listener); err != nil {
+			log.Errorf("Query server stopped: %v", err)
+		}
+	}()
+	return nil
+}
+
+// Quit stops the server, open Watch streams are cancelled.
+func (s *QueryServer) Quit() {
+	s.grpcServer.Stop()
+}
+
+func (s *QueryServer) Get(ctx context.Context, command *models.Command) (*models.ItemRecord, error) {
+	query := &models.Command{
+		Type:       models.CommandType_GetItem,
+		ItemID:     command.ItemID,
+		Collection: command.Collection,
+	}
+	if err := s.authorize(ctx, query); err != nil {
+		return nil, err
+	}
+
+	items, err := s.itemService.QueryItems(tracedContext(ctx), query)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	if len(items) == 0 {
+		return nil, status.Errorf(codes.NotFound, "item %d not found", command.ItemID)
+	}
+	return models.NewItemRecord(items[0]), nil
+}
+
+func (s *QueryServer) List(command *models.Command, stream models.Query_ListServer) error {
+	query := &models.Command{
+		Type:       models.CommandType_GetAllItems,
+		Collection: command.Collection,
+	}
+	if err := s.authorize(stream.Context(), query); err != nil {
+		return err
+	}
+
+	items, err := s.itemService.QueryItems(tracedContext(stream.Context()), query)
+	if err != nil {
+		return toStatus(err)
+	}
+
+	for _, item := range items {
+		if err := stream.Send(models.NewItemRecord(item)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *QueryServer) Count(ctx context.Context, command *models.Command) (*models.CountReply, error) {
+	err := s.authorize(ctx, &models.Command{Type: models.CommandType_GetAllItems, Collection: command.Collection})
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := s.registry.GetCollection(command.Collection)
+	if errors.Is(err, repository.ErrCollectionNotFound) {
+		return &models.CountReply{}, nil
+	}
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	count, err := repo.Count(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &models.CountReply{Count: int64(count)}, nil
+}
+
+// Watch streams events of the requested collections, all of them must be allowed for the client. Without collections
+// events of all collections allowed for the client are streamed.
+func (s *QueryServer) Watch(request *models.WatchRequest, stream models.Query_WatchServer) error {
+	collections := make(map[string]bool, len(request.Collections))
+	for _, collection := range request.Collections {
+		if collection == "" {
+			collection = repository.DefaultCollection
+		}
+		err := s.authorize(stream.Context(), &models.Command{Type: models.CommandType_GetAllItems, Collection: collection})
+		if err != nil {
+			return err
+		}
+		collections[collection] = true
+	}
+
+	events, err := s.registry.Watch(stream.Context(), request.FromSequence)
+	if err != nil {
+		return toStatus(err)
+	}
+
+	// allowed caches decisions of the policy for collections of events, the policy is not reloaded during the stream
+	allowed := make(map[string]bool)
+	lastSeq := request.FromSequence
+	for event := range events {
+		lastSeq = event.Sequence
+		if len(collections) > 0 && !collections[event.Collection] {
+			continue
+		}
+		if len(collections) == 0 {
+			ok, seen := allowed[event.Collection]
+			if !seen {
+				query := &models.Command{Type: models.CommandType_GetAllItems, Collection: event.Collection}
+				ok = s.authorize(stream.Context(), query) == nil
+				allowed[event.Collection] = ok
+			}
+			if !ok {
+				continue
+			}
+		}
+		if err := stream.Send(event); err != nil {
+			return err
+		}
+	}
+
+	if err := stream.Context().Err(); err != nil {
+		return status.FromContextError(err).Err()
+	}
+	return status.Errorf(codes.Aborted, "watcher fell behind the change log, resume from sequence %d", lastSeq+1)
+}
+
+// authenticateUnary passes the client id of the request token to the handler.
+func (s *QueryServer) authenticateUnary(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	clientID, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(context.WithValue(ctx, clientIDKey, clientID), req)
+}
+
+// authenticateStream passes the client id of the request token to the handler in the context of the stream.
+func (s *QueryServer) authenticateStream(srv interface{}, stream grpc.ServerStream, _ *grpc.StreamServerInfo,
+	handler grpc.StreamHandler) error {
+	clientID, err := s.authenticate(stream.Context())
+	if err != nil {
+		return err
+	}
+	ctx := context.WithValue(stream.Context(), clientIDKey, clientID)
+	return handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+}
+
+// authenticate returns the id of the client which token is in the metadata of the request.
+func (s *QueryServer) authenticate(ctx context.Context) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return "", status.Error(codes.Unauthenticated, "bearer token is required")
+	}
+	token := strings.TrimPrefix(values[0], "Bearer ")
+	if token != values[0] {
+		for _, client := range s.clients {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(client.Token)) == 1 {
+				return client.ID, nil
+			}
+		}
+	}
+	return "", status.Error(codes.Unauthenticated, "invalid bearer token")
+}
+
+// authorize checks the query of the client by the policy.
+func (s *QueryServer) authorize(ctx context.Context, query *models.Command) error {
+	if s.policy == nil {
+		return nil
+	}
+	clientID, _ := ctx.Value(clientIDKey).(string)
+	if err := s.policy.Authorize(clientID, query); err != nil {
+		log.WithField(clientIDKey, clientID).Warningf("Query is denied: %v", err)
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	return nil
+}
+
+// authenticatedStream is the stream with the client id in its context.
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// tracedContext passes trace id of the request metadata to the item service.
+func tracedContext(ctx context.Context) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(traceIDKey); len(values) > 0 {
+		return context.WithValue(ctx, traceIDKey, values[0])
+	}
+	return ctx
+}
+
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrSequenceNotAvailable):
+		return status.Error(codes.OutOfRange, err.Error())
+	case errors.Is(err, consensus.ErrNotLeader):
+		return status.Error(codes.Unavailable, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
@@ -0,0 +1,253 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/policy"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/repository"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+var testQueryConfig = QueryConfig{Clients: []QueryClient{{ID: "client-1", Token: "secret-1"}, {ID: "client-2", Token: "secret-2"}}}
+
+func newQueryClient(t *testing.T, registry repository.Registry) models.QueryClient {
+	return newQueryClients(t, registry)["secret-1"]
+}
+
+// newQueryClients returns clients of the server by their tokens, the client without token is returned by empty one.
+func newQueryClients(t *testing.T, registry repository.Registry, opts ...QueryOption) map[string]models.QueryClient {
+	listener := bufconn.Listen(1 << 20)
+	s, err := NewQueryServer(testQueryConfig, service.New(registry), registry, opts...)
+	require.NoError(t, err)
+	go s.grpcServer.Serve(listener)
+	t.Cleanup(s.Quit)
+
+	clients := make(map[string]models.QueryClient)
+	for _, token := range []string{"", "secret-1", "secret-2", "wrong"} {
+		dialOpts := []grpc.DialOption{
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+				return listener.DialContext(ctx)
+			}),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+		}
+		if token != "" {
+			dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(bearerToken(token)))
+		}
+		conn, err := grpc.Dial("bufnet", dialOpts...)
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		clients[token] = models.NewQueryClient(conn)
+	}
+	return clients
+}
+
+// bearerToken sends the token in the metadata of requests over the insecure test connection.
+type bearerToken string
+
+func (b bearerToken) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(b)}, nil
+}
+
+func (b bearerToken) RequireTransportSecurity() bool {
+	return false
+}
+
+func TestQueryServer(t *testing.T) {
+	ctx := context.Background()
+	registry, err := repository.NewRegistry(repository.RegistryConfig{})
+	require.NoError(t, err)
+	repo, err := registry.Collection(ctx, "sessions")
+	require.NoError(t, err)
+	require.NoError(t, repo.AddItem(ctx, models.Item{ID: 1, Payload: "A"}))
+	require.NoError(t, repo.AddItem(ctx, models.Item{ID: 2, Payload: "B"}))
+
+	client := newQueryClient(t, registry)
+
+	t.Run("should get item", func(t *testing.T) {
+		item, err := client.Get(ctx, &models.Command{ItemID: 2, Collection: "sessions"})
+		require.NoError(t, err)
+		assert.Equal(t, "B", item.Payload)
+		assert.Equal(t, uint64(1), item.Version)
+	})
+
+	t.Run("should return not found for unknown item", func(t *testing.T) {
+		_, err := client.Get(ctx, &models.Command{ItemID: 3, Collection: "sessions"})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+
+		_, err = client.Get(ctx, &models.Command{ItemID: 1, Collection: "unknown"})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("should list items in insertion order", func(t *testing.T) {
+		stream, err := client.List(ctx, &models.Command{Collection: "sessions"})
+		require.NoError(t, err)
+
+		var payloads []string
+		for {
+			item, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			payloads = append(payloads, item.Payload)
+		}
+		assert.Equal(t, []string{"A", "B"}, payloads)
+	})
+
+	t.Run("should count items", func(t *testing.T) {
+		reply, err := client.Count(ctx, &models.Command{Collection: "sessions"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), reply.Count)
+
+		reply, err = client.Count(ctx, &models.Command{Collection: "unknown"})
+		require.NoError(t, err)
+		assert.Zero(t, reply.Count)
+	})
+}
+
+func TestQueryServer_Watch(t *testing.T) {
+	ctx := context.Background()
+	registry, err := repository.NewRegistry(repository.RegistryConfig{})
+	require.NoError(t, err)
+	repo, err := registry.Collection(ctx, "sessions")
+	require.NoError(t, err)
+	require.NoError(t, repo.AddItem(ctx, models.Item{ID: 1, Payload: "A"}))
+
+	client := newQueryClient(t, registry)
+	watchCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	// events of the default collection are filtered out
+	stream, err := client.Watch(watchCtx, &models.WatchRequest{FromSequence: 1, Collections: []string{"sessions"}})
+	require.NoError(t, err)
+	defaultRepo, err := registry.Collection(ctx, "")
+	require.NoError(t, err)
+	require.NoError(t, defaultRepo.AddItem(ctx, models.Item{ID: 1, Payload: "X"}))
+	require.NoError(t, repo.RemoveItem(ctx, 1))
+
+	var ops []models.ChangeOp
+	for len(ops) < 3 {
+		event, err := stream.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, "sessions", event.Collection)
+		ops = append(ops, event.Op)
+	}
+	assert.Equal(t, []models.ChangeOp{models.ChangeOp_CollectionCreated, models.ChangeOp_ItemAdded, models.ChangeOp_ItemRemoved}, ops)
+}
+
+func TestQueryServer_WatchNotRetained(t *testing.T) {
+	registry, err := repository.NewRegistry(repository.RegistryConfig{ChangeLogRetention: 1})
+	require.NoError(t, err)
+	require.NoError(t, registry.CreateCollection(context.Background(), "sessions"))
+	require.NoError(t, registry.CreateCollection(context.Background(), "users"))
+
+	client := newQueryClient(t, registry)
+	stream, err := client.Watch(context.Background(), &models.WatchRequest{FromSequence: 1})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.OutOfRange, status.Code(err))
+}
+
+func TestQueryServer_Authentication(t *testing.T) {
+	ctx := context.Background()
+	registry, err := repository.NewRegistry(repository.RegistryConfig{})
+	require.NoError(t, err)
+	sessions, err := registry.Collection(ctx, "sessions")
+	require.NoError(t, err)
+	require.NoError(t, sessions.AddItem(ctx, models.Item{ID: 1, Payload: "A"}))
+	clients := newQueryClients(t, registry)
+
+	t.Run("should reject requests without valid token", func(t *testing.T) {
+		_, err := clients[""].Count(ctx, &models.Command{Collection: "sessions"})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+		_, err = clients["wrong"].Count(ctx, &models.Command{Collection: "sessions"})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+		stream, err := clients["wrong"].List(ctx, &models.Command{Collection: "sessions"})
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("should accept requests of every client", func(t *testing.T) {
+		for _, token := range []string{"secret-1", "secret-2"} {
+			count, err := clients[token].Count(ctx, &models.Command{Collection: "sessions"})
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), count.Count)
+		}
+	})
+}
+
+func TestQueryServer_Authorization(t *testing.T) {
+	ctx := context.Background()
+	registry, err := repository.NewRegistry(repository.RegistryConfig{})
+	require.NoError(t, err)
+	sessions, err := registry.Collection(ctx, "sessions")
+	require.NoError(t, err)
+	require.NoError(t, sessions.AddItem(ctx, models.Item{ID: 1, Payload: "A"}))
+	users, err := registry.Collection(ctx, "users")
+	require.NoError(t, err)
+	require.NoError(t, users.AddItem(ctx, models.Item{ID: 1, Payload: "B"}))
+
+	file := filepath.Join(t.TempDir(), "policy.yaml")
+	policyYAML := "clients: {client-1: [{commands: [GetItem, GetAllItems], collections: [sessions]}]}\ndefault: []"
+	require.NoError(t, os.WriteFile(file, []byte(policyYAML), 0o600))
+	engine, err := policy.NewEngine(file, 0)
+	require.NoError(t, err)
+	clients := newQueryClients(t, registry, QueryPolicy(engine))
+
+	t.Run("should authorize requests by policy of the client", func(t *testing.T) {
+		count, err := clients["secret-1"].Count(ctx, &models.Command{Collection: "sessions"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), count.Count)
+
+		_, err = clients["secret-1"].Count(ctx, &models.Command{Collection: "users"})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+		_, err = clients["secret-2"].Get(ctx, &models.Command{ItemID: 1, Collection: "sessions"})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+		stream, err := clients["secret-1"].List(ctx, &models.Command{Collection: "users"})
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	t.Run("should watch only allowed collections", func(t *testing.T) {
+		watchCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		denied, err := clients["secret-1"].Watch(watchCtx, &models.WatchRequest{FromSequence: 1, Collections: []string{"users"}})
+		require.NoError(t, err)
+		_, err = denied.Recv()
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+		stream, err := clients["secret-1"].Watch(watchCtx, &models.WatchRequest{FromSequence: 1})
+		require.NoError(t, err)
+		require.NoError(t, sessions.RemoveItem(ctx, 1))
+
+		var ops []models.ChangeOp
+		for len(ops) < 3 {
+			event, err := stream.Recv()
+			require.NoError(t, err)
+			assert.Equal(t, "sessions", event.Collection)
+			ops = append(ops, event.Op)
+		}
+		assert.Equal(t, []models.ChangeOp{models.ChangeOp_CollectionCreated, models.ChangeOp_ItemAdded, models.ChangeOp_ItemRemoved}, ops)
+	})
+}
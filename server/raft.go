@@ -0,0 +1,15 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/consensus"
+)
+
+// NewRaftServer returns the server of the Raft cluster status and membership changes, see consensus.Server.
+// Requests require "Authorization: Bearer <token>" header with the Raft token.
+func NewRaftServer(config RaftConfig, node *consensus.Node) *consensus.Server {
+	return consensus.NewServer(config.ListenAddr, node, func(next http.Handler) http.Handler {
+		return bearerAuth(config.Token, next)
+	})
+}
@@ -0,0 +1,291 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/metrics"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/repository"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	RoleStandalone = "standalone"
+	RolePrimary    = "primary"
+	RoleReplica    = "replica"
+
+	snapshotPath          = "/replication/snapshot"
+	replicaRetryInterval  = 5 * time.Second
+	snapshotClientTimeout = time.Minute
+)
+
+var errReplicaOutOfSync = errors.New("replica is out of sync with the primary")
+
+// SnapshotServer serves consistent snapshot of the registry in JSON format on /replication/snapshot,
+// replicas start from it and apply the following changes from the change feed. Requests require
+// "Authorization: Bearer <token>" header with the replication token.
+type SnapshotServer struct {
+	httpServer *http.Server
+}
+
+func NewSnapshotServer(config ReplicationConfig, registry repository.Registry) *SnapshotServer {
+	mux := http.NewServeMux()
+	mux.Handle(snapshotPath, bearerAuth(config.Token, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(registry.Snapshot()); err != nil {
+			log.Errorf("Cannot write snapshot: %v", err)
+		}
+	})))
+
+	return &SnapshotServer{
+		httpServer: &http.Server{
+			Addr:              config.ListenAddr,
+			Handler:           mux,
+			ReadHeaderTimeout: 5 * time.Second,
+		},
+	}
+}
+
+func (s *SnapshotServer) Start() {
+	go func() {
+		log.Infof("Snapshots are served on %s%s", s.httpServer.Addr, snapshotPath)
+		if err := s.httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("Snapshot server stopped: %v", err)
+		}
+	}()
+}
+
+func (s *SnapshotServer) Quit() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return s.httpServer.Shutdown(ctx)
+}
+
+// bearerAuth rejects requests without "Authorization: Bearer <token>" header, all requests are rejected when
+// the token is empty.
+func bearerAuth(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		requestToken := strings.TrimPrefix(header, "Bearer ")
+		if requestToken == header || token == "" || subtle.ConstantTimeCompare([]byte(requestToken), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Replica keeps the registry in sync with the primary. It subscribes to the change feed of the primary,
+// restores the registry from the primary snapshot and applies all changes made after the snapshot in order.
+// Replica starts over from a new snapshot when it misses changes or the primary is restarted.
+type Replica struct {
+	config     Configurations
+	registry   repository.Registry
+	httpClient *http.Client
+	now        func() time.Time
+	cancel     context.CancelFunc
+	done       chan struct{}
+}
+
+func NewReplica(config Configurations, registry repository.Registry) *Replica {
+	return &Replica{
+		config:     config,
+		registry:   registry,
+		httpClient: &http.Client{Timeout: snapshotClientTimeout},
+		now:        time.Now,
+		done:       make(chan struct{}),
+	}
+}
+
+func (r *Replica) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+
+	go func() {
+		defer close(r.done)
+
+		for {
+			err := r.sync(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			if errors.Is(err, errReplicaOutOfSync) {
+				log.Warningf("Replica is restored again: %v", err)
+				continue
+			}
+			log.Errorf("Replication is interrupted: %v", err)
+
+			select {
+			case <-time.After(replicaRetryInterval):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (r *Replica) Quit() {
+	if r.cancel == nil {
+		return
+	}
+	r.cancel()
+	<-r.done
+}
+
+// sync restores the registry from the snapshot and applies changes until ctx is done or an error occurs.
+func (r *Replica) sync(ctx context.Context) error {
+	conn, err := r.config.RabbitMQConfig.Dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	exchange := r.config.ChangeFeedConfig.Exchange
+	err = ch.ExchangeDeclare(
+		exchange,
+		amqp.ExchangeFanout,
+		true,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		return err
+	}
+
+	// every replica has its own queue which exists while the replica is connected
+	queue, err := ch.QueueDeclare(
+		"",
+		false,
+		true,
+		true,
+		false,
+		nil,
+	)
+	if err != nil {
+		return err
+	}
+	if err := ch.QueueBind(queue.Name, "", exchange, false, nil); err != nil {
+		return err
+	}
+
+	msgs, err := ch.Consume(
+		queue.Name,
+		"",
+		true,
+		true,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		return err
+	}
+
+	// changes are queued since the queue is bound, so the ones made after the snapshot are not lost
+	snapshot, err := r.fetchSnapshot(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.registry.Restore(snapshot); err != nil {
+		return err
+	}
+	log.Infof("Replica is restored from the snapshot at sequence %d", snapshot.Sequence)
+
+	lastSeq := snapshot.Sequence
+	metrics.ReplicatedSequence.Set(int64(lastSeq))
+	for {
+		select {
+		case d, ok := <-msgs:
+			if !ok {
+				return errors.New("change feed is closed")
+			}
+
+			lastSeq, err = r.apply(ctx, d, snapshot.ChangeLogID, lastSeq)
+			if err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (r *Replica) fetchSnapshot(ctx context.Context) (*repository.ReplicaSnapshot, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.config.ReplicationConfig.PrimaryURL+snapshotPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+r.config.ReplicationConfig.Token)
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cannot get snapshot of the primary: %s", resp.Status)
+	}
+
+	snapshot := new(repository.ReplicaSnapshot)
+	if err := json.NewDecoder(resp.Body).Decode(snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// apply applies the change event if it follows lastSeq and returns sequence of the last applied event.
+func (r *Replica) apply(ctx context.Context, d amqp.Delivery, changeLogID string, lastSeq uint64) (uint64, error) {
+	if d.Type != models.MessageTypeChangeEvent {
+		return lastSeq, fmt.Errorf("unknown message type: %s", d.Type)
+	}
+
+	event := new(models.ChangeEvent)
+	if err := proto.Unmarshal(d.Body, event); err != nil {
+		return lastSeq, err
+	}
+
+	if id, _ := d.Headers[changeLogIDKey].(string); id != changeLogID {
+		return lastSeq, fmt.Errorf("%w: change log of the primary is changed", errReplicaOutOfSync)
+	}
+
+	switch {
+	case event.Sequence <= lastSeq:
+		// the change is already in the snapshot
+		return lastSeq, nil
+	case event.Sequence > lastSeq+1:
+		return lastSeq, fmt.Errorf("%w: changes from %d to %d are missed", errReplicaOutOfSync, lastSeq+1, event.Sequence-1)
+	}
+
+	ctx = context.WithValue(ctx, traceIDKey, event.TraceID)
+	if err := r.registry.Replicate(ctx, event); err != nil {
+		return lastSeq, err
+	}
+
+	metrics.ReplicatedSequence.Set(int64(event.Sequence))
+	metrics.ReplicationLag.Set(r.now().Sub(time.Unix(0, event.Timestamp)).Seconds())
+	return event.Sequence, nil
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/repository"
+	"github.com/golang/mock/gomock"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestReplica_apply(t *testing.T) {
+	delivery := func(changeLogID string, event *models.ChangeEvent) amqp.Delivery {
+		body, err := proto.Marshal(event)
+		require.NoError(t, err)
+		return amqp.Delivery{
+			Type:    models.MessageTypeChangeEvent,
+			Headers: amqp.Table{changeLogIDKey: changeLogID},
+			Body:    body,
+		}
+	}
+
+	tests := []struct {
+		name        string
+		registry    func(ctrl *gomock.Controller) repository.Registry
+		delivery    amqp.Delivery
+		wantSeq     uint64
+		wantErr     assert.ErrorAssertionFunc
+		wantOutSync bool
+	}{
+		{
+			name: "should apply next change",
+			registry: func(ctrl *gomock.Controller) repository.Registry {
+				registry := repository.NewMockRegistry(ctrl)
+				registry.EXPECT().Replicate(gomock.Any(), protoEq{&models.ChangeEvent{Sequence: 6, ItemID: 1}}).Return(nil)
+				return registry
+			},
+			delivery: delivery("log", &models.ChangeEvent{Sequence: 6, ItemID: 1}),
+			wantSeq:  6,
+			wantErr:  assert.NoError,
+		},
+		{
+			name: "should skip change which is in the snapshot",
+			registry: func(ctrl *gomock.Controller) repository.Registry {
+				return repository.NewMockRegistry(ctrl)
+			},
+			delivery: delivery("log", &models.ChangeEvent{Sequence: 5}),
+			wantSeq:  5,
+			wantErr:  assert.NoError,
+		},
+		{
+			name: "should return error when changes are missed",
+			registry: func(ctrl *gomock.Controller) repository.Registry {
+				return repository.NewMockRegistry(ctrl)
+			},
+			delivery:    delivery("log", &models.ChangeEvent{Sequence: 7}),
+			wantSeq:     5,
+			wantErr:     assert.Error,
+			wantOutSync: true,
+		},
+		{
+			name: "should return error when primary is restarted",
+			registry: func(ctrl *gomock.Controller) repository.Registry {
+				return repository.NewMockRegistry(ctrl)
+			},
+			delivery:    delivery("other", &models.ChangeEvent{Sequence: 1}),
+			wantSeq:     5,
+			wantErr:     assert.Error,
+			wantOutSync: true,
+		},
+		{
+			name: "should return error when change is not applied",
+			registry: func(ctrl *gomock.Controller) repository.Registry {
+				registry := repository.NewMockRegistry(ctrl)
+				registry.EXPECT().Replicate(gomock.Any(), gomock.Any()).Return(repository.ErrInvalidCollectionName)
+				return registry
+			},
+			delivery: delivery("log", &models.ChangeEvent{Sequence: 6}),
+			wantSeq:  5,
+			wantErr:  assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			r := NewReplica(Configurations{}, tt.registry(ctrl))
+
+			gotSeq, err := r.apply(context.Background(), tt.delivery, "log", 5)
+			tt.wantErr(t, err)
+			assert.Equal(t, tt.wantOutSync, errors.Is(err, errReplicaOutOfSync))
+			assert.Equal(t, tt.wantSeq, gotSeq)
+		})
+	}
+}
+
+func TestReplica_fetchSnapshot(t *testing.T) {
+	ctx := context.Background()
+	primary, err := repository.NewRegistry(repository.RegistryConfig{})
+	require.NoError(t, err)
+	repo, err := primary.Collection(ctx, "sessions")
+	require.NoError(t, err)
+	require.NoError(t, repo.AddItem(ctx, models.Item{ID: 1, Payload: "A", ExpiresAt: time.Now().Add(time.Hour)}))
+
+	httpServer := httptest.NewServer(NewSnapshotServer(ReplicationConfig{Token: "secret"}, primary).httpServer.Handler)
+	defer httpServer.Close()
+
+	r := NewReplica(Configurations{ReplicationConfig: ReplicationConfig{PrimaryURL: httpServer.URL, Token: "secret"}}, nil)
+	snapshot, err := r.fetchSnapshot(ctx)
+	require.NoError(t, err)
+
+	want := primary.Snapshot()
+	assert.Equal(t, want.ChangeLogID, snapshot.ChangeLogID)
+	assert.Equal(t, want.Sequence, snapshot.Sequence)
+	require.Len(t, snapshot.Collections, 1)
+	assert.Equal(t, "sessions", snapshot.Collections[0].Name)
+	require.Len(t, snapshot.Collections[0].Items, 1)
+	assert.Equal(t, "A", snapshot.Collections[0].Items[0].Payload)
+	assert.True(t, want.Collections[0].Items[0].ExpiresAt.Equal(*snapshot.Collections[0].Items[0].ExpiresAt))
+}
+
+func TestSnapshotServer_Unauthorized(t *testing.T) {
+	primary, err := repository.NewRegistry(repository.RegistryConfig{})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		serverToken  string
+		replicaToken string
+	}{
+		{name: "should reject request without token", serverToken: "secret", replicaToken: ""},
+		{name: "should reject request with wrong token", serverToken: "secret", replicaToken: "guess"},
+		{name: "should reject all requests when token is not set", serverToken: "", replicaToken: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpServer := httptest.NewServer(NewSnapshotServer(ReplicationConfig{Token: tt.serverToken}, primary).httpServer.Handler)
+			defer httpServer.Close()
+
+			r := NewReplica(Configurations{ReplicationConfig: ReplicationConfig{PrimaryURL: httpServer.URL, Token: tt.replicaToken}}, nil)
+			_, err := r.fetchSnapshot(context.Background())
+			assert.ErrorContains(t, err, "401 Unauthorized")
+		})
+	}
+}
@@ -0,0 +1,276 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/metrics"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Operation is a single step of the batch. Item has only ID for all types except AddItem and UpdateItem.
+// ExpectedVersion makes UpdateItem and RemoveItem conditional like in Repo.UpdateItem and Repo.RemoveItemIfVersion.
+type Operation struct {
+	Type            models.CommandType
+	Item            models.Item
+	ExpectedVersion uint64
+}
+
+// OperationResult keeps items which were read by GetItem and GetAllItems operations.
+type OperationResult struct {
+	Items []models.Item
+}
+
+func (r *repoImpl) ApplyBatch(ctx context.Context, ops []Operation) (results []OperationResult, err error) {
+	ctx, span := r.startSpan(ctx, "ApplyBatch", attribute.Int("batch.operations", len(ops)))
+	defer func() { tracing.End(span, err) }()
+
+	results, evicted, err := r.applyBatch(ctx, ops)
+	if errors.Is(err, ErrCapacityExceeded) {
+		metrics.RejectedItems.Add(r.name, 1)
+	}
+	r.reportEvicted(evicted)
+	return results, err
+}
+
+func (r *repoImpl) applyBatch(ctx context.Context, ops []Operation) ([]OperationResult, []models.Item, error) {
+	r.rwMx.Lock()
+	defer r.rwMx.Unlock()
+
+	// all checks are done before the first change, so applying the batch cannot fail in the middle
+	if err := r.validateBatch(ctx, ops); err != nil {
+		return nil, nil, err
+	}
+
+	var evicted []models.Item
+	results := make([]OperationResult, len(ops))
+	for i, op := range ops {
+		switch op.Type {
+		case models.CommandType_AddItem:
+			evictedItems, err := r.addLocked(ctx, op.Item)
+			if err != nil {
+				// should not happen after validation
+				return nil, evicted, fmt.Errorf("batch is applied partially: %w", err)
+			}
+			evicted = append(evicted, evictedItems...)
+		case models.CommandType_UpdateItem:
+			_, evictedItems, err := r.updateLocked(ctx, op.Item, op.ExpectedVersion)
+			if err != nil {
+				// should not happen after validation
+				return nil, evicted, fmt.Errorf("batch is applied partially: %w", err)
+			}
+			evicted = append(evicted, evictedItems...)
+		case models.CommandType_RemoveItem:
+			r.deleteLocked(ctx, op.Item.ID, models.ChangeOp_ItemRemoved)
+		case models.CommandType_GetItem:
+			item, err := r.getLocked(op.Item.ID)
+			if err == nil {
+				results[i].Items = []models.Item{item}
+			}
+		case models.CommandType_GetAllItems:
+			results[i].Items = r.allLocked()
+		}
+	}
+
+	r.updateMetrics()
+	return results, evicted, nil
+}
+
+// simulatedItem is the state of the item after some operations of the batch.
+type simulatedItem struct {
+	exists  bool
+	size    int64
+	version uint64
+}
+
+// validateBatch checks that every operation of the batch can be applied: types are supported, items exist for
+// UpdateItem, versions are correct for conditional operations and the collection does not exceed the limits at any
+// step. Evictions are simulated, so items evicted by earlier operations are missing for later ones. It should be called
+// under the write lock.
+func (r *repoImpl) validateBatch(ctx context.Context, ops []Operation) error {
+	for _, op := range ops {
+		switch op.Type {
+		case models.CommandType_AddItem, models.CommandType_UpdateItem:
+			if r.limits.MaxBytes > 0 && itemSize(op.Item.Payload) > r.limits.MaxBytes {
+				return ErrCapacityExceeded
+			}
+		case models.CommandType_RemoveItem, models.CommandType_GetItem, models.CommandType_GetAllItems:
+		default:
+			return fmt.Errorf("command type %s is not allowed in batch", op.Type)
+		}
+	}
+
+	checkLimits := r.limits.exceeded(r.storage.Size()+len(ops), r.bytes+batchBytes(ops))
+	var queue *evictionQueue
+	if checkLimits {
+		// adding items removes expired items too, do it in advance to have the same state as during applying
+		r.removeExpiredLocked(ctx)
+		if r.limits.Policy == EvictionPolicyFIFO || r.limits.Policy == EvictionPolicyLRU {
+			queue = r.newEvictionQueue()
+		}
+	}
+
+	simulated := make(map[int64]simulatedItem)
+	removedVersion := r.removedVersion
+	get := func(itemID int64) simulatedItem {
+		if item, ok := simulated[itemID]; ok {
+			return item
+		}
+		item, err := r.peekLocked(itemID)
+		if err != nil {
+			return simulatedItem{}
+		}
+		return simulatedItem{exists: true, size: itemSize(item.Payload), version: item.Version}
+	}
+	remove := func(itemID int64, current simulatedItem) {
+		simulated[itemID] = simulatedItem{}
+		if current.version > removedVersion {
+			removedVersion = current.version
+		}
+		if queue != nil {
+			queue.remove(itemID)
+		}
+	}
+
+	items, bytes := r.storage.Size(), r.bytes
+	for i, op := range ops {
+		current := get(op.Item.ID)
+		switch op.Type {
+		case models.CommandType_AddItem, models.CommandType_UpdateItem:
+			if op.Type == models.CommandType_UpdateItem {
+				if !current.exists {
+					return fmt.Errorf("command %d: %w", i, ErrItemNotFound)
+				}
+				if op.ExpectedVersion != 0 && op.ExpectedVersion != current.version {
+					return fmt.Errorf("command %d: %w: expected version %d, current version %d",
+						i, ErrVersionConflict, op.ExpectedVersion, current.version)
+				}
+			}
+
+			version := current.version + 1
+			if current.exists {
+				bytes -= current.size
+			} else {
+				items++
+				version = removedVersion + 1
+			}
+			bytes += itemSize(op.Item.Payload)
+
+			for checkLimits && r.limits.exceeded(items, bytes) {
+				if queue == nil {
+					return ErrCapacityExceeded
+				}
+				victimID, ok := queue.victim(op.Item.ID)
+				if !ok {
+					return ErrCapacityExceeded
+				}
+				victim := get(victimID)
+				items--
+				bytes -= victim.size
+				remove(victimID, victim)
+			}
+
+			simulated[op.Item.ID] = simulatedItem{exists: true, size: itemSize(op.Item.Payload), version: version}
+			if queue != nil {
+				queue.put(op.Item.ID, current.exists)
+			}
+		case models.CommandType_RemoveItem:
+			if op.ExpectedVersion != 0 && !current.exists {
+				return fmt.Errorf("command %d: %w", i, ErrItemNotFound)
+			}
+			if op.ExpectedVersion != 0 && op.ExpectedVersion != current.version {
+				return fmt.Errorf("command %d: %w: expected version %d, current version %d",
+					i, ErrVersionConflict, op.ExpectedVersion, current.version)
+			}
+			if current.exists {
+				items--
+				bytes -= current.size
+				remove(op.Item.ID, current)
+			}
+		case models.CommandType_GetItem:
+			if current.exists && queue != nil {
+				queue.access(op.Item.ID)
+			}
+		}
+	}
+	return nil
+}
+
+// evictionQueue simulates the order in which items are evicted while the batch is applied.
+type evictionQueue struct {
+	lru bool
+	// ids are in the order of eviction, items which are moved to the back are appended again
+	ids []int64
+	// positions are indexes of stored items in ids
+	positions map[int64]int
+	// next is the index of the first id which can be evicted
+	next int
+}
+
+// newEvictionQueue should be called under the write lock.
+func (r *repoImpl) newEvictionQueue() *evictionQueue {
+	q := &evictionQueue{lru: r.lru != nil}
+	if q.lru {
+		q.ids = r.lru.ids()
+	} else {
+		for _, key := range r.storage.Keys() {
+			q.ids = append(q.ids, key.(int64))
+		}
+	}
+	q.positions = make(map[int64]int, len(q.ids))
+	for i, itemID := range q.ids {
+		q.positions[itemID] = i
+	}
+	return q
+}
+
+// victim returns the item which is evicted next except the given one.
+func (q *evictionQueue) victim(except int64) (int64, bool) {
+	for i := q.next; i < len(q.ids); i++ {
+		itemID := q.ids[i]
+		if position, ok := q.positions[itemID]; !ok || position != i {
+			if i == q.next {
+				q.next++
+			}
+			continue
+		}
+		if itemID != except {
+			return itemID, true
+		}
+	}
+	return 0, false
+}
+
+// put moves the added or updated item to the back, existing items keep their position by FIFO policy.
+func (q *evictionQueue) put(itemID int64, exists bool) {
+	if q.lru || !exists {
+		q.moveToBack(itemID)
+	}
+}
+
+// access moves the read item to the back by LRU policy.
+func (q *evictionQueue) access(itemID int64) {
+	if q.lru {
+		q.moveToBack(itemID)
+	}
+}
+
+func (q *evictionQueue) moveToBack(itemID int64) {
+	q.positions[itemID] = len(q.ids)
+	q.ids = append(q.ids, itemID)
+}
+
+func (q *evictionQueue) remove(itemID int64) {
+	delete(q.positions, itemID)
+}
+
+func batchBytes(ops []Operation) int64 {
+	var bytes int64
+	for _, op := range ops {
+		bytes += itemSize(op.Item.Payload)
+	}
+	return bytes
+}
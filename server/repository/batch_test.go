@@ -0,0 +1,216 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_repoImpl_ApplyBatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		limits      Limits
+		fillRepo    func(r Repo)
+		ops         []Operation
+		wantErr     assert.ErrorAssertionFunc
+		wantResults []OperationResult
+		wantItems   []models.Item
+	}{
+		{
+			name: "should apply all operations in order",
+			fillRepo: func(r Repo) {
+				r.AddItem(context.Background(), models.Item{ID: 1, Payload: "A"})
+			},
+			ops: []Operation{
+				{Type: models.CommandType_AddItem, Item: models.Item{ID: 2, Payload: "B"}},
+				{Type: models.CommandType_RemoveItem, Item: models.Item{ID: 1}},
+				{Type: models.CommandType_GetItem, Item: models.Item{ID: 1}},
+				{Type: models.CommandType_AddItem, Item: models.Item{ID: 3, Payload: "C"}},
+				{Type: models.CommandType_GetItem, Item: models.Item{ID: 2}},
+				{Type: models.CommandType_GetAllItems},
+			},
+			wantResults: []OperationResult{
+				{},
+				{},
+				{},
+				{},
+				{Items: []models.Item{{ID: 2, Payload: "B", Version: 1}}},
+				// versions of added items start after versions of removed ones
+				{Items: []models.Item{{ID: 2, Payload: "B", Version: 1}, {ID: 3, Payload: "C", Version: 2}}},
+			},
+			wantItems: []models.Item{{ID: 2, Payload: "B"}, {ID: 3, Payload: "C"}},
+			wantErr:   assert.NoError,
+		},
+		{
+			name:   "should not apply any operation when limits are exceeded in the middle",
+			limits: Limits{MaxItems: 2, Policy: EvictionPolicyReject},
+			fillRepo: func(r Repo) {
+				r.AddItem(context.Background(), models.Item{ID: 1, Payload: "A"})
+			},
+			ops: []Operation{
+				{Type: models.CommandType_AddItem, Item: models.Item{ID: 2, Payload: "B"}},
+				{Type: models.CommandType_AddItem, Item: models.Item{ID: 3, Payload: "C"}},
+				{Type: models.CommandType_RemoveItem, Item: models.Item{ID: 1}},
+			},
+			wantErr:   isCapacityExceeded,
+			wantItems: []models.Item{{ID: 1, Payload: "A"}},
+		},
+		{
+			name:   "should apply batch when items are removed before limits are exceeded",
+			limits: Limits{MaxItems: 2, MaxBytes: 3, Policy: EvictionPolicyReject},
+			fillRepo: func(r Repo) {
+				r.AddItem(context.Background(), models.Item{ID: 1, Payload: "A"})
+				r.AddItem(context.Background(), models.Item{ID: 2, Payload: "B"})
+			},
+			ops: []Operation{
+				{Type: models.CommandType_RemoveItem, Item: models.Item{ID: 1}},
+				{Type: models.CommandType_AddItem, Item: models.Item{ID: 3, Payload: "C"}},
+				{Type: models.CommandType_AddItem, Item: models.Item{ID: 2, Payload: "BB"}},
+			},
+			wantResults: []OperationResult{{}, {}, {}},
+			wantErr:     assert.NoError,
+			wantItems:   []models.Item{{ID: 2, Payload: "BB"}, {ID: 3, Payload: "C"}},
+		},
+		{
+			name:   "should evict items during batch by fifo policy",
+			limits: Limits{MaxItems: 2, Policy: EvictionPolicyFIFO},
+			fillRepo: func(r Repo) {
+				r.AddItem(context.Background(), models.Item{ID: 1, Payload: "A"})
+			},
+			ops: []Operation{
+				{Type: models.CommandType_AddItem, Item: models.Item{ID: 2, Payload: "B"}},
+				{Type: models.CommandType_AddItem, Item: models.Item{ID: 3, Payload: "C"}},
+			},
+			wantResults: []OperationResult{{}, {}},
+			wantErr:     assert.NoError,
+			wantItems:   []models.Item{{ID: 2, Payload: "B"}, {ID: 3, Payload: "C"}},
+		},
+		{
+			name:   "should not apply batch when updated item is evicted by previous operation",
+			limits: Limits{MaxItems: 2, Policy: EvictionPolicyFIFO},
+			fillRepo: func(r Repo) {
+				r.AddItem(context.Background(), models.Item{ID: 1, Payload: "A"})
+				r.AddItem(context.Background(), models.Item{ID: 2, Payload: "B"})
+			},
+			ops: []Operation{
+				{Type: models.CommandType_AddItem, Item: models.Item{ID: 3, Payload: "C"}},
+				{Type: models.CommandType_UpdateItem, Item: models.Item{ID: 1, Payload: "AA"}},
+			},
+			wantItems: []models.Item{{ID: 1, Payload: "A"}, {ID: 2, Payload: "B"}},
+			wantErr: func(t assert.TestingT, err error, msgAndArgs ...interface{}) bool {
+				return assert.ErrorIs(t, err, ErrItemNotFound, msgAndArgs...)
+			},
+		},
+		{
+			name:   "should not apply batch when removed item is evicted by lru policy",
+			limits: Limits{MaxItems: 2, Policy: EvictionPolicyLRU},
+			fillRepo: func(r Repo) {
+				r.AddItem(context.Background(), models.Item{ID: 1, Payload: "A"})
+				r.AddItem(context.Background(), models.Item{ID: 2, Payload: "B"})
+			},
+			ops: []Operation{
+				{Type: models.CommandType_GetItem, Item: models.Item{ID: 1}},
+				{Type: models.CommandType_AddItem, Item: models.Item{ID: 3, Payload: "C"}},
+				{Type: models.CommandType_RemoveItem, Item: models.Item{ID: 2}, ExpectedVersion: 1},
+			},
+			wantItems: []models.Item{{ID: 1, Payload: "A"}, {ID: 2, Payload: "B"}},
+			wantErr: func(t assert.TestingT, err error, msgAndArgs ...interface{}) bool {
+				return assert.ErrorIs(t, err, ErrItemNotFound, msgAndArgs...)
+			},
+		},
+		{
+			name:   "should update item which is read in batch by lru policy",
+			limits: Limits{MaxItems: 2, Policy: EvictionPolicyLRU},
+			fillRepo: func(r Repo) {
+				r.AddItem(context.Background(), models.Item{ID: 1, Payload: "A"})
+				r.AddItem(context.Background(), models.Item{ID: 2, Payload: "B"})
+			},
+			ops: []Operation{
+				{Type: models.CommandType_GetItem, Item: models.Item{ID: 1}},
+				{Type: models.CommandType_AddItem, Item: models.Item{ID: 3, Payload: "C"}},
+				{Type: models.CommandType_UpdateItem, Item: models.Item{ID: 1, Payload: "AA"}},
+			},
+			wantResults: []OperationResult{{Items: []models.Item{{ID: 1, Payload: "A", Version: 1}}}, {}, {}},
+			wantItems:   []models.Item{{ID: 1, Payload: "AA"}, {ID: 3, Payload: "C"}},
+			wantErr:     assert.NoError,
+		},
+		{
+			name: "should apply conditional operations when versions are correct",
+			fillRepo: func(r Repo) {
+				r.AddItem(context.Background(), models.Item{ID: 1, Payload: "A"})
+				r.AddItem(context.Background(), models.Item{ID: 2, Payload: "B"})
+			},
+			ops: []Operation{
+				{Type: models.CommandType_UpdateItem, Item: models.Item{ID: 1, Payload: "AA"}, ExpectedVersion: 1},
+				{Type: models.CommandType_UpdateItem, Item: models.Item{ID: 1, Payload: "AAA"}, ExpectedVersion: 2},
+				{Type: models.CommandType_RemoveItem, Item: models.Item{ID: 2}, ExpectedVersion: 1},
+				{Type: models.CommandType_GetItem, Item: models.Item{ID: 1}},
+			},
+			wantResults: []OperationResult{{}, {}, {}, {Items: []models.Item{{ID: 1, Payload: "AAA", Version: 3}}}},
+			wantItems:   []models.Item{{ID: 1, Payload: "AAA"}},
+			wantErr:     assert.NoError,
+		},
+		{
+			name: "should not apply batch when version is changed by previous operation",
+			fillRepo: func(r Repo) {
+				r.AddItem(context.Background(), models.Item{ID: 1, Payload: "A"})
+				r.AddItem(context.Background(), models.Item{ID: 2, Payload: "B"})
+			},
+			ops: []Operation{
+				{Type: models.CommandType_RemoveItem, Item: models.Item{ID: 2}},
+				{Type: models.CommandType_UpdateItem, Item: models.Item{ID: 1, Payload: "AA"}, ExpectedVersion: 1},
+				{Type: models.CommandType_RemoveItem, Item: models.Item{ID: 1}, ExpectedVersion: 1},
+			},
+			wantItems: []models.Item{{ID: 1, Payload: "A"}, {ID: 2, Payload: "B"}},
+			wantErr: func(t assert.TestingT, err error, msgAndArgs ...interface{}) bool {
+				return assert.ErrorIs(t, err, ErrVersionConflict, msgAndArgs...)
+			},
+		},
+		{
+			name: "should not apply batch when updated item does not exist",
+			fillRepo: func(r Repo) {
+				r.AddItem(context.Background(), models.Item{ID: 1, Payload: "A"})
+			},
+			ops: []Operation{
+				{Type: models.CommandType_AddItem, Item: models.Item{ID: 2, Payload: "B"}},
+				{Type: models.CommandType_UpdateItem, Item: models.Item{ID: 3, Payload: "C"}},
+			},
+			wantItems: []models.Item{{ID: 1, Payload: "A"}},
+			wantErr: func(t assert.TestingT, err error, msgAndArgs ...interface{}) bool {
+				return assert.ErrorIs(t, err, ErrItemNotFound, msgAndArgs...)
+			},
+		},
+		{
+			name: "should not apply batch with not supported command",
+			fillRepo: func(r Repo) {
+				r.AddItem(context.Background(), models.Item{ID: 1, Payload: "A"})
+			},
+			ops: []Operation{
+				{Type: models.CommandType_RemoveItem, Item: models.Item{ID: 1}},
+				{Type: models.CommandType_DropCollection},
+			},
+			wantErr:   assert.Error,
+			wantItems: []models.Item{{ID: 1, Payload: "A"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := New(WithLimits(tt.limits)).(*repoImpl)
+			tt.fillRepo(r)
+
+			results, err := r.ApplyBatch(context.Background(), tt.ops)
+			tt.wantErr(t, err)
+			for _, result := range results {
+				withoutSequences(t, result.Items)
+			}
+			assert.Equal(t, tt.wantResults, results)
+			assert.Equal(t, tt.wantItems, getAllItems(r))
+		})
+	}
+}
+
+func isCapacityExceeded(t assert.TestingT, err error, msgAndArgs ...interface{}) bool {
+	return assert.ErrorIs(t, err, ErrCapacityExceeded, msgAndArgs...)
+}
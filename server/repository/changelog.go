@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
+	"github.com/google/uuid"
+)
+
+const (
+	traceIDKey = "X-Trace-ID"
+
+	defaultChangeLogRetention = 10000
+)
+
+var ErrSequenceNotAvailable = errors.New("sequence is not available anymore")
+
+// changeLog assigns sequence numbers to change events and keeps the last events in a ring buffer,
+// so watchers can resume from any retained sequence number.
+type changeLog struct {
+	// id is unique for every change log, so sequence numbers of different server runs can be told apart
+	id string
+	// events[seq % len(events)] keeps the event with sequence seq
+	events  []*models.ChangeEvent
+	lastSeq uint64
+	// changed is closed and replaced when new events are appended
+	changed chan struct{}
+	now     func() time.Time
+	mx      sync.RWMutex
+}
+
+func newChangeLog(retention int) *changeLog {
+	if retention <= 0 {
+		retention = defaultChangeLogRetention
+	}
+	return &changeLog{
+		id:      uuid.NewString(),
+		events:  make([]*models.ChangeEvent, retention),
+		changed: make(chan struct{}),
+		now:     time.Now,
+	}
+}
+
+// append assigns the next sequence number to the event and stores it. Changes of one collection should be appended
+// under the collection write lock, so sequence numbers follow the order the changes are applied in.
+func (c *changeLog) append(ctx context.Context, event *models.ChangeEvent) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	c.lastSeq++
+	event.Sequence = c.lastSeq
+	event.Timestamp = c.now().UnixNano()
+	if traceID, ok := ctx.Value(traceIDKey).(string); ok {
+		event.TraceID = traceID
+	}
+	c.events[c.lastSeq%uint64(len(c.events))] = event
+
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+func (c *changeLog) sequence() uint64 {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+
+	return c.lastSeq
+}
+
+// read returns retained events starting from fromSeq and the channel which is closed when there are new events.
+func (c *changeLog) read(fromSeq uint64) ([]*models.ChangeEvent, <-chan struct{}, error) {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+
+	if fromSeq > c.lastSeq {
+		return nil, c.changed, nil
+	}
+	if c.lastSeq-fromSeq >= uint64(len(c.events)) {
+		return nil, nil, fmt.Errorf("%w: %d, oldest retained sequence is %d",
+			ErrSequenceNotAvailable, fromSeq, c.lastSeq-uint64(len(c.events))+1)
+	}
+
+	events := make([]*models.ChangeEvent, 0, c.lastSeq-fromSeq+1)
+	for seq := fromSeq; seq <= c.lastSeq; seq++ {
+		events = append(events, c.events[seq%uint64(len(c.events))])
+	}
+	return events, c.changed, nil
+}
+
+// watch sends events starting from fromSeq until ctx is done. Zero fromSeq means only new events.
+// Returned channel is closed when ctx is done or when the watcher is too slow and the next event is not retained.
+func (c *changeLog) watch(ctx context.Context, fromSeq uint64) (<-chan *models.ChangeEvent, error) {
+	if fromSeq == 0 {
+		fromSeq = c.sequence() + 1
+	}
+	if _, _, err := c.read(fromSeq); err != nil {
+		return nil, err
+	}
+
+	ch := make(chan *models.ChangeEvent)
+	go func() {
+		defer close(ch)
+
+		nextSeq := fromSeq
+		for {
+			events, changed, err := c.read(nextSeq)
+			if err != nil {
+				return
+			}
+
+			for _, event := range events {
+				select {
+				case ch <- event:
+					nextSeq = event.Sequence + 1
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			if len(events) == 0 {
+				select {
+				case <-changed:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return ch, nil
+}
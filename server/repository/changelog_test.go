@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_registryImpl_Watch(t *testing.T) {
+	r, err := NewRegistry(RegistryConfig{Collections: map[string]CollectionConfig{
+		"sessions": {Limits: Limits{MaxItems: 1, Policy: EvictionPolicyFIFO}},
+	}})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := r.Watch(ctx, 0)
+	require.NoError(t, err)
+
+	tracedCtx := context.WithValue(context.Background(), traceIDKey, "trace_id")
+	sessions, err := r.Collection(tracedCtx, "sessions")
+	require.NoError(t, err)
+	require.NoError(t, sessions.AddItem(tracedCtx, models.Item{ID: 1, Payload: "A"}))
+	_, err = sessions.UpdateItem(tracedCtx, models.Item{ID: 1, Payload: "B"}, 1)
+	require.NoError(t, err)
+	require.NoError(t, sessions.AddItem(tracedCtx, models.Item{ID: 2, Payload: "C"}))
+	// reads and not existing items do not produce events
+	_, err = sessions.GetItem(tracedCtx, 2)
+	require.NoError(t, err)
+	require.NoError(t, sessions.RemoveItem(tracedCtx, 3))
+	require.NoError(t, sessions.RemoveItem(tracedCtx, 2))
+	require.NoError(t, r.DropCollection(tracedCtx, "sessions"))
+
+	want := []*models.ChangeEvent{
+		{Sequence: 1, Op: models.ChangeOp_CollectionCreated, Collection: "sessions"},
+		{Sequence: 2, Op: models.ChangeOp_ItemAdded, Collection: "sessions", ItemID: 1, NewPayload: "A", Version: 1},
+		{Sequence: 3, Op: models.ChangeOp_ItemUpdated, Collection: "sessions", ItemID: 1, OldPayload: "A", NewPayload: "B", Version: 2},
+		{Sequence: 4, Op: models.ChangeOp_ItemEvicted, Collection: "sessions", ItemID: 1, OldPayload: "B", Version: 2},
+		{Sequence: 5, Op: models.ChangeOp_ItemAdded, Collection: "sessions", ItemID: 2, NewPayload: "C", Version: 3},
+		{Sequence: 6, Op: models.ChangeOp_ItemRemoved, Collection: "sessions", ItemID: 2, OldPayload: "C", Version: 3},
+		{Sequence: 7, Op: models.ChangeOp_CollectionDropped, Collection: "sessions"},
+	}
+	for _, wantEvent := range want {
+		select {
+		case event := <-events:
+			assert.NotZero(t, event.Timestamp)
+			assert.Equal(t, "trace_id", event.TraceID)
+			if event.ItemID != 0 {
+				assert.NotZero(t, event.ItemSequence)
+			}
+			event.Timestamp, event.TraceID, event.ItemSequence = 0, "", 0
+			assert.Equal(t, wantEvent.String(), event.String())
+		case <-time.After(time.Second):
+			t.Fatalf("event %d is not received", wantEvent.Sequence)
+		}
+	}
+	assert.Equal(t, uint64(7), r.Sequence())
+
+	// watching is resumed from the retained sequence
+	resumed, err := r.Watch(ctx, 6)
+	require.NoError(t, err)
+	assert.Equal(t, models.ChangeOp_ItemRemoved, (<-resumed).Op)
+	assert.Equal(t, models.ChangeOp_CollectionDropped, (<-resumed).Op)
+
+	cancel()
+	for range events {
+	}
+}
+
+func Test_changeLog_Retention(t *testing.T) {
+	c := newChangeLog(2)
+	for i := 0; i < 3; i++ {
+		c.append(context.Background(), &models.ChangeEvent{ItemID: int64(i)})
+	}
+
+	_, err := c.watch(context.Background(), 1)
+	assert.ErrorIs(t, err, ErrSequenceNotAvailable)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := c.watch(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), (<-events).Sequence)
+
+	// the watcher falls behind, so the channel is closed after the retained events
+	for i := 0; i < 3; i++ {
+		c.append(context.Background(), &models.ChangeEvent{})
+	}
+	for event := range events {
+		assert.LessOrEqual(t, event.Sequence, uint64(3))
+	}
+}
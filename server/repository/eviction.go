@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+var ErrCapacityExceeded = errors.New("storage capacity exceeded")
+
+// EvictionPolicy defines what happens when a new item does not fit into the storage limits.
+type EvictionPolicy string
+
+const (
+	// EvictionPolicyReject rejects new items with ErrCapacityExceeded.
+	EvictionPolicyReject EvictionPolicy = "reject"
+	// EvictionPolicyFIFO evicts the oldest items by insertion order.
+	EvictionPolicyFIFO EvictionPolicy = "fifo"
+	// EvictionPolicyLRU evicts the least recently accessed items.
+	EvictionPolicyLRU EvictionPolicy = "lru"
+)
+
+func ParseEvictionPolicy(s string) (EvictionPolicy, error) {
+	switch policy := EvictionPolicy(strings.ToLower(s)); policy {
+	case "":
+		return EvictionPolicyReject, nil
+	case EvictionPolicyReject, EvictionPolicyFIFO, EvictionPolicyLRU:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown eviction policy: %s", s)
+	}
+}
+
+// Limits of the storage. Zero value means no limit.
+type Limits struct {
+	MaxItems int
+	MaxBytes int64
+	Policy   EvictionPolicy
+}
+
+func (l Limits) exceeded(items int, bytes int64) bool {
+	return (l.MaxItems > 0 && items > l.MaxItems) || (l.MaxBytes > 0 && bytes > l.MaxBytes)
+}
+
+func WithLimits(limits Limits) Option {
+	return func(r *repoImpl) {
+		r.limits = limits
+		if limits.Policy == EvictionPolicyLRU {
+			r.lru = newAccessList()
+		}
+	}
+}
+
+type untrackedAccessKey struct{}
+
+// WithoutAccess returns the context of reads which do not change the access order of LRU eviction, e.g. local reads
+// of Raft nodes, which would make the eviction order differ between nodes.
+func WithoutAccess(ctx context.Context) context.Context {
+	return context.WithValue(ctx, untrackedAccessKey{}, true)
+}
+
+func accessTracked(ctx context.Context) bool {
+	untracked, _ := ctx.Value(untrackedAccessKey{}).(bool)
+	return !untracked
+}
+
+// accessList keeps item ids from the least to the most recently accessed.
+// It has own lock because items are accessed under the read lock of the repository.
+type accessList struct {
+	order *list.List
+	byID  map[int64]*list.Element
+	mx    sync.Mutex
+}
+
+func newAccessList() *accessList {
+	return &accessList{
+		order: list.New(),
+		byID:  make(map[int64]*list.Element),
+	}
+}
+
+func (a *accessList) touch(itemID int64) {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+
+	if elem, ok := a.byID[itemID]; ok {
+		a.order.MoveToBack(elem)
+		return
+	}
+	a.byID[itemID] = a.order.PushBack(itemID)
+}
+
+func (a *accessList) remove(itemID int64) {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+
+	if elem, ok := a.byID[itemID]; ok {
+		a.order.Remove(elem)
+		delete(a.byID, itemID)
+	}
+}
+
+// leastRecent returns the least recently accessed item id except the given one.
+func (a *accessList) leastRecent(except int64) (int64, bool) {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+
+	for elem := a.order.Front(); elem != nil; elem = elem.Next() {
+		if itemID := elem.Value.(int64); itemID != except {
+			return itemID, true
+		}
+	}
+	return 0, false
+}
+
+// ids returns item ids from the least to the most recently accessed.
+func (a *accessList) ids() []int64 {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+
+	ids := make([]int64, 0, a.order.Len())
+	for elem := a.order.Front(); elem != nil; elem = elem.Next() {
+		ids = append(ids, elem.Value.(int64))
+	}
+	return ids
+}
+
+// restoreAccessOrder touches the stored items in the given order, so the least recently accessed items
+// are evicted first as before the snapshot.
+func (r *repoImpl) restoreAccessOrder(ids []int64) {
+	if r.lru == nil {
+		return
+	}
+
+	r.rwMx.RLock()
+	defer r.rwMx.RUnlock()
+
+	for _, itemID := range ids {
+		if _, ok := r.storage.Get(itemID); ok {
+			r.lru.touch(itemID)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"container/heap"
+	"time"
+)
+
+type expiryEntry struct {
+	itemID    int64
+	expiresAt time.Time
+	index     int
+}
+
+// expiryIndex is a min-heap of item expiration times with lookup by item id,
+// so the next item to expire is always available in O(1) and updates take O(log n).
+type expiryIndex struct {
+	entries []*expiryEntry
+	byID    map[int64]*expiryEntry
+}
+
+func newExpiryIndex() *expiryIndex {
+	return &expiryIndex{
+		byID: make(map[int64]*expiryEntry),
+	}
+}
+
+func (e *expiryIndex) Len() int { return len(e.entries) }
+
+func (e *expiryIndex) Less(i, j int) bool {
+	return e.entries[i].expiresAt.Before(e.entries[j].expiresAt)
+}
+
+func (e *expiryIndex) Swap(i, j int) {
+	e.entries[i], e.entries[j] = e.entries[j], e.entries[i]
+	e.entries[i].index = i
+	e.entries[j].index = j
+}
+
+func (e *expiryIndex) Push(x any) {
+	entry := x.(*expiryEntry)
+	entry.index = len(e.entries)
+	e.entries = append(e.entries, entry)
+}
+
+func (e *expiryIndex) Pop() any {
+	last := len(e.entries) - 1
+	entry := e.entries[last]
+	e.entries[last] = nil
+	e.entries = e.entries[:last]
+	entry.index = -1
+	return entry
+}
+
+// set adds or moves expiration time of the item.
+func (e *expiryIndex) set(itemID int64, expiresAt time.Time) {
+	if entry, ok := e.byID[itemID]; ok {
+		entry.expiresAt = expiresAt
+		heap.Fix(e, entry.index)
+		return
+	}
+
+	entry := &expiryEntry{itemID: itemID, expiresAt: expiresAt}
+	heap.Push(e, entry)
+	e.byID[itemID] = entry
+}
+
+func (e *expiryIndex) remove(itemID int64) {
+	entry, ok := e.byID[itemID]
+	if !ok {
+		return
+	}
+	heap.Remove(e, entry.index)
+	delete(e.byID, itemID)
+}
+
+func (e *expiryIndex) get(itemID int64) (time.Time, bool) {
+	entry, ok := e.byID[itemID]
+	if !ok {
+		return time.Time{}, false
+	}
+	return entry.expiresAt, true
+}
+
+// countExpired returns the number of items which are expired at the given moment.
+func (e *expiryIndex) countExpired(now time.Time) int {
+	count := 0
+	for _, entry := range e.entries {
+		if !now.Before(entry.expiresAt) {
+			count++
+		}
+	}
+	return count
+}
+
+// popExpired removes from the index all items which are expired at the given moment and returns their ids.
+func (e *expiryIndex) popExpired(now time.Time) []int64 {
+	var itemIDs []int64
+	for len(e.entries) > 0 && !now.Before(e.entries[0].expiresAt) {
+		entry := heap.Pop(e).(*expiryEntry)
+		delete(e.byID, entry.itemID)
+		itemIDs = append(itemIDs, entry.itemID)
+	}
+	return itemIDs
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const defaultReaperInterval = time.Second
+
+// Reaper periodically removes expired items from all collections.
+type Reaper struct {
+	registry Registry
+	interval time.Duration
+	quit     chan struct{}
+	done     chan struct{}
+}
+
+func NewReaper(registry Registry, interval time.Duration) *Reaper {
+	if interval <= 0 {
+		interval = defaultReaperInterval
+	}
+	return &Reaper{
+		registry: registry,
+		interval: interval,
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+func (r *Reaper) Start() {
+	go func() {
+		defer close(r.done)
+
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				RemoveExpiredItems(context.Background(), r.registry)
+			case <-r.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Quit stops the reaper and waits until the running iteration is finished.
+func (r *Reaper) Quit() {
+	close(r.quit)
+	<-r.done
+}
+
+// RemoveExpiredItems removes expired items from all collections of the registry.
+func RemoveExpiredItems(ctx context.Context, registry Registry) {
+	for _, name := range registry.Collections() {
+		repo, err := registry.GetCollection(name)
+		if err != nil {
+			// collection was dropped in the meantime
+			continue
+		}
+
+		items, err := repo.RemoveExpiredItems(ctx)
+		if err != nil {
+			log.WithField("Collection", name).Errorf("Cannot remove expired items: %v", err)
+			continue
+		}
+
+		for _, item := range items {
+			log.WithFields(logrus.Fields{"Collection": name, "ItemID": item.ID}).Debug("Item was expired and removed.")
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/metrics"
+)
+
+const DefaultCollection = "default"
+
+var (
+	ErrCollectionNotFound      = errors.New("collection not found")
+	ErrCollectionAlreadyExists = errors.New("collection already exists")
+	ErrInvalidCollectionName   = errors.New("invalid collection name")
+
+	collectionNameRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+)
+
+type Option func(r *repoImpl)
+
+func WithDefaultTTL(ttl time.Duration) Option {
+	return func(r *repoImpl) {
+		r.defaultTTL = ttl
+	}
+}
+
+func withClock(now func() time.Time) Option {
+	return func(r *repoImpl) {
+		r.now = now
+	}
+}
+
+func withName(name string) Option {
+	return func(r *repoImpl) {
+		r.name = name
+	}
+}
+
+type CollectionConfig struct {
+	Limits Limits
+	// DefaultTTL is applied to items added without TTL, zero means such items never expire.
+	DefaultTTL time.Duration
+	// Persistent collections are saved to the data directory on shutdown and loaded on start.
+	Persistent bool
+}
+
+//go:generate mockgen -package=repository -source=registry.go -destination=registry_mock.go
+type Registry interface {
+	// Collection returns the collection by name creating it on demand.
+	Collection(ctx context.Context, name string) (Repo, error)
+	// GetCollection returns ErrCollectionNotFound if the collection does not exist.
+	GetCollection(name string) (Repo, error)
+	CreateCollection(ctx context.Context, name string) error
+	DropCollection(ctx context.Context, name string) error
+	// Collections returns names of all collections in alphabetical order.
+	Collections() []string
+	// Save writes all persistent collections to the data directory.
+	Save() error
+	// Watch returns change events of all collections in the order they are applied starting from sequence fromSeq,
+	// zero fromSeq means only new events. ErrSequenceNotAvailable is returned when fromSeq is not retained anymore.
+	// The channel is closed when ctx is done or when the watcher falls behind the retained events,
+	// in this case watching can be resumed from the sequence after the last received event.
+	Watch(ctx context.Context, fromSeq uint64) (<-chan *models.ChangeEvent, error)
+	// Sequence returns the sequence number of the last change event.
+	Sequence() uint64
+	// ChangeLogID returns the id which is unique for the change log of every registry.
+	ChangeLogID() string
+
+	// Snapshot returns consistent copy of all collections together with the sequence of the last applied change.
+	Snapshot() *ReplicaSnapshot
+	// Restore replaces all collections with the snapshot of the primary.
+	Restore(snapshot *ReplicaSnapshot) error
+	// Replicate applies the change event of the primary.
+	Replicate(ctx context.Context, event *models.ChangeEvent) error
+}
+
+type RegistryConfig struct {
+	// DataDir is where persistent collections are saved.
+	DataDir string
+	// Defaults is used for collections which have no own config in Collections.
+	Defaults    CollectionConfig
+	Collections map[string]CollectionConfig
+	// ChangeLogRetention is the number of the last change events available for Watch.
+	ChangeLogRetention int
+	// Now is the clock of all collections, time.Now by default.
+	Now func() time.Time
+}
+
+type registryImpl struct {
+	collections map[string]Repo
+	defaults    CollectionConfig
+	configs     map[string]CollectionConfig
+	snapshots   *snapshotStore
+	changes     *changeLog
+	now         func() time.Time
+	mx          sync.RWMutex
+}
+
+// NewRegistry creates registry of collections. Persistent collections which are found in the data directory
+// are loaded immediately.
+func NewRegistry(config RegistryConfig) (Registry, error) {
+	r := &registryImpl{
+		collections: make(map[string]Repo),
+		defaults:    config.Defaults,
+		configs:     config.Collections,
+		snapshots:   newSnapshotStore(config.DataDir),
+		changes:     newChangeLog(config.ChangeLogRetention),
+		now:         config.Now,
+	}
+	if r.now == nil {
+		r.now = time.Now
+	}
+	r.changes.now = r.now
+
+	names, err := r.snapshots.list()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if !r.config(name).Persistent {
+			continue
+		}
+		if _, err := r.create(context.Background(), name); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+func (r *registryImpl) Collection(ctx context.Context, name string) (Repo, error) {
+	name = collectionName(name)
+	if repo, err := r.GetCollection(name); err == nil {
+		return repo, nil
+	}
+
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	if repo, ok := r.collections[name]; ok {
+		return repo, nil
+	}
+	return r.create(ctx, name)
+}
+
+func (r *registryImpl) GetCollection(name string) (Repo, error) {
+	r.mx.RLock()
+	defer r.mx.RUnlock()
+
+	repo, ok := r.collections[collectionName(name)]
+	if !ok {
+		return nil, ErrCollectionNotFound
+	}
+	return repo, nil
+}
+
+func (r *registryImpl) CreateCollection(ctx context.Context, name string) error {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	name = collectionName(name)
+	if _, ok := r.collections[name]; ok {
+		return ErrCollectionAlreadyExists
+	}
+	_, err := r.create(ctx, name)
+	return err
+}
+
+func (r *registryImpl) DropCollection(ctx context.Context, name string) error {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	name = collectionName(name)
+	if _, ok := r.collections[name]; !ok {
+		return ErrCollectionNotFound
+	}
+	if r.config(name).Persistent {
+		if err := r.snapshots.remove(name); err != nil {
+			return err
+		}
+	}
+
+	r.detach(name)
+	r.changes.append(ctx, &models.ChangeEvent{Op: models.ChangeOp_CollectionDropped, Collection: name})
+	return nil
+}
+
+func (r *registryImpl) Collections() []string {
+	r.mx.RLock()
+	defer r.mx.RUnlock()
+
+	names := make([]string, 0, len(r.collections))
+	for name := range r.collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (r *registryImpl) Save() error {
+	r.mx.RLock()
+	defer r.mx.RUnlock()
+
+	for name, repo := range r.collections {
+		if !r.config(name).Persistent {
+			continue
+		}
+
+		items, removedVersion := repo.(*repoImpl).snapshot()
+		if err := r.snapshots.save(name, items, removedVersion); err != nil {
+			return fmt.Errorf("cannot save collection %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (r *registryImpl) Watch(ctx context.Context, fromSeq uint64) (<-chan *models.ChangeEvent, error) {
+	return r.changes.watch(ctx, fromSeq)
+}
+
+func (r *registryImpl) Sequence() uint64 {
+	return r.changes.sequence()
+}
+
+// create should be called under the write lock.
+func (r *registryImpl) create(ctx context.Context, name string) (Repo, error) {
+	if !collectionNameRe.MatchString(name) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidCollectionName, name)
+	}
+
+	config := r.config(name)
+	repo := r.newCollection(name)
+	if config.Persistent {
+		items, removedVersion, err := r.snapshots.load(name, r.now())
+		if err != nil {
+			return nil, fmt.Errorf("cannot load collection %s: %w", name, err)
+		}
+		if err := repo.restore(items, removedVersion); err != nil {
+			return nil, fmt.Errorf("cannot load collection %s: %w", name, err)
+		}
+	}
+
+	r.changes.append(ctx, &models.ChangeEvent{Op: models.ChangeOp_CollectionCreated, Collection: name})
+	repo.changes = r.changes
+	r.collections[name] = repo
+	return repo, nil
+}
+
+func (r *registryImpl) newCollection(name string) *repoImpl {
+	config := r.config(name)
+	return New(withName(name), withClock(r.now), WithLimits(config.Limits), WithDefaultTTL(config.DefaultTTL)).(*repoImpl)
+}
+
+// detach removes the collection from the registry. It should be called under the write lock.
+func (r *registryImpl) detach(name string) {
+	repo := r.collections[name].(*repoImpl)
+	delete(r.collections, name)
+	metrics.DeleteCollection(name)
+
+	// writes to the removed collection are not a part of the change log anymore
+	repo.rwMx.Lock()
+	repo.changes = nil
+	repo.rwMx.Unlock()
+}
+
+func (r *registryImpl) config(name string) CollectionConfig {
+	if config, ok := r.configs[name]; ok {
+		return config
+	}
+	return r.defaults
+}
+
+func collectionName(name string) string {
+	if name == "" {
+		return DefaultCollection
+	}
+	return name
+}
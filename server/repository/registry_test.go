@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_registryImpl_Collections(t *testing.T) {
+	r, err := NewRegistry(RegistryConfig{})
+	require.NoError(t, err)
+
+	_, err = r.GetCollection("")
+	assert.ErrorIs(t, err, ErrCollectionNotFound)
+
+	defaultRepo, err := r.Collection(context.Background(), "")
+	require.NoError(t, err)
+	sameRepo, err := r.GetCollection(DefaultCollection)
+	require.NoError(t, err)
+	assert.Same(t, defaultRepo, sameRepo)
+
+	assert.NoError(t, r.CreateCollection(context.Background(), "sessions"))
+	assert.ErrorIs(t, r.CreateCollection(context.Background(), "sessions"), ErrCollectionAlreadyExists)
+	assert.ErrorIs(t, r.CreateCollection(context.Background(), "../sessions"), ErrInvalidCollectionName)
+	assert.Equal(t, []string{DefaultCollection, "sessions"}, r.Collections())
+
+	sessions, err := r.GetCollection("sessions")
+	require.NoError(t, err)
+	defaultRepo.AddItem(context.Background(), models.Item{ID: 1, Payload: "A"})
+	sessions.AddItem(context.Background(), models.Item{ID: 1, Payload: "B"})
+
+	item, err := defaultRepo.GetItem(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "A", item.Payload)
+	item, err = sessions.GetItem(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "B", item.Payload)
+
+	assert.NoError(t, r.DropCollection(context.Background(), "sessions"))
+	assert.ErrorIs(t, r.DropCollection(context.Background(), "sessions"), ErrCollectionNotFound)
+	assert.Equal(t, []string{DefaultCollection}, r.Collections())
+}
+
+func Test_registryImpl_CollectionConfig(t *testing.T) {
+	r, err := NewRegistry(RegistryConfig{Collections: map[string]CollectionConfig{
+		"sessions": {
+			Limits:     Limits{MaxItems: 1, Policy: EvictionPolicyFIFO},
+			DefaultTTL: time.Minute,
+		},
+	}})
+	require.NoError(t, err)
+
+	sessions, err := r.Collection(context.Background(), "sessions")
+	require.NoError(t, err)
+	sessions.AddItem(context.Background(), models.Item{ID: 1, Payload: "A"})
+	sessions.AddItem(context.Background(), models.Item{ID: 2, Payload: "B"})
+
+	items, err := sessions.GetAllItems(context.Background())
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, int64(2), items[0].ID)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), items[0].ExpiresAt, time.Second)
+
+	other, err := r.Collection(context.Background(), "other")
+	require.NoError(t, err)
+	other.AddItem(context.Background(), models.Item{ID: 1, Payload: "A"})
+	other.AddItem(context.Background(), models.Item{ID: 2, Payload: "B"})
+
+	items, err = other.GetAllItems(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []models.Item{{ID: 1, Payload: "A", Version: 1}, {ID: 2, Payload: "B", Version: 1}}, withoutSequences(t, items))
+}
+
+func Test_registryImpl_Persistence(t *testing.T) {
+	dataDir := t.TempDir()
+	configs := map[string]CollectionConfig{
+		"persistent": {Persistent: true},
+	}
+	expiresAt := time.Now().Add(time.Hour).Round(0)
+
+	r, err := NewRegistry(RegistryConfig{DataDir: dataDir, Collections: configs})
+	require.NoError(t, err)
+	persistent, err := r.Collection(context.Background(), "persistent")
+	require.NoError(t, err)
+	persistent.AddItem(context.Background(), models.Item{ID: 2, Payload: "B"})
+	persistent.AddItem(context.Background(), models.Item{ID: 2, Payload: "B"})
+	persistent.AddItem(context.Background(), models.Item{ID: 1, Payload: "A", ExpiresAt: expiresAt})
+	for i := 0; i < 3; i++ {
+		persistent.AddItem(context.Background(), models.Item{ID: 3, Payload: "C", ExpiresAt: time.Now().Add(-time.Second)})
+	}
+	persistent.AddItem(context.Background(), models.Item{ID: 4, Payload: "D"})
+	persistent.RemoveItem(context.Background(), 4)
+	inMemory, err := r.Collection(context.Background(), "in-memory")
+	require.NoError(t, err)
+	inMemory.AddItem(context.Background(), models.Item{ID: 1, Payload: "A"})
+	saved, err := persistent.GetAllItems(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, r.Save())
+
+	r, err = NewRegistry(RegistryConfig{DataDir: dataDir, Collections: configs})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"persistent"}, r.Collections())
+
+	persistent, err = r.GetCollection("persistent")
+	require.NoError(t, err)
+	items, err := persistent.GetAllItems(context.Background())
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	assert.Equal(t, models.Item{ID: 2, Payload: "B", Version: 2, Sequence: saved[0].Sequence}, items[0])
+	assert.Equal(t, saved[1].Sequence, items[1].Sequence)
+	assert.Equal(t, int64(1), items[1].ID)
+	assert.True(t, expiresAt.Equal(items[1].ExpiresAt))
+
+	// versions of removed and expired items are not repeated after restart
+	require.NoError(t, persistent.AddItem(context.Background(), models.Item{ID: 4, Payload: "D"}))
+	item, err := persistent.GetItem(context.Background(), 4)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4), item.Version)
+
+	require.NoError(t, r.DropCollection(context.Background(), "persistent"))
+	r, err = NewRegistry(RegistryConfig{DataDir: dataDir, Collections: configs})
+	require.NoError(t, err)
+	assert.Empty(t, r.Collections())
+}
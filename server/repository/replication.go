@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
+)
+
+// ReplicaSnapshot is the state of all collections after the change with sequence number Sequence is applied.
+type ReplicaSnapshot struct {
+	ChangeLogID string               `json:"change_log_id"`
+	Sequence    uint64               `json:"sequence"`
+	Collections []CollectionSnapshot `json:"collections"`
+}
+
+type CollectionSnapshot struct {
+	Name string `json:"name"`
+	// Items are in insertion order.
+	Items []SnapshotItem `json:"items"`
+	// AccessOrder is ids of items from the least to the most recently accessed, set only for EvictionPolicyLRU.
+	AccessOrder []int64 `json:"access_order,omitempty"`
+	// RemovedVersion is the highest version of removed items.
+	RemovedVersion uint64 `json:"removed_version,omitempty"`
+}
+
+func (r *registryImpl) ChangeLogID() string {
+	return r.changes.id
+}
+
+func (r *registryImpl) Snapshot() *ReplicaSnapshot {
+	// changes are appended under the locks of the registry or the collection, so no change can be applied
+	// while all of them are held
+	r.mx.RLock()
+	defer r.mx.RUnlock()
+
+	names := make([]string, 0, len(r.collections))
+	for name := range r.collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	snapshot := &ReplicaSnapshot{
+		ChangeLogID: r.changes.id,
+		Collections: make([]CollectionSnapshot, 0, len(names)),
+	}
+	for _, name := range names {
+		repo := r.collections[name].(*repoImpl)
+		repo.rwMx.RLock()
+		defer repo.rwMx.RUnlock()
+
+		collection := CollectionSnapshot{
+			Name:           name,
+			Items:          toSnapshotItems(repo.allLocked()),
+			RemovedVersion: repo.removedVersionLocked(),
+		}
+		if repo.lru != nil {
+			collection.AccessOrder = repo.lru.ids()
+		}
+		snapshot.Collections = append(snapshot.Collections, collection)
+	}
+	snapshot.Sequence = r.changes.sequence()
+	return snapshot
+}
+
+func (r *registryImpl) Restore(snapshot *ReplicaSnapshot) error {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	for name := range r.collections {
+		r.detach(name)
+	}
+
+	now := r.now()
+	for _, collection := range snapshot.Collections {
+		if !collectionNameRe.MatchString(collection.Name) {
+			return fmt.Errorf("%w: %q", ErrInvalidCollectionName, collection.Name)
+		}
+
+		repo := r.newCollection(collection.Name)
+		if err := repo.restore(fromSnapshotItems(collection.Items, now), collection.RemovedVersion); err != nil {
+			return fmt.Errorf("cannot restore collection %s: %w", collection.Name, err)
+		}
+		repo.restoreAccessOrder(collection.AccessOrder)
+		repo.changes = r.changes
+		r.collections[collection.Name] = repo
+	}
+	return nil
+}
+
+func (r *registryImpl) Replicate(ctx context.Context, event *models.ChangeEvent) error {
+	switch event.Op {
+	case models.ChangeOp_CollectionCreated:
+		err := r.CreateCollection(ctx, event.Collection)
+		if errors.Is(err, ErrCollectionAlreadyExists) {
+			return nil
+		}
+		return err
+	case models.ChangeOp_CollectionDropped:
+		err := r.DropCollection(ctx, event.Collection)
+		if errors.Is(err, ErrCollectionNotFound) {
+			return nil
+		}
+		return err
+	default:
+		repo, err := r.Collection(ctx, event.Collection)
+		if err != nil {
+			return err
+		}
+		return repo.(*repoImpl).replicate(ctx, event)
+	}
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_registryImpl_Replicate(t *testing.T) {
+	ctx := context.Background()
+	primary, err := NewRegistry(RegistryConfig{Collections: map[string]CollectionConfig{
+		"sessions": {Limits: Limits{MaxItems: 2, Policy: EvictionPolicyFIFO}},
+	}})
+	require.NoError(t, err)
+
+	defaultRepo, err := primary.Collection(ctx, "")
+	require.NoError(t, err)
+	require.NoError(t, defaultRepo.AddItem(ctx, models.Item{ID: 1, Payload: "A", ExpiresAt: time.Now().Add(time.Hour)}))
+	require.NoError(t, defaultRepo.AddItem(ctx, models.Item{ID: 2, Payload: "B"}))
+
+	// replica starts from the snapshot and applies the following changes
+	snapshot := primary.Snapshot()
+	assert.Equal(t, uint64(3), snapshot.Sequence)
+	assert.Equal(t, primary.ChangeLogID(), snapshot.ChangeLogID)
+	events, err := primary.Watch(ctx, snapshot.Sequence+1)
+	require.NoError(t, err)
+
+	sessions, err := primary.Collection(ctx, "sessions")
+	require.NoError(t, err)
+	for i := int64(1); i <= 3; i++ {
+		require.NoError(t, sessions.AddItem(ctx, models.Item{ID: i, Payload: "S"}))
+	}
+	_, err = defaultRepo.UpdateItem(ctx, models.Item{ID: 2, Payload: "BB"}, 1)
+	require.NoError(t, err)
+	require.NoError(t, defaultRepo.RemoveItem(ctx, 1))
+	require.NoError(t, defaultRepo.AddItem(ctx, models.Item{ID: 1, Payload: "A"}))
+	require.NoError(t, primary.CreateCollection(ctx, "dropped"))
+	require.NoError(t, primary.DropCollection(ctx, "dropped"))
+
+	replica, err := NewRegistry(RegistryConfig{})
+	require.NoError(t, err)
+	// replica is restored from scratch
+	require.NoError(t, replica.CreateCollection(ctx, "stale"))
+	require.NoError(t, replica.Restore(snapshot))
+	for seq := snapshot.Sequence + 1; seq <= primary.Sequence(); seq++ {
+		event := <-events
+		require.Equal(t, seq, event.Sequence)
+		require.NoError(t, replica.Replicate(ctx, event))
+	}
+
+	want := primary.Snapshot()
+	got := replica.Snapshot()
+	assert.Equal(t, want.Collections, got.Collections)
+	assert.Equal(t, []string{DefaultCollection, "sessions"}, replica.Collections())
+
+	// item 1 is evicted before item 3 is added
+	item, err := replica.(*registryImpl).collections["sessions"].GetItem(ctx, 3)
+	require.NoError(t, err)
+	assert.Equal(t, models.Item{ID: 3, Payload: "S", Version: 2}, withoutSequences(t, []models.Item{item})[0])
+
+	// removed item added again does not repeat its versions
+	item, err = replica.(*registryImpl).collections[DefaultCollection].GetItem(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), item.Version)
+}
+
+func Test_registryImpl_ReplicateUnknownOp(t *testing.T) {
+	r, err := NewRegistry(RegistryConfig{})
+	require.NoError(t, err)
+
+	assert.Error(t, r.Replicate(context.Background(), &models.ChangeEvent{Op: models.ChangeOp(100)}))
+}
@@ -1,79 +1,563 @@
 package repository
 
 import (
+	"context"
 	"errors"
+	"expvar"
+	"fmt"
 	"sync"
+	"time"
 
+	"github.com/dliakhov/bloxroutelabs/client-server-app/logging"
 	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/metrics"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/tracing"
 	"github.com/emirpasic/gods/maps/linkedhashmap"
+	"github.com/sirupsen/logrus"
+)
+
+var log = logging.Logger("server/repository")
+
+var (
+	ErrItemNotFound    = errors.New("item not found")
+	ErrVersionConflict = errors.New("item version conflict")
 )
 
 //go:generate mockgen -package=repository -source=repo.go -destination=repo_mock.go
 type Repo interface {
-	AddItem(item models.Item) error
-	RemoveItem(itemID int64) error
-	GetItem(itemID int64) (models.Item, error)
-	GetAllItems() ([]models.Item, error)
+	AddItem(ctx context.Context, item models.Item) error
+	// UpdateItem changes payload of the existing item and returns its new version. When expectedVersion is not zero,
+	// the item is updated only if its current version is the same, otherwise ErrVersionConflict is returned.
+	// The item keeps its expiration time if item.ExpiresAt is zero.
+	UpdateItem(ctx context.Context, item models.Item, expectedVersion uint64) (uint64, error)
+	RemoveItem(ctx context.Context, itemID int64) error
+	// RemoveItemIfVersion removes the item only if its current version is expectedVersion.
+	RemoveItemIfVersion(ctx context.Context, itemID int64, expectedVersion uint64) error
+	GetItem(ctx context.Context, itemID int64) (models.Item, error)
+	GetAllItems(ctx context.Context) ([]models.Item, error)
+	// Count returns the number of items which are not expired without copying them.
+	Count(ctx context.Context) (int, error)
+	// RemoveExpiredItems deletes items which TTL is elapsed and returns them.
+	RemoveExpiredItems(ctx context.Context) ([]models.Item, error)
+	// ApplyBatch applies all operations in order under one lock. Either all operations are applied or none of them.
+	ApplyBatch(ctx context.Context, ops []Operation) ([]OperationResult, error)
 }
 
 type repoImpl struct {
+	name    string
 	storage *linkedhashmap.Map
+	expiry  *expiryIndex
+	// versions start after removedVersion when the item is added and are incremented on every change of the item
+	versions map[int64]uint64
+	// removedVersion is the highest version of removed items, so versions of an item removed and added again
+	// never repeat
+	removedVersion uint64
+	// sequences keep insertion sequences of items, lastSequence is the last assigned one
+	sequences    map[int64]uint64
+	lastSequence uint64
+	limits       Limits
+	// lru is set only for EvictionPolicyLRU
+	lru        *accessList
+	defaultTTL time.Duration
+	// changes is nil when the repository is not a part of the registry
+	changes *changeLog
+	bytes   int64
+	now     func() time.Time
 	rwMx    sync.RWMutex
+
+	storedItems *expvar.Int
+	storedBytes *expvar.Int
+}
+
+func New(opts ...Option) Repo {
+	r := &repoImpl{
+		name:      DefaultCollection,
+		storage:   linkedhashmap.New(),
+		expiry:    newExpiryIndex(),
+		versions:  make(map[int64]uint64),
+		sequences: make(map[int64]uint64),
+		now:       time.Now,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	r.storedItems = metrics.Gauge(metrics.StoredItems, r.name)
+	r.storedBytes = metrics.Gauge(metrics.StoredBytes, r.name)
+	return r
 }
 
-func New() Repo {
-	return &repoImpl{
-		storage: linkedhashmap.New(),
+func (r *repoImpl) AddItem(ctx context.Context, item models.Item) (err error) {
+	ctx, span := r.startSpan(ctx, "AddItem", itemIDAttribute(item.ID))
+	defer func() { tracing.End(span, err) }()
+
+	evicted, err := r.addItem(ctx, item)
+	if errors.Is(err, ErrCapacityExceeded) {
+		metrics.RejectedItems.Add(r.name, 1)
 	}
+	r.reportEvicted(evicted)
+	return err
 }
 
-func (r *repoImpl) AddItem(item models.Item) error {
+func (r *repoImpl) addItem(ctx context.Context, item models.Item) ([]models.Item, error) {
 	r.rwMx.Lock()
 	defer r.rwMx.Unlock()
 
+	return r.addLocked(ctx, item)
+}
+
+// addLocked should be called under the write lock.
+func (r *repoImpl) addLocked(ctx context.Context, item models.Item) ([]models.Item, error) {
+	if item.ExpiresAt.IsZero() && r.defaultTTL > 0 {
+		item.ExpiresAt = r.now().Add(r.defaultTTL)
+	}
+
+	evicted, err := r.makeRoom(ctx, item)
+	if err != nil {
+		return evicted, err
+	}
+
+	event := &models.ChangeEvent{
+		Op:         models.ChangeOp_ItemAdded,
+		ItemID:     item.ID,
+		NewPayload: item.Payload,
+	}
+	if oldPayload, ok := r.putLocked(item); ok {
+		event.Op = models.ChangeOp_ItemUpdated
+		event.OldPayload = oldPayload
+	}
+	if _, ok := r.versions[item.ID]; !ok {
+		r.versions[item.ID] = r.removedVersion
+	}
+	r.versions[item.ID]++
+	r.updateMetrics()
+
+	event.Version = r.versions[item.ID]
+	event.ItemSequence = r.sequences[item.ID]
+	if !item.ExpiresAt.IsZero() {
+		event.ExpiresAt = item.ExpiresAt.UnixNano()
+	}
+	r.emit(ctx, event)
+	return evicted, nil
+}
+
+// putLocked stores the item and returns the previous payload if the item existed. New item gets item.Sequence
+// or the next insertion sequence if it is zero. It should be called under the write lock.
+func (r *repoImpl) putLocked(item models.Item) (string, bool) {
+	// existing item keeps its position in the insertion order
+	oldValue, exists := r.storage.Get(item.ID)
+	oldPayload, _ := oldValue.(string)
+	if exists {
+		r.bytes -= itemSize(oldPayload)
+	} else {
+		r.sequences[item.ID] = r.nextSequence(item.Sequence)
+	}
 	r.storage.Put(item.ID, item.Payload)
+	r.bytes += itemSize(item.Payload)
+	if item.ExpiresAt.IsZero() {
+		r.expiry.remove(item.ID)
+	} else {
+		r.expiry.set(item.ID, item.ExpiresAt)
+	}
+	if r.lru != nil {
+		r.lru.touch(item.ID)
+	}
+	return oldPayload, exists
+}
+
+// nextSequence returns insertion sequence which is the time of insertion in Unix nanoseconds unless it is not greater
+// than the last one. Given sequence is kept if it is not zero. It should be called under the write lock.
+func (r *repoImpl) nextSequence(sequence uint64) uint64 {
+	if sequence == 0 {
+		sequence = uint64(r.now().UnixNano())
+		if sequence <= r.lastSequence {
+			sequence = r.lastSequence + 1
+		}
+	}
+	if sequence > r.lastSequence {
+		r.lastSequence = sequence
+	}
+	return sequence
+}
+
+// makeRoom removes expired items and evicts items according to the policy until the item fits into the limits.
+func (r *repoImpl) makeRoom(ctx context.Context, item models.Item) ([]models.Item, error) {
+	if r.limits.MaxBytes > 0 && itemSize(item.Payload) > r.limits.MaxBytes {
+		return nil, ErrCapacityExceeded
+	}
+
+	fits := func() bool {
+		items, bytes := r.storage.Size()+1, r.bytes+itemSize(item.Payload)
+		if oldValue, ok := r.storage.Get(item.ID); ok {
+			items--
+			bytes -= itemSize(oldValue.(string))
+		}
+		return !r.limits.exceeded(items, bytes)
+	}
+	if fits() {
+		return nil, nil
+	}
+
+	r.removeExpiredLocked(ctx)
+
+	var evicted []models.Item
+	for !fits() {
+		if r.limits.Policy != EvictionPolicyFIFO && r.limits.Policy != EvictionPolicyLRU {
+			return evicted, ErrCapacityExceeded
+		}
+
+		victimID, ok := r.victim(item.ID)
+		if !ok {
+			return evicted, ErrCapacityExceeded
+		}
+		evicted = append(evicted, r.deleteLocked(ctx, victimID, models.ChangeOp_ItemEvicted))
+	}
+	return evicted, nil
+}
+
+// victim returns the item which should be evicted next according to the policy.
+func (r *repoImpl) victim(except int64) (int64, bool) {
+	if r.lru != nil {
+		return r.lru.leastRecent(except)
+	}
+
+	it := r.storage.Iterator()
+	for it.Next() {
+		if itemID := it.Key().(int64); itemID != except {
+			return itemID, true
+		}
+	}
+	return 0, false
+}
+
+func (r *repoImpl) RemoveItem(ctx context.Context, itemID int64) (err error) {
+	ctx, span := r.startSpan(ctx, "RemoveItem", itemIDAttribute(itemID))
+	defer func() { tracing.End(span, err) }()
+
+	r.rwMx.Lock()
+	defer r.rwMx.Unlock()
+
+	r.deleteLocked(ctx, itemID, models.ChangeOp_ItemRemoved)
+	r.updateMetrics()
 	return nil
 }
 
-func (r *repoImpl) RemoveItem(itemID int64) error {
+func (r *repoImpl) UpdateItem(ctx context.Context, item models.Item, expectedVersion uint64) (version uint64, err error) {
+	ctx, span := r.startSpan(ctx, "UpdateItem", itemIDAttribute(item.ID))
+	defer func() { tracing.End(span, err) }()
+
+	version, evicted, err := r.updateItem(ctx, item, expectedVersion)
+	if errors.Is(err, ErrCapacityExceeded) {
+		metrics.RejectedItems.Add(r.name, 1)
+	}
+	r.reportEvicted(evicted)
+	return version, err
+}
+
+func (r *repoImpl) updateItem(ctx context.Context, item models.Item, expectedVersion uint64) (uint64, []models.Item, error) {
 	r.rwMx.Lock()
 	defer r.rwMx.Unlock()
 
-	r.storage.Remove(itemID)
+	return r.updateLocked(ctx, item, expectedVersion)
+}
+
+// updateLocked should be called under the write lock.
+func (r *repoImpl) updateLocked(ctx context.Context, item models.Item, expectedVersion uint64) (uint64, []models.Item, error) {
+	current, err := r.getLocked(item.ID)
+	if err != nil {
+		return 0, nil, err
+	}
+	if expectedVersion != 0 && current.Version != expectedVersion {
+		return 0, nil, fmt.Errorf("%w: expected version %d, current version %d", ErrVersionConflict, expectedVersion, current.Version)
+	}
+
+	if item.ExpiresAt.IsZero() {
+		item.ExpiresAt = current.ExpiresAt
+	}
+	evicted, err := r.addLocked(ctx, item)
+	if err != nil {
+		return 0, evicted, err
+	}
+	return r.versions[item.ID], evicted, nil
+}
+
+func (r *repoImpl) RemoveItemIfVersion(ctx context.Context, itemID int64, expectedVersion uint64) (err error) {
+	ctx, span := r.startSpan(ctx, "RemoveItemIfVersion", itemIDAttribute(itemID))
+	defer func() { tracing.End(span, err) }()
+
+	r.rwMx.Lock()
+	defer r.rwMx.Unlock()
+
+	if err := r.checkVersionLocked(itemID, expectedVersion); err != nil {
+		return err
+	}
+
+	r.deleteLocked(ctx, itemID, models.ChangeOp_ItemRemoved)
+	r.updateMetrics()
 	return nil
 }
 
-func (r *repoImpl) GetItem(itemID int64) (models.Item, error) {
+// checkVersionLocked should be called under the read or write lock.
+func (r *repoImpl) checkVersionLocked(itemID int64, expectedVersion uint64) error {
+	current, err := r.getLocked(itemID)
+	if err != nil {
+		return err
+	}
+	if current.Version != expectedVersion {
+		return fmt.Errorf("%w: expected version %d, current version %d", ErrVersionConflict, expectedVersion, current.Version)
+	}
+	return nil
+}
+
+func (r *repoImpl) GetItem(ctx context.Context, itemID int64) (item models.Item, err error) {
+	ctx, span := r.startSpan(ctx, "GetItem", itemIDAttribute(itemID))
+	defer func() { tracing.End(span, err) }()
+
 	r.rwMx.RLock()
 	defer r.rwMx.RUnlock()
 
+	if !accessTracked(ctx) {
+		return r.peekLocked(itemID)
+	}
+	return r.getLocked(itemID)
+}
+
+// getLocked should be called under the read or write lock.
+func (r *repoImpl) getLocked(itemID int64) (models.Item, error) {
+	item, err := r.peekLocked(itemID)
+	if err == nil && r.lru != nil {
+		r.lru.touch(itemID)
+	}
+	return item, err
+}
+
+// peekLocked returns the item without changing its access order. It should be called under the read or write lock.
+func (r *repoImpl) peekLocked(itemID int64) (models.Item, error) {
 	value, ok := r.storage.Get(itemID)
 	if !ok {
-		return models.Item{}, nil
+		return models.Item{}, ErrItemNotFound
 	}
 
 	payload, ok := value.(string)
 	if !ok {
 		return models.Item{}, errors.New("item has not correct type")
 	}
-	return models.Item{
-		ID:      itemID,
-		Payload: payload,
-	}, nil
+
+	item := r.newItem(itemID, payload)
+	// expired items are hidden even if the reaper has not removed them yet
+	if item.Expired(r.now()) {
+		return models.Item{}, ErrItemNotFound
+	}
+	return item, nil
 }
 
-func (r *repoImpl) GetAllItems() ([]models.Item, error) {
+func (r *repoImpl) GetAllItems(ctx context.Context) (items []models.Item, err error) {
+	ctx, span := r.startSpan(ctx, "GetAllItems")
+	defer func() { tracing.End(span, err) }()
+
 	r.rwMx.RLock()
 	defer r.rwMx.RUnlock()
 
+	return r.allLocked(), nil
+}
+
+// allLocked should be called under the read or write lock.
+func (r *repoImpl) allLocked() []models.Item {
+	now := r.now()
 	var items []models.Item
 	r.storage.All(func(key, value any) bool {
-		items = append(items, models.Item{
-			ID:      key.(int64),
-			Payload: value.(string),
-		})
+		item := r.newItem(key.(int64), value.(string))
+		if !item.Expired(now) {
+			items = append(items, item)
+		}
 		return true
 	})
 
+	return items
+}
+
+func (r *repoImpl) Count(ctx context.Context) (count int, err error) {
+	_, span := r.startSpan(ctx, "Count")
+	defer func() { tracing.End(span, err) }()
+
+	r.rwMx.RLock()
+	defer r.rwMx.RUnlock()
+
+	return r.storage.Size() - r.expiry.countExpired(r.now()), nil
+}
+
+func (r *repoImpl) RemoveExpiredItems(ctx context.Context) ([]models.Item, error) {
+	r.rwMx.Lock()
+	defer r.rwMx.Unlock()
+
+	items := r.removeExpiredLocked(ctx)
+	r.updateMetrics()
 	return items, nil
 }
+
+// removeExpiredLocked should be called under the write lock.
+func (r *repoImpl) removeExpiredLocked(ctx context.Context) []models.Item {
+	var items []models.Item
+	for _, itemID := range r.expiry.popExpired(r.now()) {
+		if item := r.deleteLocked(ctx, itemID, models.ChangeOp_ItemExpired); item.ID == itemID {
+			items = append(items, item)
+		}
+	}
+	metrics.ExpiredItems.Add(r.name, int64(len(items)))
+	return items
+}
+
+// deleteLocked removes the item and emits change event with the given operation if the item existed.
+// It should be called under the write lock.
+func (r *repoImpl) deleteLocked(ctx context.Context, itemID int64, op models.ChangeOp) models.Item {
+	item := r.removeLocked(itemID)
+	if item.ID == itemID && item.Version > 0 {
+		r.emit(ctx, &models.ChangeEvent{
+			Op:           op,
+			ItemID:       itemID,
+			OldPayload:   item.Payload,
+			Version:      item.Version,
+			ItemSequence: item.Sequence,
+		})
+	}
+	return item
+}
+
+// removeLocked deletes the item with all its metadata and returns it. It should be called under the write lock.
+func (r *repoImpl) removeLocked(itemID int64) models.Item {
+	version, sequence := r.versions[itemID], r.sequences[itemID]
+	if version > r.removedVersion {
+		r.removedVersion = version
+	}
+	delete(r.versions, itemID)
+	delete(r.sequences, itemID)
+	r.expiry.remove(itemID)
+	if r.lru != nil {
+		r.lru.remove(itemID)
+	}
+
+	value, ok := r.storage.Get(itemID)
+	if !ok {
+		return models.Item{}
+	}
+	r.storage.Remove(itemID)
+
+	payload, _ := value.(string)
+	r.bytes -= itemSize(payload)
+	return models.Item{
+		ID:       itemID,
+		Payload:  payload,
+		Version:  version,
+		Sequence: sequence,
+	}
+}
+
+// snapshot returns items in insertion order and the highest version of removed items.
+func (r *repoImpl) snapshot() ([]models.Item, uint64) {
+	r.rwMx.RLock()
+	defer r.rwMx.RUnlock()
+
+	return r.allLocked(), r.removedVersionLocked()
+}
+
+// removedVersionLocked returns the highest version of removed items including expired items which are not removed
+// yet, since snapshots do not keep them. It should be called under the read or write lock.
+func (r *repoImpl) removedVersionLocked() uint64 {
+	now := r.now()
+	version := r.removedVersion
+	r.storage.All(func(key, value any) bool {
+		if item := r.newItem(key.(int64), value.(string)); item.Expired(now) && item.Version > version {
+			version = item.Version
+		}
+		return true
+	})
+	return version
+}
+
+// restore adds items keeping their versions, versions of added items start after removedVersion. It should be called
+// before the repository is attached to the change log.
+func (r *repoImpl) restore(items []models.Item, removedVersion uint64) error {
+	r.rwMx.Lock()
+	defer r.rwMx.Unlock()
+
+	r.removedVersion = removedVersion
+	for _, item := range items {
+		if _, err := r.addLocked(context.Background(), item); err != nil {
+			return err
+		}
+		if item.Version > 0 {
+			r.versions[item.ID] = item.Version
+		}
+	}
+	return nil
+}
+
+// replicate applies the item change made by the primary as is: limits and default ttl are not applied,
+// the item gets the version of the event.
+func (r *repoImpl) replicate(ctx context.Context, event *models.ChangeEvent) error {
+	r.rwMx.Lock()
+	defer r.rwMx.Unlock()
+
+	switch event.Op {
+	case models.ChangeOp_ItemAdded, models.ChangeOp_ItemUpdated:
+		item := models.Item{ID: event.ItemID, Payload: event.NewPayload, Sequence: event.ItemSequence}
+		if event.ExpiresAt != 0 {
+			item.ExpiresAt = time.Unix(0, event.ExpiresAt)
+		}
+		r.putLocked(item)
+		r.versions[item.ID] = event.Version
+		r.emit(ctx, &models.ChangeEvent{
+			Op:           event.Op,
+			ItemID:       event.ItemID,
+			OldPayload:   event.OldPayload,
+			NewPayload:   event.NewPayload,
+			Version:      event.Version,
+			ExpiresAt:    event.ExpiresAt,
+			ItemSequence: r.sequences[event.ItemID],
+		})
+	case models.ChangeOp_ItemRemoved, models.ChangeOp_ItemExpired, models.ChangeOp_ItemEvicted:
+		r.deleteLocked(ctx, event.ItemID, event.Op)
+	default:
+		return fmt.Errorf("%s is not an item change", event.Op)
+	}
+	r.updateMetrics()
+	return nil
+}
+
+// emit should be called under the write lock.
+func (r *repoImpl) emit(ctx context.Context, event *models.ChangeEvent) {
+	if r.changes == nil {
+		return
+	}
+	event.Collection = r.name
+	r.changes.append(ctx, event)
+}
+
+func (r *repoImpl) reportEvicted(evicted []models.Item) {
+	for _, item := range evicted {
+		metrics.EvictedItems.Add(r.name, 1)
+		log.WithFields(logrus.Fields{"Collection": r.name, "ItemID": item.ID}).
+			Infof("Item was evicted by %s policy.", r.limits.Policy)
+	}
+}
+
+// updateMetrics should be called under the write lock.
+func (r *repoImpl) updateMetrics() {
+	r.storedItems.Set(int64(r.storage.Size()))
+	r.storedBytes.Set(r.bytes)
+}
+
+func itemSize(payload string) int64 {
+	return int64(len(payload))
+}
+
+// newItem should be called under the lock.
+func (r *repoImpl) newItem(itemID int64, payload string) models.Item {
+	expiresAt, _ := r.expiry.get(itemID)
+	return models.Item{
+		ID:        itemID,
+		Payload:   payload,
+		ExpiresAt: expiresAt,
+		Version:   r.versions[itemID],
+		Sequence:  r.sequences[itemID],
+	}
+}
@@ -5,6 +5,7 @@
 package repository
 
 import (
+	context "context"
 	reflect "reflect"
 
 	models "github.com/dliakhov/bloxroutelabs/client-server-app/models"
@@ -35,59 +36,133 @@ func (m *MockRepo) EXPECT() *MockRepoMockRecorder {
 }
 
 // AddItem mocks base method.
-func (m *MockRepo) AddItem(item models.Item) error {
+func (m *MockRepo) AddItem(ctx context.Context, item models.Item) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddItem", item)
+	ret := m.ctrl.Call(m, "AddItem", ctx, item)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // AddItem indicates an expected call of AddItem.
-func (mr *MockRepoMockRecorder) AddItem(item interface{}) *gomock.Call {
+func (mr *MockRepoMockRecorder) AddItem(ctx, item interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddItem", reflect.TypeOf((*MockRepo)(nil).AddItem), item)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddItem", reflect.TypeOf((*MockRepo)(nil).AddItem), ctx, item)
+}
+
+// ApplyBatch mocks base method.
+func (m *MockRepo) ApplyBatch(ctx context.Context, ops []Operation) ([]OperationResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ApplyBatch", ctx, ops)
+	ret0, _ := ret[0].([]OperationResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ApplyBatch indicates an expected call of ApplyBatch.
+func (mr *MockRepoMockRecorder) ApplyBatch(ctx, ops interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ApplyBatch", reflect.TypeOf((*MockRepo)(nil).ApplyBatch), ctx, ops)
+}
+
+// Count mocks base method.
+func (m *MockRepo) Count(ctx context.Context) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Count", ctx)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Count indicates an expected call of Count.
+func (mr *MockRepoMockRecorder) Count(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Count", reflect.TypeOf((*MockRepo)(nil).Count), ctx)
 }
 
 // GetAllItems mocks base method.
-func (m *MockRepo) GetAllItems() ([]models.Item, error) {
+func (m *MockRepo) GetAllItems(ctx context.Context) ([]models.Item, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAllItems")
+	ret := m.ctrl.Call(m, "GetAllItems", ctx)
 	ret0, _ := ret[0].([]models.Item)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetAllItems indicates an expected call of GetAllItems.
-func (mr *MockRepoMockRecorder) GetAllItems() *gomock.Call {
+func (mr *MockRepoMockRecorder) GetAllItems(ctx interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllItems", reflect.TypeOf((*MockRepo)(nil).GetAllItems))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllItems", reflect.TypeOf((*MockRepo)(nil).GetAllItems), ctx)
 }
 
 // GetItem mocks base method.
-func (m *MockRepo) GetItem(itemID int64) (models.Item, error) {
+func (m *MockRepo) GetItem(ctx context.Context, itemID int64) (models.Item, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetItem", itemID)
+	ret := m.ctrl.Call(m, "GetItem", ctx, itemID)
 	ret0, _ := ret[0].(models.Item)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetItem indicates an expected call of GetItem.
-func (mr *MockRepoMockRecorder) GetItem(itemID interface{}) *gomock.Call {
+func (mr *MockRepoMockRecorder) GetItem(ctx, itemID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetItem", reflect.TypeOf((*MockRepo)(nil).GetItem), itemID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetItem", reflect.TypeOf((*MockRepo)(nil).GetItem), ctx, itemID)
+}
+
+// RemoveExpiredItems mocks base method.
+func (m *MockRepo) RemoveExpiredItems(ctx context.Context) ([]models.Item, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveExpiredItems", ctx)
+	ret0, _ := ret[0].([]models.Item)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RemoveExpiredItems indicates an expected call of RemoveExpiredItems.
+func (mr *MockRepoMockRecorder) RemoveExpiredItems(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveExpiredItems", reflect.TypeOf((*MockRepo)(nil).RemoveExpiredItems), ctx)
 }
 
 // RemoveItem mocks base method.
-func (m *MockRepo) RemoveItem(itemID int64) error {
+func (m *MockRepo) RemoveItem(ctx context.Context, itemID int64) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "RemoveItem", itemID)
+	ret := m.ctrl.Call(m, "RemoveItem", ctx, itemID)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // RemoveItem indicates an expected call of RemoveItem.
-func (mr *MockRepoMockRecorder) RemoveItem(itemID interface{}) *gomock.Call {
+func (mr *MockRepoMockRecorder) RemoveItem(ctx, itemID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveItem", reflect.TypeOf((*MockRepo)(nil).RemoveItem), ctx, itemID)
+}
+
+// RemoveItemIfVersion mocks base method.
+func (m *MockRepo) RemoveItemIfVersion(ctx context.Context, itemID int64, expectedVersion uint64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveItemIfVersion", ctx, itemID, expectedVersion)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveItemIfVersion indicates an expected call of RemoveItemIfVersion.
+func (mr *MockRepoMockRecorder) RemoveItemIfVersion(ctx, itemID, expectedVersion interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveItemIfVersion", reflect.TypeOf((*MockRepo)(nil).RemoveItemIfVersion), ctx, itemID, expectedVersion)
+}
+
+// UpdateItem mocks base method.
+func (m *MockRepo) UpdateItem(ctx context.Context, item models.Item, expectedVersion uint64) (uint64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateItem", ctx, item, expectedVersion)
+	ret0, _ := ret[0].(uint64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateItem indicates an expected call of UpdateItem.
+func (mr *MockRepoMockRecorder) UpdateItem(ctx, item, expectedVersion interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveItem", reflect.TypeOf((*MockRepo)(nil).RemoveItem), itemID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateItem", reflect.TypeOf((*MockRepo)(nil).UpdateItem), ctx, item, expectedVersion)
 }
@@ -1,9 +1,11 @@
 package repository
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
 	"github.com/stretchr/testify/assert"
@@ -21,7 +23,7 @@ func Test_repoImpl_AddItem(t *testing.T) {
 		{
 			name: "should add one item",
 			args: args{manipulateWithRepo: func(r Repo) {
-				r.AddItem(models.Item{
+				r.AddItem(context.Background(), models.Item{
 					ID:      1,
 					Payload: "A",
 				})
@@ -34,11 +36,11 @@ func Test_repoImpl_AddItem(t *testing.T) {
 		{
 			name: "should add two items",
 			args: args{manipulateWithRepo: func(r Repo) {
-				r.AddItem(models.Item{
+				r.AddItem(context.Background(), models.Item{
 					ID:      1,
 					Payload: "A",
 				})
-				r.AddItem(models.Item{
+				r.AddItem(context.Background(), models.Item{
 					ID:      2,
 					Payload: "B",
 				})
@@ -58,7 +60,7 @@ func Test_repoImpl_AddItem(t *testing.T) {
 				for i := 0; i < 5; i++ {
 					wg.Add(1)
 					go func(i int) {
-						r.AddItem(models.Item{
+						r.AddItem(context.Background(), models.Item{
 							ID:      int64(i),
 							Payload: fmt.Sprintf("%c", 'A'+i),
 						})
@@ -92,7 +94,7 @@ func Test_repoImpl_AddItem(t *testing.T) {
 				for i := 0; i < 5; i++ {
 					wg.Add(1)
 					go func(i int) {
-						r.AddItem(models.Item{
+						r.AddItem(context.Background(), models.Item{
 							ID:      int64(i),
 							Payload: fmt.Sprintf("%c", 'A'+i),
 						})
@@ -102,8 +104,8 @@ func Test_repoImpl_AddItem(t *testing.T) {
 
 				wg.Wait()
 
-				r.RemoveItem(0)
-				r.RemoveItem(1)
+				r.RemoveItem(context.Background(), 0)
+				r.RemoveItem(context.Background(), 1)
 			}},
 			wantItems: []models.Item{{
 				ID:      2,
@@ -129,6 +131,18 @@ func Test_repoImpl_AddItem(t *testing.T) {
 	}
 }
 
+// withoutSequences checks that items have insertion sequences in increasing order and clears them,
+// so items can be compared regardless of the time they were added.
+func withoutSequences(t *testing.T, items []models.Item) []models.Item {
+	var last uint64
+	for i := range items {
+		assert.Greater(t, items[i].Sequence, last, "item %d is out of insertion order", items[i].ID)
+		last = items[i].Sequence
+		items[i].Sequence = 0
+	}
+	return items
+}
+
 func getAllItems(r *repoImpl) []models.Item {
 	var items []models.Item
 	r.storage.All(func(key, value any) bool {
@@ -189,7 +203,7 @@ func Test_repoImpl_GetItem(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := r.GetItem(tt.args.itemID)
+			got, err := r.GetItem(context.Background(), tt.args.itemID)
 			if !tt.wantErr(t, err, fmt.Sprintf("GetItem(%v)", tt.args.itemID)) {
 				return
 			}
@@ -257,7 +271,7 @@ func Test_repoImpl_GetAllItems(t *testing.T) {
 			r := New().(*repoImpl)
 			tt.initRepo(r)
 
-			got, err := r.GetAllItems()
+			got, err := r.GetAllItems(context.Background())
 			if !tt.wantErr(t, err, fmt.Sprintf("GetAllItems()")) {
 				return
 			}
@@ -265,3 +279,229 @@ func Test_repoImpl_GetAllItems(t *testing.T) {
 		})
 	}
 }
+
+func Test_repoImpl_ExpiredItemsAreHidden(t *testing.T) {
+	now := time.Now()
+	r := New().(*repoImpl)
+	r.now = func() time.Time { return now }
+
+	r.AddItem(context.Background(), models.Item{ID: 1, Payload: "A", ExpiresAt: now.Add(-time.Second)})
+	r.AddItem(context.Background(), models.Item{ID: 2, Payload: "B", ExpiresAt: now.Add(time.Minute)})
+	r.AddItem(context.Background(), models.Item{ID: 3, Payload: "C"})
+
+	_, err := r.GetItem(context.Background(), 1)
+	assert.ErrorIs(t, err, ErrItemNotFound)
+
+	got, err := r.GetItem(context.Background(), 2)
+	assert.NoError(t, err)
+	seq := uint64(now.UnixNano())
+	assert.Equal(t, models.Item{ID: 2, Payload: "B", ExpiresAt: now.Add(time.Minute), Version: 1, Sequence: seq + 1}, got)
+
+	items, err := r.GetAllItems(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Item{
+		{ID: 2, Payload: "B", ExpiresAt: now.Add(time.Minute), Version: 1, Sequence: seq + 1},
+		{ID: 3, Payload: "C", Version: 1, Sequence: seq + 2},
+	}, items)
+
+	count, err := r.Count(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
+
+func Test_repoImpl_RemoveExpiredItems(t *testing.T) {
+	now := time.Now()
+	seq := uint64(now.UnixNano())
+	r := New().(*repoImpl)
+	r.now = func() time.Time { return now }
+
+	r.AddItem(context.Background(), models.Item{ID: 1, Payload: "A", ExpiresAt: now.Add(3 * time.Second)})
+	r.AddItem(context.Background(), models.Item{ID: 2, Payload: "B", ExpiresAt: now.Add(time.Second)})
+	r.AddItem(context.Background(), models.Item{ID: 3, Payload: "C"})
+	r.AddItem(context.Background(), models.Item{ID: 4, Payload: "D", ExpiresAt: now.Add(2 * time.Second)})
+	// item added again without ttl should not expire anymore
+	r.AddItem(context.Background(), models.Item{ID: 4, Payload: "D"})
+	r.AddItem(context.Background(), models.Item{ID: 5, Payload: "E", ExpiresAt: now.Add(time.Second)})
+	r.RemoveItem(context.Background(), 5)
+
+	items, err := r.RemoveExpiredItems(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, items)
+
+	now = now.Add(2 * time.Second)
+	items, err = r.RemoveExpiredItems(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Item{{ID: 2, Payload: "B", Version: 1, Sequence: seq + 1}}, items)
+
+	now = now.Add(time.Hour)
+	items, err = r.RemoveExpiredItems(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Item{{ID: 1, Payload: "A", Version: 1, Sequence: seq}}, items)
+
+	assert.Equal(t, []models.Item{{ID: 3, Payload: "C"}, {ID: 4, Payload: "D"}}, getAllItems(r))
+	assert.Zero(t, r.expiry.Len())
+}
+
+func Test_repoImpl_AddItemWithLimits(t *testing.T) {
+	tests := []struct {
+		name      string
+		limits    Limits
+		fillRepo  func(r Repo)
+		item      models.Item
+		wantErr   error
+		wantItems []models.Item
+	}{
+		{
+			name:   "should reject item when max items is reached",
+			limits: Limits{MaxItems: 2, Policy: EvictionPolicyReject},
+			fillRepo: func(r Repo) {
+				r.AddItem(context.Background(), models.Item{ID: 1, Payload: "A"})
+				r.AddItem(context.Background(), models.Item{ID: 2, Payload: "B"})
+			},
+			item:      models.Item{ID: 3, Payload: "C"},
+			wantErr:   ErrCapacityExceeded,
+			wantItems: []models.Item{{ID: 1, Payload: "A"}, {ID: 2, Payload: "B"}},
+		},
+		{
+			name:   "should update existing item when max items is reached",
+			limits: Limits{MaxItems: 2, Policy: EvictionPolicyReject},
+			fillRepo: func(r Repo) {
+				r.AddItem(context.Background(), models.Item{ID: 1, Payload: "A"})
+				r.AddItem(context.Background(), models.Item{ID: 2, Payload: "B"})
+			},
+			item:      models.Item{ID: 1, Payload: "C"},
+			wantItems: []models.Item{{ID: 1, Payload: "C"}, {ID: 2, Payload: "B"}},
+		},
+		{
+			name:   "should reject item which is bigger than max bytes",
+			limits: Limits{MaxBytes: 2, Policy: EvictionPolicyFIFO},
+			fillRepo: func(r Repo) {
+				r.AddItem(context.Background(), models.Item{ID: 1, Payload: "A"})
+			},
+			item:      models.Item{ID: 2, Payload: "BBB"},
+			wantErr:   ErrCapacityExceeded,
+			wantItems: []models.Item{{ID: 1, Payload: "A"}},
+		},
+		{
+			name:   "should evict oldest items by fifo policy",
+			limits: Limits{MaxItems: 3, Policy: EvictionPolicyFIFO},
+			fillRepo: func(r Repo) {
+				r.AddItem(context.Background(), models.Item{ID: 1, Payload: "A"})
+				r.AddItem(context.Background(), models.Item{ID: 2, Payload: "B"})
+				r.AddItem(context.Background(), models.Item{ID: 3, Payload: "C"})
+				r.GetItem(context.Background(), 1)
+			},
+			item:      models.Item{ID: 4, Payload: "D"},
+			wantItems: []models.Item{{ID: 2, Payload: "B"}, {ID: 3, Payload: "C"}, {ID: 4, Payload: "D"}},
+		},
+		{
+			name:   "should evict least recently accessed items by lru policy",
+			limits: Limits{MaxItems: 3, Policy: EvictionPolicyLRU},
+			fillRepo: func(r Repo) {
+				r.AddItem(context.Background(), models.Item{ID: 1, Payload: "A"})
+				r.AddItem(context.Background(), models.Item{ID: 2, Payload: "B"})
+				r.AddItem(context.Background(), models.Item{ID: 3, Payload: "C"})
+				r.GetItem(context.Background(), 1)
+			},
+			item:      models.Item{ID: 4, Payload: "D"},
+			wantItems: []models.Item{{ID: 1, Payload: "A"}, {ID: 3, Payload: "C"}, {ID: 4, Payload: "D"}},
+		},
+		{
+			name:   "should not count reads without access by lru policy",
+			limits: Limits{MaxItems: 3, Policy: EvictionPolicyLRU},
+			fillRepo: func(r Repo) {
+				r.AddItem(context.Background(), models.Item{ID: 1, Payload: "A"})
+				r.AddItem(context.Background(), models.Item{ID: 2, Payload: "B"})
+				r.AddItem(context.Background(), models.Item{ID: 3, Payload: "C"})
+				r.GetItem(WithoutAccess(context.Background()), 1)
+			},
+			item:      models.Item{ID: 4, Payload: "D"},
+			wantItems: []models.Item{{ID: 2, Payload: "B"}, {ID: 3, Payload: "C"}, {ID: 4, Payload: "D"}},
+		},
+		{
+			name:   "should evict several items to fit max bytes",
+			limits: Limits{MaxBytes: 4, Policy: EvictionPolicyFIFO},
+			fillRepo: func(r Repo) {
+				r.AddItem(context.Background(), models.Item{ID: 1, Payload: "A"})
+				r.AddItem(context.Background(), models.Item{ID: 2, Payload: "B"})
+				r.AddItem(context.Background(), models.Item{ID: 3, Payload: "CC"})
+			},
+			item:      models.Item{ID: 4, Payload: "DD"},
+			wantItems: []models.Item{{ID: 3, Payload: "CC"}, {ID: 4, Payload: "DD"}},
+		},
+		{
+			name:   "should remove expired items before evicting",
+			limits: Limits{MaxItems: 2, Policy: EvictionPolicyFIFO},
+			fillRepo: func(r Repo) {
+				r.AddItem(context.Background(), models.Item{ID: 1, Payload: "A"})
+				r.AddItem(context.Background(), models.Item{ID: 2, Payload: "B", ExpiresAt: time.Now().Add(-time.Second)})
+			},
+			item:      models.Item{ID: 3, Payload: "C"},
+			wantItems: []models.Item{{ID: 1, Payload: "A"}, {ID: 3, Payload: "C"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := New(WithLimits(tt.limits)).(*repoImpl)
+			tt.fillRepo(r)
+
+			err := r.AddItem(context.Background(), tt.item)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.wantItems, getAllItems(r))
+
+			var wantBytes int64
+			for _, item := range tt.wantItems {
+				wantBytes += itemSize(item.Payload)
+			}
+			assert.Equal(t, wantBytes, r.bytes)
+		})
+	}
+}
+
+func Test_repoImpl_Versions(t *testing.T) {
+	r := New().(*repoImpl)
+
+	r.AddItem(context.Background(), models.Item{ID: 1, Payload: "A"})
+	r.AddItem(context.Background(), models.Item{ID: 2, Payload: "B", ExpiresAt: time.Now().Add(time.Hour)})
+	item, err := r.GetItem(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), item.Version)
+
+	// adding the same item again changes it too
+	r.AddItem(context.Background(), models.Item{ID: 1, Payload: "AA"})
+	item, err = r.GetItem(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.NotZero(t, item.Sequence)
+	item.Sequence = 0
+	assert.Equal(t, models.Item{ID: 1, Payload: "AA", Version: 2}, item)
+
+	version, err := r.UpdateItem(context.Background(), models.Item{ID: 1, Payload: "AAA"}, 1)
+	assert.ErrorIs(t, err, ErrVersionConflict)
+	assert.Zero(t, version)
+
+	version, err = r.UpdateItem(context.Background(), models.Item{ID: 1, Payload: "AAA"}, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), version)
+
+	version, err = r.UpdateItem(context.Background(), models.Item{ID: 2, Payload: "BB"}, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), version)
+	item, err = r.GetItem(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.False(t, item.ExpiresAt.IsZero(), "updated item should keep expiration time")
+
+	_, err = r.UpdateItem(context.Background(), models.Item{ID: 3, Payload: "C"}, 0)
+	assert.ErrorIs(t, err, ErrItemNotFound)
+
+	assert.ErrorIs(t, r.RemoveItemIfVersion(context.Background(), 1, 2), ErrVersionConflict)
+	assert.ErrorIs(t, r.RemoveItemIfVersion(context.Background(), 3, 1), ErrItemNotFound)
+	assert.NoError(t, r.RemoveItemIfVersion(context.Background(), 1, 3))
+
+	// removed item added again does not repeat its versions, so stale conditions fail
+	r.AddItem(context.Background(), models.Item{ID: 1, Payload: "A"})
+	assert.Equal(t, []models.Item{{ID: 2, Payload: "BB"}, {ID: 1, Payload: "A"}}, getAllItems(r))
+	item, err = r.GetItem(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(4), item.Version)
+	assert.ErrorIs(t, r.RemoveItemIfVersion(context.Background(), 1, 1), ErrVersionConflict)
+}
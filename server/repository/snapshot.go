@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
+)
+
+const snapshotExt = ".json"
+
+// SnapshotItem is the item in JSON snapshots of collections.
+type SnapshotItem struct {
+	ID        int64      `json:"id"`
+	Payload   string     `json:"payload"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Version   uint64     `json:"version"`
+	Sequence  uint64     `json:"sequence,omitempty"`
+}
+
+// collectionFile is the JSON snapshot of the collection.
+type collectionFile struct {
+	// RemovedVersion is the highest version of removed items.
+	RemovedVersion uint64         `json:"removed_version,omitempty"`
+	Items          []SnapshotItem `json:"items"`
+}
+
+// snapshotStore keeps every collection in a separate JSON file with items in insertion order.
+type snapshotStore struct {
+	dir string
+}
+
+func newSnapshotStore(dir string) *snapshotStore {
+	return &snapshotStore{dir: dir}
+}
+
+// list returns names of all saved collections.
+func (s *snapshotStore) list() ([]string, error) {
+	if s.dir == "" {
+		return nil, nil
+	}
+
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), snapshotExt) {
+			names = append(names, strings.TrimSuffix(entry.Name(), snapshotExt))
+		}
+	}
+	return names, nil
+}
+
+// load returns items of the collection which are not expired at now and the highest version of its removed items.
+func (s *snapshotStore) load(name string, now time.Time) ([]models.Item, uint64, error) {
+	if s.dir == "" {
+		return nil, 0, nil
+	}
+
+	data, err := os.ReadFile(s.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var snapshot collectionFile
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, 0, err
+	}
+	return fromSnapshotItems(snapshot.Items, now), snapshot.RemovedVersion, nil
+}
+
+func (s *snapshotStore) save(name string, items []models.Item, removedVersion uint64) error {
+	if s.dir == "" {
+		return errors.New("data directory is not configured")
+	}
+
+	data, err := json.Marshal(collectionFile{RemovedVersion: removedVersion, Items: toSnapshotItems(items)})
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+
+	// write to temporary file first, so the previous snapshot is not lost if writing fails
+	tmpPath := s.path(name) + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, s.path(name))
+}
+
+func (s *snapshotStore) remove(name string) error {
+	if s.dir == "" {
+		return nil
+	}
+
+	err := os.Remove(s.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *snapshotStore) path(name string) string {
+	return filepath.Join(s.dir, name+snapshotExt)
+}
+
+// NewSnapshotItem converts the item to its JSON representation.
+func NewSnapshotItem(item models.Item) SnapshotItem {
+	snapshotItem := SnapshotItem{
+		ID:       item.ID,
+		Payload:  item.Payload,
+		Version:  item.Version,
+		Sequence: item.Sequence,
+	}
+	if !item.ExpiresAt.IsZero() {
+		expiresAt := item.ExpiresAt
+		snapshotItem.ExpiresAt = &expiresAt
+	}
+	return snapshotItem
+}
+
+func toSnapshotItems(items []models.Item) []SnapshotItem {
+	snapshot := make([]SnapshotItem, 0, len(items))
+	for _, item := range items {
+		snapshot = append(snapshot, NewSnapshotItem(item))
+	}
+	return snapshot
+}
+
+// fromSnapshotItems returns items which are not expired at now.
+func fromSnapshotItems(snapshot []SnapshotItem, now time.Time) []models.Item {
+	items := make([]models.Item, 0, len(snapshot))
+	for _, snapshotItem := range snapshot {
+		item := models.Item{
+			ID:       snapshotItem.ID,
+			Payload:  snapshotItem.Payload,
+			Version:  snapshotItem.Version,
+			Sequence: snapshotItem.Sequence,
+		}
+		if snapshotItem.ExpiresAt != nil {
+			item.ExpiresAt = *snapshotItem.ExpiresAt
+		}
+		if !item.Expired(now) {
+			items = append(items, item)
+		}
+	}
+	return items
+}
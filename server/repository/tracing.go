@@ -0,0 +1,21 @@
+package repository
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/dliakhov/bloxroutelabs/client-server-app/server/repository")
+
+// startSpan starts the span of the operation on the collection.
+func (r *repoImpl) startSpan(ctx context.Context, operation string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, attribute.String("collection", r.name))
+	return tracer.Start(ctx, "repository."+operation, trace.WithAttributes(attrs...))
+}
+
+func itemIDAttribute(itemID int64) attribute.KeyValue {
+	return attribute.Int64("item.id", itemID)
+}
@@ -0,0 +1,45 @@
+package scheduler
+
+// timerQueue is a min-heap of scheduled commands by NotBefore, commands with the same moment are kept in the order
+// they are scheduled. It implements heap.Interface.
+type timerQueue []*entry
+
+type entry struct {
+	ScheduledCommand
+	// seq is the order the command is scheduled in
+	seq   uint64
+	index int
+}
+
+func (q timerQueue) Len() int {
+	return len(q)
+}
+
+func (q timerQueue) Less(i, j int) bool {
+	if q[i].NotBefore.Equal(q[j].NotBefore) {
+		return q[i].seq < q[j].seq
+	}
+	return q[i].NotBefore.Before(q[j].NotBefore)
+}
+
+func (q timerQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *timerQueue) Push(x interface{}) {
+	e := x.(*entry)
+	e.index = len(*q)
+	*q = append(*q, e)
+}
+
+func (q *timerQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	e.index = -1
+	*q = old[:n-1]
+	return e
+}
@@ -0,0 +1,367 @@
+package scheduler
+
+import (
+	"container/heap"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/logging"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/service"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+var log = logging.Logger("server/scheduler")
+
+const traceIDKey = "X-Trace-ID"
+
+var (
+	ErrNotFound = errors.New("scheduled command not found")
+	// ErrNotSchedulable is returned for commands which do not change the storage, e.g. GetItem.
+	ErrNotSchedulable = errors.New("command cannot be scheduled")
+)
+
+// ScheduledCommand is a command which is held until NotBefore.
+type ScheduledCommand struct {
+	ID          string
+	Command     *models.Command
+	NotBefore   time.Time
+	TraceID     string
+	ScheduledAt time.Time
+}
+
+type scheduledCommandJSON struct {
+	ID          string          `json:"id"`
+	Command     json.RawMessage `json:"command"`
+	NotBefore   time.Time       `json:"not_before"`
+	TraceID     string          `json:"trace_id,omitempty"`
+	ScheduledAt time.Time       `json:"scheduled_at"`
+}
+
+func (c ScheduledCommand) MarshalJSON() ([]byte, error) {
+	command, err := protojson.Marshal(c.Command)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(scheduledCommandJSON{
+		ID:          c.ID,
+		Command:     command,
+		NotBefore:   c.NotBefore,
+		TraceID:     c.TraceID,
+		ScheduledAt: c.ScheduledAt,
+	})
+}
+
+func (c *ScheduledCommand) UnmarshalJSON(data []byte) error {
+	var value scheduledCommandJSON
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	command := new(models.Command)
+	if err := protojson.Unmarshal(value.Command, command); err != nil {
+		return err
+	}
+	*c = ScheduledCommand{
+		ID:          value.ID,
+		Command:     command,
+		NotBefore:   value.NotBefore,
+		TraceID:     value.TraceID,
+		ScheduledAt: value.ScheduledAt,
+	}
+	return nil
+}
+
+// Scheduler holds delayed commands in a timer queue and processes them by the item service when they are due.
+// Pending commands are saved to the file on every change, so they survive restarts. Commands which are being
+// processed are saved too, so a command interrupted by a crash is processed again on the next start.
+type Scheduler struct {
+	itemService service.ItemService
+	file        string
+	now         func() time.Time
+
+	mu         sync.Mutex
+	queue      timerQueue
+	byID       map[string]*entry
+	processing []ScheduledCommand
+	seq        uint64
+
+	wakeup chan struct{}
+	quit   chan struct{}
+	done   chan struct{}
+}
+
+type Option func(s *Scheduler)
+
+// WithClock replaces time.Now, the timer still waits for the real time.
+func WithClock(now func() time.Time) Option {
+	return func(s *Scheduler) {
+		s.now = now
+	}
+}
+
+// New creates the scheduler and loads pending commands from the file. Empty file means commands are kept in
+// memory only.
+func New(itemService service.ItemService, file string, opts ...Option) (*Scheduler, error) {
+	s := &Scheduler{
+		itemService: itemService,
+		file:        file,
+		now:         time.Now,
+		byID:        make(map[string]*entry),
+		wakeup:      make(chan struct{}, 1),
+		quit:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if err := s.load(); err != nil {
+		return nil, fmt.Errorf("cannot load scheduled commands: %w", err)
+	}
+	return s, nil
+}
+
+// Schedule holds the command until its NotBefore. The command is saved before Schedule returns.
+func (s *Scheduler) Schedule(ctx context.Context, command *models.Command) (ScheduledCommand, error) {
+	if !schedulable(command.Type) {
+		return ScheduledCommand{}, fmt.Errorf("%w: %s", ErrNotSchedulable, command.Type)
+	}
+
+	traceID, _ := ctx.Value(traceIDKey).(string)
+	scheduled := ScheduledCommand{
+		ID:          uuid.NewString(),
+		Command:     proto.Clone(command).(*models.Command),
+		NotBefore:   time.Unix(0, command.NotBefore),
+		TraceID:     traceID,
+		ScheduledAt: s.now(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := s.push(scheduled)
+	if err := s.save(); err != nil {
+		heap.Remove(&s.queue, e.index)
+		delete(s.byID, e.ID)
+		return ScheduledCommand{}, fmt.Errorf("cannot save scheduled command: %w", err)
+	}
+	s.notify()
+
+	log.WithField(traceIDKey, traceID).Infof("Command %s is scheduled at %s with id %s",
+		command.Type, scheduled.NotBefore.Format(time.RFC3339Nano), scheduled.ID)
+	return scheduled, nil
+}
+
+// List returns pending commands in the order they will be processed.
+func (s *Scheduler) List() []ScheduledCommand {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.pending()
+}
+
+// Cancel removes the pending command, commands which are already being processed cannot be cancelled.
+func (s *Scheduler) Cancel(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.byID[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	heap.Remove(&s.queue, e.index)
+	delete(s.byID, id)
+	if err := s.save(); err != nil {
+		s.push(e.ScheduledCommand)
+		return fmt.Errorf("cannot save scheduled commands: %w", err)
+	}
+	s.notify()
+
+	log.WithField(traceIDKey, e.TraceID).Infof("Scheduled command %s is cancelled", id)
+	return nil
+}
+
+func (s *Scheduler) Start() {
+	go func() {
+		defer close(s.done)
+
+		for {
+			s.processDue()
+
+			var timer *time.Timer
+			var fired <-chan time.Time
+			if next, ok := s.next(); ok {
+				timer = time.NewTimer(next.Sub(s.now()))
+				fired = timer.C
+			}
+
+			select {
+			case <-fired:
+			case <-s.wakeup:
+			case <-s.quit:
+				if timer != nil {
+					timer.Stop()
+				}
+				return
+			}
+			if timer != nil {
+				timer.Stop()
+			}
+		}
+	}()
+}
+
+// Quit stops the scheduler and waits until due commands which are being processed are finished.
+func (s *Scheduler) Quit() {
+	close(s.quit)
+	<-s.done
+}
+
+// processDue processes all commands which are due in the order of their NotBefore.
+func (s *Scheduler) processDue() {
+	s.mu.Lock()
+	now := s.now()
+	for len(s.queue) > 0 && !s.queue[0].NotBefore.After(now) {
+		e := heap.Pop(&s.queue).(*entry)
+		delete(s.byID, e.ID)
+		s.processing = append(s.processing, e.ScheduledCommand)
+	}
+	due := s.processing
+	s.mu.Unlock()
+
+	if len(due) == 0 {
+		return
+	}
+
+	for _, scheduled := range due {
+		ctx := context.WithValue(context.Background(), traceIDKey, scheduled.TraceID)
+		command := proto.Clone(scheduled.Command).(*models.Command)
+		command.NotBefore = 0
+
+		if err := s.itemService.ProcessItemCommand(ctx, command); err != nil {
+			log.WithField(traceIDKey, scheduled.TraceID).Errorf("Cannot process scheduled command %s: %v", scheduled.ID, err)
+			continue
+		}
+		log.WithField(traceIDKey, scheduled.TraceID).Infof("Scheduled command %s is processed", scheduled.ID)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.processing = nil
+	if err := s.save(); err != nil {
+		log.Errorf("Cannot save scheduled commands: %v", err)
+	}
+}
+
+// next returns the moment of the first pending command.
+func (s *Scheduler) next() (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.queue) == 0 {
+		return time.Time{}, false
+	}
+	return s.queue[0].NotBefore, true
+}
+
+// notify wakes up the timer loop to recalculate the next moment.
+func (s *Scheduler) notify() {
+	select {
+	case s.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Scheduler) push(scheduled ScheduledCommand) *entry {
+	s.seq++
+	e := &entry{ScheduledCommand: scheduled, seq: s.seq}
+	heap.Push(&s.queue, e)
+	s.byID[e.ID] = e
+	return e
+}
+
+func (s *Scheduler) pending() []ScheduledCommand {
+	entries := make(timerQueue, len(s.queue))
+	copy(entries, s.queue)
+	sort.Slice(entries, func(i, j int) bool {
+		return timerQueue.Less(entries, i, j)
+	})
+
+	commands := make([]ScheduledCommand, 0, len(entries))
+	for _, e := range entries {
+		commands = append(commands, e.ScheduledCommand)
+	}
+	return commands
+}
+
+func (s *Scheduler) load() error {
+	if s.file == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var commands []ScheduledCommand
+	if err := json.Unmarshal(data, &commands); err != nil {
+		return err
+	}
+	for _, command := range commands {
+		s.push(command)
+	}
+	if len(commands) > 0 {
+		log.Infof("Loaded %d scheduled commands", len(commands))
+	}
+	return nil
+}
+
+// save writes commands being processed and pending ones to the file.
+func (s *Scheduler) save() error {
+	if s.file == "" {
+		return nil
+	}
+
+	commands := append(append([]ScheduledCommand{}, s.processing...), s.pending()...)
+	data, err := json.Marshal(commands)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.file), 0o755); err != nil {
+		return err
+	}
+
+	// write to temporary file first, so the previous state is not lost if writing fails
+	tmpPath := s.file + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, s.file)
+}
+
+func schedulable(commandType models.CommandType) bool {
+	switch commandType {
+	case models.CommandType_AddItem, models.CommandType_UpdateItem, models.CommandType_RemoveItem,
+		models.CommandType_CreateCollection, models.CommandType_DropCollection:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,191 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/server/service"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+// protoEq matches protobuf messages which are equal to the given one.
+type protoEq struct {
+	msg proto.Message
+}
+
+func (p protoEq) Matches(x interface{}) bool {
+	msg, ok := x.(proto.Message)
+	return ok && proto.Equal(p.msg, msg)
+}
+
+func (p protoEq) String() string {
+	return fmt.Sprintf("is equal to %v", p.msg)
+}
+
+// clock is a manually advanced time.
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func delayedCommand(commandType models.CommandType, itemID int64, notBefore time.Time) *models.Command {
+	return &models.Command{Type: commandType, ItemID: itemID, ItemPayload: "A", NotBefore: notBefore.UnixNano()}
+}
+
+func ids(commands []ScheduledCommand) []int64 {
+	var itemIDs []int64
+	for _, command := range commands {
+		itemIDs = append(itemIDs, command.Command.ItemID)
+	}
+	return itemIDs
+}
+
+func TestScheduler_ProcessDue(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	c := &clock{now: time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)}
+	itemService := service.NewMockItemService(ctrl)
+	s, err := New(itemService, "", WithClock(c.Now))
+	require.NoError(t, err)
+
+	ctx := context.WithValue(context.Background(), traceIDKey, "trace-1")
+	_, err = s.Schedule(ctx, delayedCommand(models.CommandType_RemoveItem, 3, c.now.Add(2*time.Minute)))
+	require.NoError(t, err)
+	_, err = s.Schedule(ctx, delayedCommand(models.CommandType_AddItem, 1, c.now.Add(time.Minute)))
+	require.NoError(t, err)
+	_, err = s.Schedule(ctx, delayedCommand(models.CommandType_AddItem, 2, c.now.Add(time.Minute)))
+	require.NoError(t, err)
+
+	pending := s.List()
+	assert.Equal(t, []int64{1, 2, 3}, ids(pending))
+	assert.Equal(t, "trace-1", pending[0].TraceID)
+
+	// nothing is due yet
+	s.processDue()
+
+	c.Add(time.Minute)
+	gomock.InOrder(
+		itemService.EXPECT().ProcessItemCommand(gomock.Any(), protoEq{&models.Command{Type: models.CommandType_AddItem, ItemID: 1, ItemPayload: "A"}}).
+			DoAndReturn(func(ctx context.Context, _ *models.Command) error {
+				assert.Equal(t, "trace-1", ctx.Value(traceIDKey))
+				return nil
+			}),
+		itemService.EXPECT().ProcessItemCommand(gomock.Any(), protoEq{&models.Command{Type: models.CommandType_AddItem, ItemID: 2, ItemPayload: "A"}}).
+			Return(errors.New("capacity exceeded")),
+	)
+	s.processDue()
+	assert.Equal(t, []int64{3}, ids(s.List()))
+}
+
+func TestScheduler_Cancel(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	c := &clock{now: time.Now()}
+	s, err := New(service.NewMockItemService(ctrl), "", WithClock(c.Now))
+	require.NoError(t, err)
+
+	scheduled, err := s.Schedule(context.Background(), delayedCommand(models.CommandType_AddItem, 1, c.now.Add(time.Minute)))
+	require.NoError(t, err)
+
+	require.NoError(t, s.Cancel(scheduled.ID))
+	assert.Empty(t, s.List())
+	assert.ErrorIs(t, s.Cancel(scheduled.ID), ErrNotFound)
+
+	// cancelled command is not processed
+	c.Add(time.Hour)
+	s.processDue()
+}
+
+func TestScheduler_NotSchedulable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s, err := New(service.NewMockItemService(ctrl), "")
+	require.NoError(t, err)
+
+	_, err = s.Schedule(context.Background(), delayedCommand(models.CommandType_GetItem, 1, time.Now().Add(time.Minute)))
+	assert.ErrorIs(t, err, ErrNotSchedulable)
+}
+
+func TestScheduler_Persistence(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	file := filepath.Join(t.TempDir(), "data", "scheduled.json")
+	notBefore := time.Now().Add(time.Hour)
+	s, err := New(service.NewMockItemService(ctrl), file)
+	require.NoError(t, err)
+
+	first, err := s.Schedule(context.Background(), delayedCommand(models.CommandType_AddItem, 1, notBefore))
+	require.NoError(t, err)
+	cancelled, err := s.Schedule(context.Background(), delayedCommand(models.CommandType_AddItem, 2, notBefore))
+	require.NoError(t, err)
+	_, err = s.Schedule(context.Background(), delayedCommand(models.CommandType_UpdateItem, 3, notBefore.Add(-time.Minute)))
+	require.NoError(t, err)
+	require.NoError(t, s.Cancel(cancelled.ID))
+
+	restored, err := New(service.NewMockItemService(ctrl), file)
+	require.NoError(t, err)
+
+	pending := restored.List()
+	assert.Equal(t, []int64{3, 1}, ids(pending))
+	assert.Equal(t, first.ID, pending[1].ID)
+	assert.True(t, notBefore.Equal(pending[1].NotBefore))
+	assert.True(t, proto.Equal(first.Command, pending[1].Command))
+}
+
+func TestScheduler_Start(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	itemService := service.NewMockItemService(ctrl)
+	s, err := New(itemService, "")
+	require.NoError(t, err)
+	s.Start()
+	defer s.Quit()
+
+	processed := make(chan struct{})
+	itemService.EXPECT().ProcessItemCommand(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(context.Context, *models.Command) error {
+			close(processed)
+			return nil
+		})
+
+	// the timer is reset when a command is scheduled earlier than the pending one
+	_, err = s.Schedule(context.Background(), delayedCommand(models.CommandType_AddItem, 1, time.Now().Add(time.Hour)))
+	require.NoError(t, err)
+	_, err = s.Schedule(context.Background(), delayedCommand(models.CommandType_AddItem, 2, time.Now().Add(50*time.Millisecond)))
+	require.NoError(t, err)
+
+	select {
+	case <-processed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("scheduled command is not processed")
+	}
+	assert.Eventually(t, func() bool {
+		return len(s.List()) == 1
+	}, time.Second, 10*time.Millisecond)
+}
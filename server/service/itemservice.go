@@ -3,75 +3,339 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"time"
 
-	log "github.com/sirupsen/logrus"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/logging"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
 	"github.com/dliakhov/bloxroutelabs/client-server-app/server/repository"
+	"github.com/dliakhov/bloxroutelabs/client-server-app/tracing"
 )
 
+var log = logging.Logger("server/service")
+
 const traceIDKey = "X-Trace-ID"
 
+var tracer = otel.Tracer("github.com/dliakhov/bloxroutelabs/client-server-app/server/service")
+
+// ErrReadOnly is returned by read-only service for commands which change the storage.
+var ErrReadOnly = errors.New("storage is read-only")
+
 //go:generate mockgen -package=service -source=itemservice.go -destination=itemservice_mock.go
 type ItemService interface {
 	ProcessItemCommand(ctx context.Context, command *models.Command) error
+	// ProcessBatch applies all commands of the batch atomically and returns items found by its GetItem and
+	// GetAllItems commands in the order of the commands.
+	ProcessBatch(ctx context.Context, batch *models.Batch) ([]models.Item, error)
+	// QueryItems processes GetItem or GetAllItems command and returns found items in insertion order.
+	QueryItems(ctx context.Context, command *models.Command) ([]models.Item, error)
 }
 
 type itemServiceImpl struct {
-	repo repository.Repo
+	registry repository.Registry
+	readOnly bool
+	now      func() time.Time
+}
+
+type Option func(i *itemServiceImpl)
+
+// WithClock sets the clock expiration time of items is calculated from, time.Now by default.
+func WithClock(now func() time.Time) Option {
+	return func(i *itemServiceImpl) {
+		i.now = now
+	}
 }
 
-func New(repo repository.Repo) ItemService {
-	return &itemServiceImpl{repo: repo}
+func New(registry repository.Registry, opts ...Option) ItemService {
+	return newItemService(registry, false, opts)
 }
 
-func (i *itemServiceImpl) ProcessItemCommand(ctx context.Context, command *models.Command) error {
-	log.WithField(traceIDKey, ctx.Value(traceIDKey)).
-		Info("Start processing command: ", command.String())
+// NewReadOnly creates service which processes only GetItem and GetAllItems commands, e.g. for replicas.
+func NewReadOnly(registry repository.Registry, opts ...Option) ItemService {
+	return newItemService(registry, true, opts)
+}
+
+func newItemService(registry repository.Registry, readOnly bool, opts []Option) *itemServiceImpl {
+	i := &itemServiceImpl{registry: registry, readOnly: readOnly, now: time.Now}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
+}
+
+func (i *itemServiceImpl) ProcessItemCommand(ctx context.Context, command *models.Command) (err error) {
+	ctx, span := tracer.Start(ctx, "ProcessItemCommand", trace.WithAttributes(commandAttributes(command)...))
+	defer func() { tracing.End(span, err) }()
+
+	logger := log.WithField(traceIDKey, ctx.Value(traceIDKey))
+	logger.Debug("Start processing command: ", command.String())
+
+	if i.readOnly && !isRead(command.Type) {
+		return fmt.Errorf("%w: %s command is not allowed", ErrReadOnly, command.Type)
+	}
 
 	switch command.Type {
+	case models.CommandType_CreateCollection:
+		err := i.registry.CreateCollection(ctx, command.Collection)
+		if errors.Is(err, repository.ErrCollectionAlreadyExists) {
+			logger.Debug("Collection already exists.")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		logger.Debug("Collection was created successfully.")
+
+		return nil
+	case models.CommandType_DropCollection:
+		err := i.registry.DropCollection(ctx, command.Collection)
+		if errors.Is(err, repository.ErrCollectionNotFound) {
+			logger.Debug("Collection was not found with such name.")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		logger.Debug("Collection was dropped successfully.")
+
+		return nil
 	case models.CommandType_AddItem:
-		err := i.repo.AddItem(models.Item{
-			ID:      command.ItemID,
-			Payload: command.ItemPayload,
-		})
+		item, err := i.newItem(command)
 		if err != nil {
 			return err
 		}
-		log.WithField(traceIDKey, ctx.Value(traceIDKey)).Info("Item was added successfully.")
+
+		// collection is created on demand when the first item is added
+		repo, err := i.registry.Collection(ctx, command.Collection)
+		if err != nil {
+			return err
+		}
+
+		err = repo.AddItem(ctx, item)
+		if err != nil {
+			return err
+		}
+		logger.Debug("Item was added successfully.")
+
+		return nil
+	case models.CommandType_UpdateItem:
+		item, err := i.newItem(command)
+		if err != nil {
+			return err
+		}
+
+		repo, err := i.registry.GetCollection(command.Collection)
+		if err != nil {
+			return err
+		}
+
+		version, err := repo.UpdateItem(ctx, item, command.ExpectedVersion)
+		if err != nil {
+			return err
+		}
+		logger.Debugf("Item was updated successfully. New version: %d", version)
 
 		return nil
 	case models.CommandType_RemoveItem:
-		err := i.repo.RemoveItem(command.ItemID)
+		repo, err := i.registry.GetCollection(command.Collection)
+		if errors.Is(err, repository.ErrCollectionNotFound) && command.ExpectedVersion == 0 {
+			logger.Debug("Collection was not found with such name.")
+			return nil
+		}
 		if err != nil {
 			return err
 		}
-		log.WithField(traceIDKey, ctx.Value(traceIDKey)).Info("Item was removed successfully.")
+
+		if command.ExpectedVersion != 0 {
+			err = repo.RemoveItemIfVersion(ctx, command.ItemID, command.ExpectedVersion)
+		} else {
+			err = repo.RemoveItem(ctx, command.ItemID)
+		}
+		if err != nil {
+			return err
+		}
+		logger.Debug("Item was removed successfully.")
 
 		return nil
 	case models.CommandType_GetItem:
-		item, err := i.repo.GetItem(command.ItemID)
+		repo, err := i.registry.GetCollection(command.Collection)
+		if errors.Is(err, repository.ErrCollectionNotFound) {
+			logger.Debug("Collection was not found with such name.")
+			return nil
+		}
 		if err != nil {
 			return err
 		}
-		if item == (models.Item{}) {
-			log.WithField(traceIDKey, ctx.Value(traceIDKey)).Info("Item was not found with such id.")
+
+		item, err := repo.GetItem(ctx, command.ItemID)
+		if errors.Is(err, repository.ErrItemNotFound) {
+			logger.Info("Item was not found with such id.")
 			return nil
 		}
-		log.WithField(traceIDKey, ctx.Value(traceIDKey)).Info("Item was retrieved successfully.")
-		log.WithField(traceIDKey, ctx.Value(traceIDKey)).Info(item)
+		if err != nil {
+			return err
+		}
+		logger.Debug("Item was retrieved successfully.")
+		logger.Info(item)
 
 		return nil
 	case models.CommandType_GetAllItems:
-		items, err := i.repo.GetAllItems()
+		repo, err := i.registry.GetCollection(command.Collection)
+		if errors.Is(err, repository.ErrCollectionNotFound) {
+			logger.Debug("Collection was not found with such name.")
+			return nil
+		}
 		if err != nil {
 			return err
 		}
-		log.WithField(traceIDKey, ctx.Value(traceIDKey)).Info("Get all items")
-		log.WithField(traceIDKey, ctx.Value(traceIDKey)).Info(items)
+
+		items, err := repo.GetAllItems(ctx)
+		if err != nil {
+			return err
+		}
+		logger.Debug("Get all items")
+		logger.Info(items)
 
 		return nil
 	default:
 		return errors.New("unknown command type")
 	}
 }
+
+func (i *itemServiceImpl) ProcessBatch(ctx context.Context, batch *models.Batch) (items []models.Item, err error) {
+	ctx, span := tracer.Start(ctx, "ProcessBatch", trace.WithAttributes(attribute.Int("batch.commands", len(batch.Commands))))
+	defer func() { tracing.End(span, err) }()
+
+	logger := log.WithField(traceIDKey, ctx.Value(traceIDKey))
+	logger.Debug("Start processing batch: ", batch.String())
+
+	if len(batch.Commands) == 0 {
+		return nil, errors.New("batch is empty")
+	}
+
+	collection := batch.Commands[0].Collection
+	hasAddItem, hasConditions := false, false
+	ops := make([]repository.Operation, 0, len(batch.Commands))
+	for _, command := range batch.Commands {
+		if command.Collection != collection {
+			return nil, errors.New("all commands of the batch must have the same collection")
+		}
+		if i.readOnly && !isRead(command.Type) {
+			return nil, fmt.Errorf("%w: %s command is not allowed", ErrReadOnly, command.Type)
+		}
+
+		op := repository.Operation{
+			Type:            command.Type,
+			Item:            models.Item{ID: command.ItemID},
+			ExpectedVersion: command.ExpectedVersion,
+		}
+		switch command.Type {
+		case models.CommandType_AddItem, models.CommandType_UpdateItem:
+			item, err := i.newItem(command)
+			if err != nil {
+				return nil, err
+			}
+			op.Item = item
+			hasAddItem = hasAddItem || command.Type == models.CommandType_AddItem
+		}
+		hasConditions = hasConditions || command.Type == models.CommandType_UpdateItem || command.ExpectedVersion != 0
+		ops = append(ops, op)
+	}
+
+	var repo repository.Repo
+	if hasAddItem {
+		repo, err = i.registry.Collection(ctx, collection)
+	} else {
+		repo, err = i.registry.GetCollection(collection)
+	}
+	if errors.Is(err, repository.ErrCollectionNotFound) && !hasConditions {
+		// nothing to change or read in not existing collection
+		logger.Debug("Collection was not found with such name.")
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := repo.ApplyBatch(ctx, ops)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Debugf("Batch of %d commands was applied successfully.", len(ops))
+	for idx, result := range results {
+		switch ops[idx].Type {
+		case models.CommandType_GetItem, models.CommandType_GetAllItems:
+			logger.Debugf("Result of command %d (%s): %v", idx, ops[idx].Type, result.Items)
+			items = append(items, result.Items...)
+		}
+	}
+
+	return items, nil
+}
+
+func (i *itemServiceImpl) QueryItems(ctx context.Context, command *models.Command) (items []models.Item, err error) {
+	ctx, span := tracer.Start(ctx, "QueryItems", trace.WithAttributes(commandAttributes(command)...))
+	defer func() { tracing.End(span, err) }()
+
+	logger := log.WithField(traceIDKey, ctx.Value(traceIDKey))
+	logger.Debug("Start processing query: ", command.String())
+
+	if !isRead(command.Type) {
+		return nil, fmt.Errorf("%s command is not a query", command.Type)
+	}
+
+	repo, err := i.registry.GetCollection(command.Collection)
+	if errors.Is(err, repository.ErrCollectionNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if command.Type == models.CommandType_GetAllItems {
+		return repo.GetAllItems(ctx)
+	}
+
+	item, err := repo.GetItem(ctx, command.ItemID)
+	if errors.Is(err, repository.ErrItemNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []models.Item{item}, nil
+}
+
+// commandAttributes returns attributes of the span of the command.
+func commandAttributes(command *models.Command) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("command.type", command.Type.String()),
+		attribute.String("command.collection", command.Collection),
+		attribute.Int64("command.item_id", command.ItemID),
+	}
+}
+
+func isRead(commandType models.CommandType) bool {
+	return commandType == models.CommandType_GetItem || commandType == models.CommandType_GetAllItems
+}
+
+// newItem creates item from AddItem or UpdateItem command.
+func (i *itemServiceImpl) newItem(command *models.Command) (models.Item, error) {
+	if command.ItemTTLSeconds < 0 {
+		return models.Item{}, errors.New("item ttl cannot be negative")
+	}
+
+	item := models.Item{
+		ID:      command.ItemID,
+		Payload: command.ItemPayload,
+	}
+	if command.ItemTTLSeconds > 0 {
+		item.ExpiresAt = i.now().Add(time.Duration(command.ItemTTLSeconds) * time.Second)
+	}
+	return item, nil
+}
@@ -35,6 +35,21 @@ func (m *MockItemService) EXPECT() *MockItemServiceMockRecorder {
 	return m.recorder
 }
 
+// ProcessBatch mocks base method.
+func (m *MockItemService) ProcessBatch(ctx context.Context, batch *models.Batch) ([]models.Item, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ProcessBatch", ctx, batch)
+	ret0, _ := ret[0].([]models.Item)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ProcessBatch indicates an expected call of ProcessBatch.
+func (mr *MockItemServiceMockRecorder) ProcessBatch(ctx, batch interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProcessBatch", reflect.TypeOf((*MockItemService)(nil).ProcessBatch), ctx, batch)
+}
+
 // ProcessItemCommand mocks base method.
 func (m *MockItemService) ProcessItemCommand(ctx context.Context, command *models.Command) error {
 	m.ctrl.T.Helper()
@@ -48,3 +63,18 @@ func (mr *MockItemServiceMockRecorder) ProcessItemCommand(ctx, command interface
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProcessItemCommand", reflect.TypeOf((*MockItemService)(nil).ProcessItemCommand), ctx, command)
 }
+
+// QueryItems mocks base method.
+func (m *MockItemService) QueryItems(ctx context.Context, command *models.Command) ([]models.Item, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "QueryItems", ctx, command)
+	ret0, _ := ret[0].([]models.Item)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// QueryItems indicates an expected call of QueryItems.
+func (mr *MockItemServiceMockRecorder) QueryItems(ctx, command interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryItems", reflect.TypeOf((*MockItemService)(nil).QueryItems), ctx, command)
+}
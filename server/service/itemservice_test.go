@@ -2,16 +2,19 @@ package service
 
 import (
 	"context"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/dliakhov/bloxroutelabs/client-server-app/models"
 	"github.com/dliakhov/bloxroutelabs/client-server-app/server/repository"
 	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
 )
 
 func Test_itemServiceImpl_ProcessItemCommand(t *testing.T) {
 	type fields struct {
-		repo func(c *gomock.Controller) repository.Repo
+		registry func(c *gomock.Controller) repository.Registry
 	}
 	type args struct {
 		ctx     context.Context
@@ -25,14 +28,14 @@ func Test_itemServiceImpl_ProcessItemCommand(t *testing.T) {
 	}{
 		{
 			name: "should process add item command",
-			fields: fields{repo: func(ctrl *gomock.Controller) repository.Repo {
+			fields: fields{registry: func(ctrl *gomock.Controller) repository.Registry {
 				repo := repository.NewMockRepo(ctrl)
-				repo.EXPECT().AddItem(models.Item{
+				repo.EXPECT().AddItem(gomock.Any(), models.Item{
 					ID:      1,
 					Payload: "A",
 				}).Return(nil)
 
-				return repo
+				return registryWith(ctrl, repo)
 			}},
 			args: args{
 				ctx: context.Background(),
@@ -43,13 +46,53 @@ func Test_itemServiceImpl_ProcessItemCommand(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "should process add item command with ttl",
+			fields: fields{registry: func(ctrl *gomock.Controller) repository.Registry {
+				repo := repository.NewMockRepo(ctrl)
+				repo.EXPECT().AddItem(gomock.Any(), expiresWithin{
+					item: models.Item{
+						ID:      1,
+						Payload: "A",
+					},
+					ttl: 10 * time.Second,
+				}).Return(nil)
+
+				return registryWith(ctrl, repo)
+			}},
+			args: args{
+				ctx: context.Background(),
+				command: &models.Command{
+					Type:           models.CommandType_AddItem,
+					ItemID:         1,
+					ItemPayload:    "A",
+					ItemTTLSeconds: 10,
+				},
+			},
+		},
+		{
+			name: "should return error when ttl is negative",
+			fields: fields{registry: func(ctrl *gomock.Controller) repository.Registry {
+				return registryWith(ctrl, repository.NewMockRepo(ctrl))
+			}},
+			args: args{
+				ctx: context.Background(),
+				command: &models.Command{
+					Type:           models.CommandType_AddItem,
+					ItemID:         1,
+					ItemPayload:    "A",
+					ItemTTLSeconds: -1,
+				},
+			},
+			wantErr: true,
+		},
 		{
 			name: "should process remove item command",
-			fields: fields{repo: func(ctrl *gomock.Controller) repository.Repo {
+			fields: fields{registry: func(ctrl *gomock.Controller) repository.Registry {
 				repo := repository.NewMockRepo(ctrl)
-				repo.EXPECT().RemoveItem(int64(1)).Return(nil)
+				repo.EXPECT().RemoveItem(gomock.Any(), int64(1)).Return(nil)
 
-				return repo
+				return registryWith(ctrl, repo)
 			}},
 			args: args{
 				ctx: context.Background(),
@@ -59,16 +102,88 @@ func Test_itemServiceImpl_ProcessItemCommand(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "should process update item command",
+			fields: fields{registry: func(ctrl *gomock.Controller) repository.Registry {
+				repo := repository.NewMockRepo(ctrl)
+				repo.EXPECT().UpdateItem(gomock.Any(), models.Item{ID: 1, Payload: "B"}, uint64(2)).Return(uint64(3), nil)
+
+				return registryWith(ctrl, repo)
+			}},
+			args: args{
+				ctx: context.Background(),
+				command: &models.Command{
+					Type:            models.CommandType_UpdateItem,
+					ItemID:          1,
+					ItemPayload:     "B",
+					ExpectedVersion: 2,
+				},
+			},
+		},
+		{
+			name: "should return error when update item command has version conflict",
+			fields: fields{registry: func(ctrl *gomock.Controller) repository.Registry {
+				repo := repository.NewMockRepo(ctrl)
+				repo.EXPECT().UpdateItem(gomock.Any(), models.Item{ID: 1, Payload: "B"}, uint64(2)).Return(uint64(0), repository.ErrVersionConflict)
+
+				return registryWith(ctrl, repo)
+			}},
+			args: args{
+				ctx: context.Background(),
+				command: &models.Command{
+					Type:            models.CommandType_UpdateItem,
+					ItemID:          1,
+					ItemPayload:     "B",
+					ExpectedVersion: 2,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "should process conditional remove item command",
+			fields: fields{registry: func(ctrl *gomock.Controller) repository.Registry {
+				repo := repository.NewMockRepo(ctrl)
+				repo.EXPECT().RemoveItemIfVersion(gomock.Any(), int64(1), uint64(2)).Return(nil)
+
+				return registryWith(ctrl, repo)
+			}},
+			args: args{
+				ctx: context.Background(),
+				command: &models.Command{
+					Type:            models.CommandType_RemoveItem,
+					ItemID:          1,
+					ExpectedVersion: 2,
+				},
+			},
+		},
+		{
+			name: "should return error when conditional remove item command has version conflict",
+			fields: fields{registry: func(ctrl *gomock.Controller) repository.Registry {
+				repo := repository.NewMockRepo(ctrl)
+				repo.EXPECT().RemoveItemIfVersion(gomock.Any(), int64(1), uint64(2)).Return(repository.ErrVersionConflict)
+
+				return registryWith(ctrl, repo)
+			}},
+			args: args{
+				ctx: context.Background(),
+				command: &models.Command{
+					Type:            models.CommandType_RemoveItem,
+					ItemID:          1,
+					ExpectedVersion: 2,
+				},
+			},
+			wantErr: true,
+		},
 		{
 			name: "should process get item command",
-			fields: fields{repo: func(ctrl *gomock.Controller) repository.Repo {
+			fields: fields{registry: func(ctrl *gomock.Controller) repository.Registry {
 				repo := repository.NewMockRepo(ctrl)
-				repo.EXPECT().GetItem(int64(1)).Return(models.Item{
+				repo.EXPECT().GetItem(gomock.Any(), int64(1)).Return(models.Item{
 					ID:      1,
 					Payload: "A",
 				}, nil)
 
-				return repo
+				return registryWith(ctrl, repo)
 			}},
 			args: args{
 				ctx: context.Background(),
@@ -78,16 +193,116 @@ func Test_itemServiceImpl_ProcessItemCommand(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "should process get item command when item is not found",
+			fields: fields{registry: func(ctrl *gomock.Controller) repository.Registry {
+				repo := repository.NewMockRepo(ctrl)
+				repo.EXPECT().GetItem(gomock.Any(), int64(1)).Return(models.Item{}, repository.ErrItemNotFound)
+
+				return registryWith(ctrl, repo)
+			}},
+			args: args{
+				ctx: context.Background(),
+				command: &models.Command{
+					Type:   models.CommandType_GetItem,
+					ItemID: 1,
+				},
+			},
+		},
+		{
+			name: "should process get item command when collection is not found",
+			fields: fields{registry: func(ctrl *gomock.Controller) repository.Registry {
+				registry := repository.NewMockRegistry(ctrl)
+				registry.EXPECT().GetCollection("sessions").Return(nil, repository.ErrCollectionNotFound)
+
+				return registry
+			}},
+			args: args{
+				ctx: context.Background(),
+				command: &models.Command{
+					Type:       models.CommandType_GetItem,
+					ItemID:     1,
+					Collection: "sessions",
+				},
+			},
+		},
+		{
+			name: "should return error when collection cannot be created for add item command",
+			fields: fields{registry: func(ctrl *gomock.Controller) repository.Registry {
+				registry := repository.NewMockRegistry(ctrl)
+				registry.EXPECT().Collection(gomock.Any(), "../etc").Return(nil, repository.ErrInvalidCollectionName)
+
+				return registry
+			}},
+			args: args{
+				ctx: context.Background(),
+				command: &models.Command{
+					Type:        models.CommandType_AddItem,
+					ItemID:      1,
+					ItemPayload: "A",
+					Collection:  "../etc",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "should process create collection command",
+			fields: fields{registry: func(ctrl *gomock.Controller) repository.Registry {
+				registry := repository.NewMockRegistry(ctrl)
+				registry.EXPECT().CreateCollection(gomock.Any(), "sessions").Return(nil)
+
+				return registry
+			}},
+			args: args{
+				ctx: context.Background(),
+				command: &models.Command{
+					Type:       models.CommandType_CreateCollection,
+					Collection: "sessions",
+				},
+			},
+		},
+		{
+			name: "should process create collection command when collection already exists",
+			fields: fields{registry: func(ctrl *gomock.Controller) repository.Registry {
+				registry := repository.NewMockRegistry(ctrl)
+				registry.EXPECT().CreateCollection(gomock.Any(), "sessions").Return(repository.ErrCollectionAlreadyExists)
+
+				return registry
+			}},
+			args: args{
+				ctx: context.Background(),
+				command: &models.Command{
+					Type:       models.CommandType_CreateCollection,
+					Collection: "sessions",
+				},
+			},
+		},
+		{
+			name: "should process drop collection command",
+			fields: fields{registry: func(ctrl *gomock.Controller) repository.Registry {
+				registry := repository.NewMockRegistry(ctrl)
+				registry.EXPECT().DropCollection(gomock.Any(), "sessions").Return(nil)
+
+				return registry
+			}},
+			args: args{
+				ctx: context.Background(),
+				command: &models.Command{
+					Type:       models.CommandType_DropCollection,
+					Collection: "sessions",
+				},
+			},
+		},
 		{
 			name: "should process get all items command",
-			fields: fields{repo: func(ctrl *gomock.Controller) repository.Repo {
+			fields: fields{registry: func(ctrl *gomock.Controller) repository.Registry {
 				repo := repository.NewMockRepo(ctrl)
-				repo.EXPECT().GetAllItems().Return([]models.Item{{
+				repo.EXPECT().GetAllItems(gomock.Any()).Return([]models.Item{{
 					ID:      1,
 					Payload: "A",
 				}}, nil)
 
-				return repo
+				return registryWith(ctrl, repo)
 			}},
 			args: args{
 				ctx: context.Background(),
@@ -102,10 +317,226 @@ func Test_itemServiceImpl_ProcessItemCommand(t *testing.T) {
 			ctrl := gomock.NewController(t)
 			defer ctrl.Finish()
 
-			i := New(tt.fields.repo(ctrl))
+			i := New(tt.fields.registry(ctrl))
 			if err := i.ProcessItemCommand(tt.args.ctx, tt.args.command); (err != nil) != tt.wantErr {
 				t.Errorf("ProcessItemCommand() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
 }
+
+// registryWith returns registry where every collection is the given repo.
+func registryWith(ctrl *gomock.Controller, repo repository.Repo) repository.Registry {
+	registry := repository.NewMockRegistry(ctrl)
+	registry.EXPECT().Collection(gomock.Any(), gomock.Any()).Return(repo, nil).AnyTimes()
+	registry.EXPECT().GetCollection(gomock.Any()).Return(repo, nil).AnyTimes()
+	return registry
+}
+
+// expiresWithin matches an item which expires not later than ttl from now.
+type expiresWithin struct {
+	item models.Item
+	ttl  time.Duration
+}
+
+func (e expiresWithin) Matches(x interface{}) bool {
+	item, ok := x.(models.Item)
+	if !ok || item.ID != e.item.ID || item.Payload != e.item.Payload {
+		return false
+	}
+	return item.ExpiresAt.After(time.Now()) && !item.ExpiresAt.After(time.Now().Add(e.ttl))
+}
+
+func (e expiresWithin) String() string {
+	return fmt.Sprintf("item %v expiring within %v", e.item, e.ttl)
+}
+
+func Test_itemServiceImpl_ProcessBatch(t *testing.T) {
+	tests := []struct {
+		name      string
+		registry  func(ctrl *gomock.Controller) repository.Registry
+		batch     *models.Batch
+		wantItems []models.Item
+		wantErr   bool
+	}{
+		{
+			name: "should apply batch to the collection",
+			registry: func(ctrl *gomock.Controller) repository.Registry {
+				repo := repository.NewMockRepo(ctrl)
+				repo.EXPECT().ApplyBatch(gomock.Any(), []repository.Operation{
+					{Type: models.CommandType_AddItem, Item: models.Item{ID: 1, Payload: "A"}},
+					{Type: models.CommandType_RemoveItem, Item: models.Item{ID: 2}},
+					{Type: models.CommandType_GetAllItems},
+				}).Return([]repository.OperationResult{{}, {}, {Items: []models.Item{{ID: 1, Payload: "A"}}}}, nil)
+
+				registry := repository.NewMockRegistry(ctrl)
+				registry.EXPECT().Collection(gomock.Any(), "sessions").Return(repo, nil)
+				return registry
+			},
+			batch: &models.Batch{Commands: []*models.Command{
+				{Type: models.CommandType_AddItem, ItemID: 1, ItemPayload: "A", Collection: "sessions"},
+				{Type: models.CommandType_RemoveItem, ItemID: 2, Collection: "sessions"},
+				{Type: models.CommandType_GetAllItems, Collection: "sessions"},
+			}},
+			wantItems: []models.Item{{ID: 1, Payload: "A"}},
+		},
+		{
+			name: "should not create collection for batch without added items",
+			registry: func(ctrl *gomock.Controller) repository.Registry {
+				registry := repository.NewMockRegistry(ctrl)
+				registry.EXPECT().GetCollection("sessions").Return(nil, repository.ErrCollectionNotFound)
+				return registry
+			},
+			batch: &models.Batch{Commands: []*models.Command{
+				{Type: models.CommandType_RemoveItem, ItemID: 2, Collection: "sessions"},
+			}},
+		},
+		{
+			name: "should return error when batch is not applied",
+			registry: func(ctrl *gomock.Controller) repository.Registry {
+				repo := repository.NewMockRepo(ctrl)
+				repo.EXPECT().ApplyBatch(gomock.Any(), gomock.Any()).Return(nil, repository.ErrCapacityExceeded)
+
+				registry := repository.NewMockRegistry(ctrl)
+				registry.EXPECT().Collection(gomock.Any(), "").Return(repo, nil)
+				return registry
+			},
+			batch: &models.Batch{Commands: []*models.Command{
+				{Type: models.CommandType_AddItem, ItemID: 1, ItemPayload: "A"},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "should return error when collection of batch with conditions is not found",
+			registry: func(ctrl *gomock.Controller) repository.Registry {
+				registry := repository.NewMockRegistry(ctrl)
+				registry.EXPECT().GetCollection("").Return(nil, repository.ErrCollectionNotFound)
+				return registry
+			},
+			batch: &models.Batch{Commands: []*models.Command{
+				{Type: models.CommandType_UpdateItem, ItemID: 1, ItemPayload: "A", ExpectedVersion: 1},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "should return error when batch is empty",
+			registry: func(ctrl *gomock.Controller) repository.Registry {
+				return repository.NewMockRegistry(ctrl)
+			},
+			batch:   &models.Batch{},
+			wantErr: true,
+		},
+		{
+			name: "should return error when commands have different collections",
+			registry: func(ctrl *gomock.Controller) repository.Registry {
+				return repository.NewMockRegistry(ctrl)
+			},
+			batch: &models.Batch{Commands: []*models.Command{
+				{Type: models.CommandType_AddItem, ItemID: 1, ItemPayload: "A"},
+				{Type: models.CommandType_AddItem, ItemID: 1, ItemPayload: "A", Collection: "sessions"},
+			}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			i := New(tt.registry(ctrl))
+			items, err := i.ProcessBatch(context.Background(), tt.batch)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ProcessBatch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			assert.Equal(t, tt.wantItems, items)
+		})
+	}
+}
+
+func Test_itemServiceImpl_ReadOnly(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	repo := repository.NewMockRepo(ctrl)
+	repo.EXPECT().GetItem(gomock.Any(), int64(1)).Return(models.Item{ID: 1, Payload: "A", Version: 1}, nil)
+	i := NewReadOnly(registryWith(ctrl, repo))
+
+	ctx := context.WithValue(context.Background(), traceIDKey, "trace_id")
+	assert.NoError(t, i.ProcessItemCommand(ctx, &models.Command{Type: models.CommandType_GetItem, ItemID: 1}))
+	assert.ErrorIs(t, i.ProcessItemCommand(ctx, &models.Command{Type: models.CommandType_AddItem, ItemID: 1}), ErrReadOnly)
+	assert.ErrorIs(t, i.ProcessItemCommand(ctx, &models.Command{Type: models.CommandType_DropCollection}), ErrReadOnly)
+	_, err := i.ProcessBatch(ctx, &models.Batch{Commands: []*models.Command{
+		{Type: models.CommandType_GetAllItems},
+		{Type: models.CommandType_RemoveItem, ItemID: 1},
+	}})
+	assert.ErrorIs(t, err, ErrReadOnly)
+}
+
+func Test_itemServiceImpl_QueryItems(t *testing.T) {
+	tests := []struct {
+		name     string
+		registry func(ctrl *gomock.Controller) repository.Registry
+		command  *models.Command
+		want     []models.Item
+		wantErr  assert.ErrorAssertionFunc
+	}{
+		{
+			name: "should return all items",
+			registry: func(ctrl *gomock.Controller) repository.Registry {
+				repo := repository.NewMockRepo(ctrl)
+				repo.EXPECT().GetAllItems(gomock.Any()).Return([]models.Item{{ID: 1, Payload: "A"}, {ID: 2, Payload: "B"}}, nil)
+				return registryWith(ctrl, repo)
+			},
+			command: &models.Command{Type: models.CommandType_GetAllItems},
+			want:    []models.Item{{ID: 1, Payload: "A"}, {ID: 2, Payload: "B"}},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "should return item",
+			registry: func(ctrl *gomock.Controller) repository.Registry {
+				repo := repository.NewMockRepo(ctrl)
+				repo.EXPECT().GetItem(gomock.Any(), int64(1)).Return(models.Item{ID: 1, Payload: "A"}, nil)
+				return registryWith(ctrl, repo)
+			},
+			command: &models.Command{Type: models.CommandType_GetItem, ItemID: 1},
+			want:    []models.Item{{ID: 1, Payload: "A"}},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "should return no items when item is not found",
+			registry: func(ctrl *gomock.Controller) repository.Registry {
+				repo := repository.NewMockRepo(ctrl)
+				repo.EXPECT().GetItem(gomock.Any(), int64(1)).Return(models.Item{}, repository.ErrItemNotFound)
+				return registryWith(ctrl, repo)
+			},
+			command: &models.Command{Type: models.CommandType_GetItem, ItemID: 1},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "should return no items when collection is not found",
+			registry: func(ctrl *gomock.Controller) repository.Registry {
+				registry := repository.NewMockRegistry(ctrl)
+				registry.EXPECT().GetCollection("sessions").Return(nil, repository.ErrCollectionNotFound)
+				return registry
+			},
+			command: &models.Command{Type: models.CommandType_GetAllItems, Collection: "sessions"},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "should return error for command which is not a query",
+			registry: func(ctrl *gomock.Controller) repository.Registry {
+				return repository.NewMockRegistry(ctrl)
+			},
+			command: &models.Command{Type: models.CommandType_AddItem, ItemID: 1},
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			i := New(tt.registry(ctrl))
+
+			got, err := i.QueryItems(context.Background(), tt.command)
+			tt.wantErr(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
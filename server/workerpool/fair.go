@@ -0,0 +1,166 @@
+package workerpool
+
+import (
+	"sort"
+	"sync"
+)
+
+const (
+	defaultQuantum   = 1
+	defaultMaxQueued = 1000
+)
+
+type queuedTask struct {
+	task func()
+	cost int
+}
+
+// flow is the queue of tasks of one key and priority.
+type flow struct {
+	key   string
+	tasks []queuedTask
+	// deficit is the cost the flow can still use, it is increased by quantum when the turn of the flow starts.
+	deficit int
+	inTurn  bool
+}
+
+// level is the round robin of flows of one priority.
+type level struct {
+	flows map[string]*flow
+	// active are flows with tasks in round robin order, the first one has the turn
+	active []*flow
+}
+
+// fairQueue is the deficit round robin queue of tasks by keys. Tasks of higher priority are taken first, flows of
+// the same priority take turns.
+type fairQueue struct {
+	quantum   int
+	maxQueued int
+	levels    map[uint8]*level
+	// priorities of levels with tasks from the highest
+	priorities []uint8
+	size       int
+	closed     bool
+	mx         sync.Mutex
+	notEmpty   *sync.Cond
+	notFull    *sync.Cond
+}
+
+func newFairQueue(quantum, maxQueued int) *fairQueue {
+	if quantum <= 0 {
+		quantum = defaultQuantum
+	}
+	if maxQueued <= 0 {
+		maxQueued = defaultMaxQueued
+	}
+	q := &fairQueue{
+		quantum:   quantum,
+		maxQueued: maxQueued,
+		levels:    make(map[uint8]*level),
+	}
+	q.notEmpty = sync.NewCond(&q.mx)
+	q.notFull = sync.NewCond(&q.mx)
+	return q
+}
+
+func (q *fairQueue) push(key string, priority uint8, cost int, task func()) {
+	if cost < 1 {
+		cost = 1
+	}
+
+	q.mx.Lock()
+	defer q.mx.Unlock()
+
+	for q.size >= q.maxQueued && !q.closed {
+		q.notFull.Wait()
+	}
+	if q.closed {
+		return
+	}
+
+	l, ok := q.levels[priority]
+	if !ok {
+		l = &level{flows: make(map[string]*flow)}
+		q.levels[priority] = l
+		q.priorities = append(q.priorities, priority)
+		sort.Slice(q.priorities, func(i, j int) bool { return q.priorities[i] > q.priorities[j] })
+	}
+	f, ok := l.flows[key]
+	if !ok {
+		f = &flow{key: key}
+		l.flows[key] = f
+		l.active = append(l.active, f)
+	}
+	f.tasks = append(f.tasks, queuedTask{task: task, cost: cost})
+	q.size++
+	q.notEmpty.Signal()
+}
+
+// pop waits for the next task, it returns nil when the queue is closed.
+func (q *fairQueue) pop() func() {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+
+	for q.size == 0 && !q.closed {
+		q.notEmpty.Wait()
+	}
+	if q.closed {
+		return nil
+	}
+
+	priority := q.priorities[0]
+	l := q.levels[priority]
+	for {
+		f := l.active[0]
+		if !f.inTurn {
+			f.deficit += q.quantum
+			f.inTurn = true
+		}
+		t := f.tasks[0]
+		if t.cost > f.deficit {
+			// the rest of the deficit is kept for the next turn, so large tasks run after several turns
+			f.inTurn = false
+			l.active = append(l.active[1:], f)
+			continue
+		}
+
+		f.deficit -= t.cost
+		f.tasks[0] = queuedTask{}
+		f.tasks = f.tasks[1:]
+		q.size--
+		if len(f.tasks) == 0 {
+			// idle flows do not save the deficit
+			delete(l.flows, f.key)
+			l.active = l.active[1:]
+		}
+		if len(l.active) == 0 {
+			delete(q.levels, priority)
+			q.priorities = q.priorities[1:]
+		}
+		q.notFull.Signal()
+		return t.task
+	}
+}
+
+func (q *fairQueue) close() {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+
+	q.closed = true
+	q.notEmpty.Broadcast()
+	q.notFull.Broadcast()
+}
+
+// queued returns the number of tasks by keys of all priorities.
+func (q *fairQueue) queued() map[string]int {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+
+	queued := make(map[string]int)
+	for _, l := range q.levels {
+		for key, f := range l.flows {
+			queued[key] += len(f.tasks)
+		}
+	}
+	return queued
+}
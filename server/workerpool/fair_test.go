@@ -0,0 +1,160 @@
+package workerpool
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFairQueue_Order(t *testing.T) {
+	tests := []struct {
+		name    string
+		quantum int
+		tasks   []queuedTask
+		want    string
+	}{
+		{
+			name:    "should take tasks of keys in turns",
+			quantum: 1,
+			tasks:   []queuedTask{{cost: 1}, {cost: 1}, {cost: 1}, {cost: 1}, {cost: 1}, {cost: 1}},
+			want:    "abcaba",
+		},
+		{
+			name:    "should run large task after several turns",
+			quantum: 1,
+			tasks:   []queuedTask{{cost: 3}, {cost: 1}, {cost: 1}, {cost: 1}, {cost: 1}, {cost: 1}},
+			want:    "bcbaaa",
+		},
+		{
+			name:    "should run tasks of the quantum in one turn",
+			quantum: 2,
+			tasks:   []queuedTask{{cost: 1}, {cost: 1}, {cost: 1}, {cost: 1}, {cost: 1}, {cost: 1}},
+			want:    "aabbca",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newFairQueue(tt.quantum, 0)
+			var got string
+			keys := "abaabc"
+			for i, task := range tt.tasks {
+				key := string(keys[i])
+				q.push(key, 0, task.cost, func() { got += key })
+			}
+			assert.Equal(t, map[string]int{"a": 3, "b": 2, "c": 1}, q.queued())
+
+			for range tt.tasks {
+				q.pop()()
+			}
+			assert.Equal(t, tt.want, got)
+			assert.Empty(t, q.queued())
+		})
+	}
+}
+
+func TestFairQueue_Priority(t *testing.T) {
+	q := newFairQueue(1, 0)
+	var got string
+	push := func(key string, priority uint8) {
+		q.push(key, priority, 1, func() { got += key })
+	}
+	push("a", 0)
+	push("a", 0)
+	push("b", 0)
+	push("c", 5)
+	push("d", 9)
+	push("c", 5)
+	push("e", 5)
+
+	for i := 0; i < 4; i++ {
+		q.pop()()
+	}
+	// higher priority tasks submitted later overtake the waiting ones
+	push("f", 9)
+	for i := 0; i < 4; i++ {
+		q.pop()()
+	}
+	assert.Equal(t, "dcecfaba", got)
+	assert.Empty(t, q.queued())
+}
+
+func TestFairQueue_Close(t *testing.T) {
+	q := newFairQueue(1, 1)
+	q.push("a", 0, 1, func() {})
+
+	pushed := make(chan struct{})
+	go func() {
+		q.push("b", 0, 1, func() {})
+		close(pushed)
+	}()
+	select {
+	case <-pushed:
+		t.Fatal("push is not blocked by full queue")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	q.close()
+	<-pushed
+	assert.Nil(t, q.pop(), "queued tasks are dropped")
+}
+
+// TestWorkerPool_LatencyIsolation submits a burst of tasks of one client followed by a task of another client,
+// and measures how long the last one waits to be run.
+func TestWorkerPool_LatencyIsolation(t *testing.T) {
+	const (
+		burst    = 100
+		workers  = 2
+		duration = 2 * time.Millisecond
+	)
+	latency := func(opts ...Option) time.Duration {
+		w := NewWorkerPool(workers, opts...)
+		w.Start()
+		defer w.Quit()
+
+		var wg sync.WaitGroup
+		wg.Add(burst + 1)
+		// submitting blocks while the workers are busy, so the latency is measured from the start of the burst
+		submittedAt := time.Now()
+		for i := 0; i < burst; i++ {
+			w.Submit("producer", 0, 1, func() {
+				time.Sleep(duration)
+				wg.Done()
+			})
+		}
+		var waited time.Duration
+		w.Submit("consumer", 0, 1, func() {
+			waited = time.Since(submittedAt)
+			wg.Done()
+		})
+		wg.Wait()
+		return waited
+	}
+
+	fifo := latency()
+	fair := latency(WithFairQueue(1, burst+1))
+	t.Logf("latency of the task after the burst: fifo %s, fair %s", fifo, fair)
+	require.Greater(t, fifo, (burst/workers-2)*duration, "task waits for the whole burst")
+	assert.Less(t, fair, 10*duration, "task waits for a few tasks of the burst at most")
+}
+
+func TestWorkerPool_Stats(t *testing.T) {
+	w := NewWorkerPool(1, WithFairQueue(1, 0))
+	w.Start()
+	defer w.Quit()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	w.Submit("a", 0, 1, func() {
+		close(started)
+		<-release
+	})
+	<-started
+	w.Submit("a", 0, 1, func() {})
+	w.Submit("b", 0, 1, func() {})
+
+	assert.Equal(t, Stats{Workers: 1, Busy: 1, Queued: map[string]int{"a": 1, "b": 1}}, w.Stats())
+	close(release)
+}
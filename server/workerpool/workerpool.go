@@ -1,31 +1,144 @@
 package workerpool
 
+import (
+	"sync"
+	"sync/atomic"
+)
+
 type WorkerPool struct {
+	// numWorkers is the size of the pool, running workers above it exit after their current tasks
 	numWorkers int
+	running    int
+	started    bool
+	stopped    bool
+	mx         sync.Mutex
 	chTasks    chan func()
+	busy       atomic.Int32
+	// fair queues tasks by keys when it is set, otherwise tasks are passed to workers in the order they are submitted
+	fair *fairQueue
+}
+
+// Stats is the state of the pool: the number of workers and how many of them are running tasks.
+type Stats struct {
+	Workers int `json:"workers"`
+	Busy    int `json:"busy"`
+	// Queued is the number of tasks waiting for a worker by their keys, only with fair queuing.
+	Queued map[string]int `json:"queued,omitempty"`
 }
 
-func NewWorkerPool(numWorkers int) *WorkerPool {
-	return &WorkerPool{
+type Option func(w *WorkerPool)
+
+// WithFairQueue makes the pool take tasks of higher priority first, and tasks of the same priority from keys
+// in deficit round robin order, so a burst of tasks of one key does not delay tasks of other keys. Every turn a key
+// can run tasks of total cost quantum, and submitting blocks while maxQueued tasks are waiting.
+func WithFairQueue(quantum, maxQueued int) Option {
+	return func(w *WorkerPool) {
+		w.fair = newFairQueue(quantum, maxQueued)
+	}
+}
+
+func NewWorkerPool(numWorkers int, opts ...Option) *WorkerPool {
+	w := &WorkerPool{
 		numWorkers: numWorkers,
 		chTasks:    make(chan func()),
 	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
 }
 
 func (w *WorkerPool) Start() {
-	for i := 0; i < w.numWorkers; i++ {
-		go func() {
-			for task := range w.chTasks {
-				task()
-			}
-		}()
+	w.mx.Lock()
+	defer w.mx.Unlock()
+
+	w.started = true
+	w.spawn()
+}
+
+// Resize changes the number of workers. New workers are started at once, extra workers exit after their
+// current or next tasks.
+func (w *WorkerPool) Resize(numWorkers int) {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+
+	w.numWorkers = numWorkers
+	if w.started {
+		w.spawn()
+	}
+}
+
+// spawn starts workers up to the size of the pool, it should be called under the lock.
+func (w *WorkerPool) spawn() {
+	for ; w.running < w.numWorkers && !w.stopped; w.running++ {
+		go w.work()
+	}
+}
+
+func (w *WorkerPool) work() {
+	for task := w.next(); task != nil; task = w.next() {
+		w.busy.Add(1)
+		task()
+		w.busy.Add(-1)
+
+		if w.retire() {
+			return
+		}
+	}
+}
+
+// retire returns true when the worker should exit because the pool is shrunk.
+func (w *WorkerPool) retire() bool {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+
+	if w.running > w.numWorkers {
+		w.running--
+		return true
+	}
+	return false
+}
+
+func (w *WorkerPool) next() func() {
+	if w.fair != nil {
+		return w.fair.pop()
 	}
+	return <-w.chTasks
 }
 
+// Quit stops the workers after their current tasks, queued tasks are dropped.
 func (w *WorkerPool) Quit() {
+	w.mx.Lock()
+	w.stopped = true
+	w.mx.Unlock()
+
+	if w.fair != nil {
+		w.fair.close()
+		return
+	}
 	close(w.chTasks)
 }
 
 func (w *WorkerPool) SubmitTask(task func()) {
+	w.Submit("", 0, 1, task)
+}
+
+// Submit passes the task of the key to the pool, cost is how much of the turn of the key the task uses.
+// The key, the priority and the cost are used only with fair queuing.
+func (w *WorkerPool) Submit(key string, priority uint8, cost int, task func()) {
+	if w.fair != nil {
+		w.fair.push(key, priority, cost, task)
+		return
+	}
 	w.chTasks <- task
 }
+
+func (w *WorkerPool) Stats() Stats {
+	w.mx.Lock()
+	stats := Stats{Workers: w.numWorkers, Busy: int(w.busy.Load())}
+	w.mx.Unlock()
+	if w.fair != nil {
+		stats.Queued = w.fair.queued()
+	}
+	return stats
+}
@@ -0,0 +1,49 @@
+package workerpool
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkerPool_Resize(t *testing.T) {
+	w := NewWorkerPool(1)
+	w.Start()
+	defer w.Quit()
+
+	// runBlocked submits tasks which block until release is closed and waits until n of them are running
+	runBlocked := func(tasks, n int) (release chan struct{}, done *sync.WaitGroup) {
+		release = make(chan struct{})
+		done = new(sync.WaitGroup)
+		done.Add(tasks)
+		for i := 0; i < tasks; i++ {
+			go w.SubmitTask(func() {
+				defer done.Done()
+				<-release
+			})
+		}
+		require.Eventually(t, func() bool { return w.Stats().Busy == n }, time.Second, time.Millisecond)
+		return release, done
+	}
+
+	w.Resize(3)
+	release, done := runBlocked(3, 3)
+	assert.Equal(t, Stats{Workers: 3, Busy: 3}, w.Stats())
+	close(release)
+	done.Wait()
+
+	w.Resize(2)
+	assert.Equal(t, 2, w.Stats().Workers)
+	// the extra worker exits after its next task, so the pool runs at most 2 tasks later
+	release, done = runBlocked(3, 3)
+	close(release)
+	done.Wait()
+	release, done = runBlocked(3, 2)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, 2, w.Stats().Busy)
+	close(release)
+	done.Wait()
+}
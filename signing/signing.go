@@ -0,0 +1,278 @@
+package signing
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Headers of signed messages.
+const (
+	ClientIDHeader  = "X-Client-ID"
+	TimestampHeader = "X-Timestamp"
+	NonceHeader     = "X-Nonce"
+	SignatureHeader = "X-Signature"
+)
+
+const (
+	AlgorithmHMAC    = "hmac-sha256"
+	AlgorithmEd25519 = "ed25519"
+
+	// DefaultWindow is how far the timestamp of a message can be from the time of the server.
+	DefaultWindow = 5 * time.Minute
+)
+
+var (
+	ErrInvalidKey       = errors.New("invalid signing key")
+	ErrUnsigned         = errors.New("message is not signed")
+	ErrUnknownClient    = errors.New("unknown client")
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrExpired          = errors.New("message timestamp is outside of the allowed window")
+	ErrReplayed         = errors.New("message is replayed")
+)
+
+// Key is the credential of a client. For hmac-sha256 it is the base64 shared secret on both sides. For ed25519 it is
+// the base64 private key (or its 32 bytes seed) on the client and the base64 public key on the server.
+type Key struct {
+	Algorithm string
+	Key       string `secret:"true"`
+}
+
+// Signer signs published messages of the client.
+type Signer struct {
+	clientID string
+	sign     func(data []byte) []byte
+	now      func() time.Time
+}
+
+func NewSigner(clientID string, key Key) (*Signer, error) {
+	if clientID == "" {
+		return nil, fmt.Errorf("%w: client id is required", ErrInvalidKey)
+	}
+	secret, err := decodeKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Signer{clientID: clientID, now: time.Now}
+	switch key.Algorithm {
+	case AlgorithmHMAC:
+		s.sign = func(data []byte) []byte {
+			return hmacSum(secret, data)
+		}
+	case AlgorithmEd25519:
+		var privateKey ed25519.PrivateKey
+		switch len(secret) {
+		case ed25519.SeedSize:
+			privateKey = ed25519.NewKeyFromSeed(secret)
+		case ed25519.PrivateKeySize:
+			privateKey = secret
+		default:
+			return nil, fmt.Errorf("%w: ed25519 private key has %d bytes", ErrInvalidKey, len(secret))
+		}
+		s.sign = func(data []byte) []byte {
+			return ed25519.Sign(privateKey, data)
+		}
+	}
+	return s, nil
+}
+
+// Sign sets the client id, timestamp, nonce and signature headers of the message, so its body, type and content type
+// cannot be changed. It should be called after the body is set.
+func (s *Signer) Sign(publishing *amqp.Publishing) error {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	timestamp := s.now().UnixNano()
+	nonceValue := hex.EncodeToString(nonce)
+	data := signedData(s.clientID, timestamp, nonceValue, publishing.Type, publishing.ContentType, publishing.Body)
+
+	if publishing.Headers == nil {
+		publishing.Headers = amqp.Table{}
+	}
+	publishing.Headers[ClientIDHeader] = s.clientID
+	publishing.Headers[TimestampHeader] = timestamp
+	publishing.Headers[NonceHeader] = nonceValue
+	publishing.Headers[SignatureHeader] = base64.StdEncoding.EncodeToString(s.sign(data))
+	return nil
+}
+
+// Verifier authenticates messages by signatures of known clients and rejects replayed messages.
+type Verifier struct {
+	verifiers map[string]func(data, signature []byte) bool
+	window    time.Duration
+	now       func() time.Time
+	// nonces are seen nonces by client with the time they expire, nonces are kept until timestamps of their messages
+	// are out of the window
+	nonces    map[string]time.Time
+	nextPrune time.Time
+	mx        sync.Mutex
+}
+
+type Option func(v *Verifier)
+
+// WithClock sets the source of the current time, time.Now by default.
+func WithClock(now func() time.Time) Option {
+	return func(v *Verifier) {
+		v.now = now
+	}
+}
+
+// NewVerifier returns the verifier of the client keys by client id. Window is DefaultWindow when it is zero.
+func NewVerifier(keys map[string]Key, window time.Duration, opts ...Option) (*Verifier, error) {
+	if window <= 0 {
+		window = DefaultWindow
+	}
+	v := &Verifier{
+		verifiers: make(map[string]func(data, signature []byte) bool, len(keys)),
+		window:    window,
+		now:       time.Now,
+		nonces:    make(map[string]time.Time),
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	for clientID, key := range keys {
+		secret, err := decodeKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("client %s: %w", clientID, err)
+		}
+
+		switch key.Algorithm {
+		case AlgorithmHMAC:
+			v.verifiers[clientID] = func(data, signature []byte) bool {
+				return hmac.Equal(hmacSum(secret, data), signature)
+			}
+		case AlgorithmEd25519:
+			if len(secret) != ed25519.PublicKeySize {
+				return nil, fmt.Errorf("client %s: %w: ed25519 public key has %d bytes", clientID, ErrInvalidKey, len(secret))
+			}
+			publicKey := ed25519.PublicKey(secret)
+			v.verifiers[clientID] = func(data, signature []byte) bool {
+				return ed25519.Verify(publicKey, data, signature)
+			}
+		}
+	}
+	return v, nil
+}
+
+// Verify checks the signature of the message and returns the authenticated client id. ErrUnsigned is returned
+// for messages without signature. Redelivered messages can have a seen nonce, since they have been verified
+// by the first delivery, but they are rejected when they are out of the window.
+func (v *Verifier) Verify(d amqp.Delivery) (string, error) {
+	signatureValue, ok := d.Headers[SignatureHeader].(string)
+	if !ok {
+		return "", ErrUnsigned
+	}
+	clientID, _ := d.Headers[ClientIDHeader].(string)
+	nonce, _ := d.Headers[NonceHeader].(string)
+	timestamp, ok := d.Headers[TimestampHeader].(int64)
+	if clientID == "" || nonce == "" || !ok {
+		return "", fmt.Errorf("%w: signature headers are missing", ErrInvalidSignature)
+	}
+
+	verify, ok := v.verifiers[clientID]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownClient, clientID)
+	}
+	signature, err := base64.StdEncoding.DecodeString(signatureValue)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	if !verify(signedData(clientID, timestamp, nonce, d.Type, d.ContentType, d.Body), signature) {
+		return "", ErrInvalidSignature
+	}
+
+	v.mx.Lock()
+	defer v.mx.Unlock()
+
+	now := v.now()
+	v.prune(now)
+	issuedAt := time.Unix(0, timestamp)
+	if issuedAt.Before(now.Add(-v.window)) || issuedAt.After(now.Add(v.window)) {
+		return "", fmt.Errorf("%w: issued at %s", ErrExpired, issuedAt.UTC().Format(time.RFC3339Nano))
+	}
+
+	key := clientID + "/" + nonce
+	if _, seen := v.nonces[key]; seen && !d.Redelivered {
+		return "", fmt.Errorf("%w: nonce %s of client %s", ErrReplayed, nonce, clientID)
+	}
+	v.nonces[key] = issuedAt.Add(v.window)
+	return clientID, nil
+}
+
+// ExpiresIn returns how long the signed message stays in the window, so it is not rejected as expired when it is
+// redelivered. False is returned for messages without timestamp.
+func (v *Verifier) ExpiresIn(d amqp.Delivery) (time.Duration, bool) {
+	timestamp, ok := d.Headers[TimestampHeader].(int64)
+	if !ok {
+		return 0, false
+	}
+	return time.Unix(0, timestamp).Add(v.window).Sub(v.now()), true
+}
+
+// prune removes expired nonces at most once per window.
+func (v *Verifier) prune(now time.Time) {
+	if now.Before(v.nextPrune) {
+		return
+	}
+	for key, expiresAt := range v.nonces {
+		if now.After(expiresAt) {
+			delete(v.nonces, key)
+		}
+	}
+	v.nextPrune = now.Add(v.window)
+}
+
+// signedData returns the signed representation of the message, fields are separated by new lines and the body is
+// the last one.
+func signedData(clientID string, timestamp int64, nonce, messageType, contentType string, body []byte) []byte {
+	var buf bytes.Buffer
+	for _, field := range []string{clientID, strconv.FormatInt(timestamp, 10), nonce, messageType, contentType} {
+		buf.WriteString(field)
+		buf.WriteByte('\n')
+	}
+	buf.Write(body)
+	return buf.Bytes()
+}
+
+func hmacSum(secret, data []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// Validate checks the algorithm and the encoding of the key, the size of ed25519 keys is checked by NewSigner
+// and NewVerifier.
+func (k Key) Validate() error {
+	_, err := decodeKey(k)
+	return err
+}
+
+func decodeKey(key Key) ([]byte, error) {
+	if key.Algorithm != AlgorithmHMAC && key.Algorithm != AlgorithmEd25519 {
+		return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidKey, key.Algorithm)
+	}
+	secret, err := base64.StdEncoding.DecodeString(key.Key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("%w: key is empty", ErrInvalidKey)
+	}
+	return secret, nil
+}
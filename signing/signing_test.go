@@ -0,0 +1,205 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newKeys(t *testing.T) (hmacKey, ed25519Private, ed25519Public Key) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	hmacKey = Key{Algorithm: AlgorithmHMAC, Key: base64.StdEncoding.EncodeToString([]byte("secret"))}
+	ed25519Private = Key{Algorithm: AlgorithmEd25519, Key: base64.StdEncoding.EncodeToString(privateKey.Seed())}
+	ed25519Public = Key{Algorithm: AlgorithmEd25519, Key: base64.StdEncoding.EncodeToString(publicKey)}
+	return hmacKey, ed25519Private, ed25519Public
+}
+
+// delivery returns the signed message as it is received by the server.
+func delivery(t *testing.T, signer *Signer, body string) amqp.Delivery {
+	publishing := amqp.Publishing{
+		Headers:     amqp.Table{"X-Trace-ID": "trace-1"},
+		Type:        "Command",
+		ContentType: "application/protobuf",
+		Body:        []byte(body),
+	}
+	require.NoError(t, signer.Sign(&publishing))
+	return amqp.Delivery{
+		Headers:     publishing.Headers,
+		Type:        publishing.Type,
+		ContentType: publishing.ContentType,
+		Body:        publishing.Body,
+	}
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	hmacKey, ed25519Private, ed25519Public := newKeys(t)
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	hmacSigner, err := NewSigner("hmac-client", hmacKey)
+	require.NoError(t, err)
+	ed25519Signer, err := NewSigner("ed25519-client", ed25519Private)
+	require.NoError(t, err)
+	unknownSigner, err := NewSigner("unknown-client", hmacKey)
+	require.NoError(t, err)
+	for _, s := range []*Signer{hmacSigner, ed25519Signer, unknownSigner} {
+		s.now = func() time.Time { return now }
+	}
+
+	tests := []struct {
+		name         string
+		delivery     func() amqp.Delivery
+		wantClientID string
+		wantErr      error
+	}{
+		{
+			name:         "should verify hmac signature",
+			delivery:     func() amqp.Delivery { return delivery(t, hmacSigner, "body") },
+			wantClientID: "hmac-client",
+		},
+		{
+			name:         "should verify ed25519 signature",
+			delivery:     func() amqp.Delivery { return delivery(t, ed25519Signer, "body") },
+			wantClientID: "ed25519-client",
+		},
+		{
+			name: "should reject changed body",
+			delivery: func() amqp.Delivery {
+				d := delivery(t, ed25519Signer, "body")
+				d.Body = []byte("other body")
+				return d
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "should reject changed type",
+			delivery: func() amqp.Delivery {
+				d := delivery(t, hmacSigner, "body")
+				d.Type = "Batch"
+				return d
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "should reject impersonated client",
+			delivery: func() amqp.Delivery {
+				d := delivery(t, hmacSigner, "body")
+				d.Headers[ClientIDHeader] = "ed25519-client"
+				return d
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "should reject changed timestamp",
+			delivery: func() amqp.Delivery {
+				d := delivery(t, hmacSigner, "body")
+				d.Headers[TimestampHeader] = now.Add(time.Second).UnixNano()
+				return d
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:     "should reject unknown client",
+			delivery: func() amqp.Delivery { return delivery(t, unknownSigner, "body") },
+			wantErr:  ErrUnknownClient,
+		},
+		{
+			name: "should reject message without nonce",
+			delivery: func() amqp.Delivery {
+				d := delivery(t, hmacSigner, "body")
+				delete(d.Headers, NonceHeader)
+				return d
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:     "should return unsigned message",
+			delivery: func() amqp.Delivery { return amqp.Delivery{Type: "Command", Body: []byte("body")} },
+			wantErr:  ErrUnsigned,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := NewVerifier(map[string]Key{
+				"hmac-client":    hmacKey,
+				"ed25519-client": ed25519Public,
+			}, time.Minute, WithClock(func() time.Time { return now }))
+			require.NoError(t, err)
+
+			clientID, err := v.Verify(tt.delivery())
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.wantClientID, clientID)
+		})
+	}
+}
+
+func TestVerifier_Replay(t *testing.T) {
+	hmacKey, _, _ := newKeys(t)
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	clock := now
+
+	signer, err := NewSigner("client", hmacKey)
+	require.NoError(t, err)
+	signer.now = func() time.Time { return now }
+	v, err := NewVerifier(map[string]Key{"client": hmacKey}, time.Minute, WithClock(func() time.Time { return clock }))
+	require.NoError(t, err)
+
+	d := delivery(t, signer, "body")
+	_, err = v.Verify(d)
+	require.NoError(t, err)
+	expiresIn, ok := v.ExpiresIn(d)
+	assert.True(t, ok)
+	assert.Equal(t, time.Minute, expiresIn)
+
+	// the same message published again
+	_, err = v.Verify(d)
+	assert.ErrorIs(t, err, ErrReplayed)
+
+	// the message is redelivered by the broker after it is not acked
+	d.Redelivered = true
+	_, err = v.Verify(d)
+	assert.NoError(t, err)
+
+	// other messages of the client are accepted
+	_, err = v.Verify(delivery(t, signer, "body"))
+	assert.NoError(t, err)
+
+	clock = now.Add(time.Minute + time.Nanosecond)
+	_, err = v.Verify(d)
+	assert.ErrorIs(t, err, ErrExpired)
+	assert.Empty(t, v.nonces, "expired nonces are pruned")
+
+	clock = now.Add(-time.Minute - time.Nanosecond)
+	_, err = v.Verify(delivery(t, signer, "body"))
+	assert.ErrorIs(t, err, ErrExpired, "message from the future")
+}
+
+func TestNewSigner_InvalidKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		clientID string
+		key      Key
+	}{
+		{name: "should require client id", key: Key{Algorithm: AlgorithmHMAC, Key: "c2VjcmV0"}},
+		{name: "should reject unknown algorithm", clientID: "client", key: Key{Algorithm: "md5", Key: "c2VjcmV0"}},
+		{name: "should reject invalid base64", clientID: "client", key: Key{Algorithm: AlgorithmHMAC, Key: "not base64!"}},
+		{name: "should reject empty key", clientID: "client", key: Key{Algorithm: AlgorithmHMAC}},
+		{name: "should reject ed25519 key of wrong size", clientID: "client", key: Key{Algorithm: AlgorithmEd25519, Key: "c2VjcmV0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewSigner(tt.clientID, tt.key)
+			assert.ErrorIs(t, err, ErrInvalidKey)
+		})
+	}
+
+	_, err := NewVerifier(map[string]Key{"client": {Algorithm: AlgorithmEd25519, Key: "c2VjcmV0"}}, 0)
+	assert.ErrorIs(t, err, ErrInvalidKey)
+}
@@ -0,0 +1,188 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/validation"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Exporters of spans.
+const (
+	// ExporterOTLP sends spans to the OpenTelemetry collector over gRPC.
+	ExporterOTLP = "otlp"
+	// ExporterStdout writes spans to stdout in JSON.
+	ExporterStdout = "stdout"
+	// ExporterFile appends spans to the file in JSON.
+	ExporterFile = "file"
+)
+
+type Config struct {
+	// Exporter is otlp, stdout or file. Spans are not recorded when it is empty, but the trace context
+	// of received messages is still passed on.
+	Exporter string
+	// Endpoint is the address of the OTLP collector, "localhost:4317" by default.
+	Endpoint string
+	// Insecure disables TLS of the connection to the OTLP collector.
+	Insecure bool
+	// File is where the file exporter writes spans.
+	File string
+	// SampleRatio is the part of new traces which are recorded, 1 by default. Traces of sampled parents are always
+	// recorded.
+	SampleRatio float64
+}
+
+// Validate checks the exporter and the sample ratio.
+func (c Config) Validate() error {
+	var errs validation.Errors
+	switch c.Exporter {
+	case "", ExporterOTLP, ExporterStdout:
+	case ExporterFile:
+		if c.File == "" {
+			errs.Addf("file", "is required for file exporter")
+		}
+	default:
+		errs.Addf("exporter", "unknown exporter %q", c.Exporter)
+	}
+	if c.SampleRatio < 0 || c.SampleRatio > 1 {
+		errs.Addf("sampleratio", "%v is out of range [0, 1]", c.SampleRatio)
+	}
+	return errs.Err()
+}
+
+// Init sets the global tracer provider of the service by the configuration and the W3C trace context propagator.
+// The returned function flushes spans which are not exported yet and stops the exporter.
+func Init(serviceName string, config Config) (func(context.Context) error, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if config.Exporter == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := newExporter(config)
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName)))
+	if err != nil {
+		return nil, err
+	}
+	sampleRatio := config.SampleRatio
+	if sampleRatio == 0 {
+		sampleRatio = 1
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+func newExporter(config Config) (sdktrace.SpanExporter, error) {
+	switch config.Exporter {
+	case ExporterOTLP:
+		var opts []otlptracegrpc.Option
+		if config.Endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(config.Endpoint))
+		}
+		if config.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		// the connection is established in background, so the service starts when the collector is not available
+		return otlptracegrpc.New(context.Background(), opts...)
+	case ExporterStdout:
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterFile:
+		f, err := os.OpenFile(config.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return fileExporter{SpanExporter: exporter, file: f}, nil
+	default:
+		return nil, fmt.Errorf("unknown exporter %q", config.Exporter)
+	}
+}
+
+// fileExporter closes the file when it is stopped.
+type fileExporter struct {
+	sdktrace.SpanExporter
+	file io.Closer
+}
+
+func (e fileExporter) Shutdown(ctx context.Context) error {
+	err := e.SpanExporter.Shutdown(ctx)
+	if closeErr := e.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Headers adapts AMQP message headers to the carrier of the trace context.
+type Headers amqp.Table
+
+func (h Headers) Get(key string) string {
+	value, _ := h[key].(string)
+	return value
+}
+
+func (h Headers) Set(key, value string) {
+	h[key] = value
+}
+
+func (h Headers) Keys() []string {
+	keys := make([]string, 0, len(h))
+	for key := range h {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Inject writes the trace context of ctx to the headers, e.g. traceparent.
+func Inject(ctx context.Context, headers amqp.Table) {
+	otel.GetTextMapPropagator().Inject(ctx, Headers(headers))
+}
+
+// Extract returns ctx with the trace context of the headers, ctx is returned as it is when the headers have none.
+func Extract(ctx context.Context, headers amqp.Table) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, Headers(headers))
+}
+
+// TraceID returns the id of the trace of ctx, it is empty when the span of ctx is not recorded or propagated.
+func TraceID(ctx context.Context) string {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.HasTraceID() {
+		return ""
+	}
+	return spanContext.TraceID().String()
+}
+
+// End records the error of the span and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
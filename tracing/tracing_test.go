@@ -0,0 +1,77 @@
+package tracing
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dliakhov/bloxroutelabs/client-server-app/validation"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestInjectExtract(t *testing.T) {
+	_, err := Init("test", Config{})
+	require.NoError(t, err)
+
+	traceID, err := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	require.NoError(t, err)
+	spanID, err := trace.SpanIDFromHex("00f067aa0ba902b7")
+	require.NoError(t, err)
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	headers := amqp.Table{"X-Trace-ID": "legacy"}
+	Inject(ctx, headers)
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", headers["traceparent"])
+	assert.Equal(t, "legacy", headers["X-Trace-ID"])
+
+	extracted := Extract(context.Background(), headers)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", TraceID(extracted))
+	assert.True(t, trace.SpanContextFromContext(extracted).IsRemote())
+
+	assert.Empty(t, TraceID(Extract(context.Background(), amqp.Table{"traceparent": 1})), "header of other type is ignored")
+	assert.Empty(t, TraceID(Extract(context.Background(), nil)))
+}
+
+func TestInit_File(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "spans.json")
+	shutdown, err := Init("test", Config{Exporter: ExporterFile, File: file})
+	require.NoError(t, err)
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "process Command")
+	assert.NotEmpty(t, TraceID(ctx))
+	End(span, os.ErrNotExist)
+	require.NoError(t, shutdown(context.Background()))
+
+	spans, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.True(t, strings.Contains(string(spans), `"Name":"process Command"`), string(spans))
+	assert.True(t, strings.Contains(string(spans), TraceID(ctx)))
+	assert.True(t, strings.Contains(string(spans), "file does not exist"))
+}
+
+func TestInit_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+	}{
+		{name: "should reject unknown exporter", config: Config{Exporter: "jaeger"}},
+		{name: "should reject file exporter without file", config: Config{Exporter: ExporterFile}},
+		{name: "should reject sample ratio out of range", config: Config{Exporter: ExporterStdout, SampleRatio: 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Init("test", tt.config)
+			assert.ErrorIs(t, err, validation.ErrInvalid)
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalid is matched by all errors of Errors.Err.
+var ErrInvalid = errors.New("invalid configuration")
+
+// Problem is a problem of the field of the configuration, the field is its path in the config file,
+// e.g. "rabbitmqconfig.url".
+type Problem struct {
+	Field   string
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Field == "" {
+		return p.Message
+	}
+	return p.Field + ": " + p.Message
+}
+
+// Error lists all problems of the configuration.
+type Error struct {
+	Problems []Problem
+}
+
+func (e *Error) Error() string {
+	problems := make([]string, 0, len(e.Problems))
+	for _, problem := range e.Problems {
+		problems = append(problems, problem.String())
+	}
+	return ErrInvalid.Error() + ": " + strings.Join(problems, "; ")
+}
+
+func (e *Error) Unwrap() error {
+	return ErrInvalid
+}
+
+// Errors collects problems of the configuration, so all of them are reported at once.
+type Errors struct {
+	problems []Problem
+}
+
+// Add adds the error of the field when it is not nil. Problems of the nested configuration are added with the field
+// as the prefix of their fields.
+func (e *Errors) Add(field string, err error) {
+	if err == nil {
+		return
+	}
+
+	var nested *Error
+	if !errors.As(err, &nested) {
+		e.problems = append(e.problems, Problem{Field: field, Message: err.Error()})
+		return
+	}
+	for _, problem := range nested.Problems {
+		e.problems = append(e.problems, Problem{Field: Join(field, problem.Field), Message: problem.Message})
+	}
+}
+
+// Addf adds the problem of the field.
+func (e *Errors) Addf(field, format string, args ...interface{}) {
+	e.problems = append(e.problems, Problem{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// Err returns the error with all problems, it is nil when there are no problems.
+func (e *Errors) Err() error {
+	if len(e.problems) == 0 {
+		return nil
+	}
+	return &Error{Problems: e.problems}
+}
+
+// Join joins paths of the field, e.g. Join("rabbitmqconfig", "tls") is "rabbitmqconfig.tls".
+func Join(field, nested string) string {
+	switch {
+	case field == "":
+		return nested
+	case nested == "":
+		return field
+	default:
+		return field + "." + nested
+	}
+}
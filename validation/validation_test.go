@@ -0,0 +1,37 @@
+package validation
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrors(t *testing.T) {
+	var nested Errors
+	nested.Addf("url", "is required")
+	nested.Add("tls", (&Errors{problems: []Problem{{Field: "minversion", Message: "unsupported version \"1.0\""}}}).Err())
+
+	var errs Errors
+	errs.Add("listenaddr", nil)
+	errs.Add("rabbitmqconfig", nested.Err())
+	errs.Add("tracingconfig", errors.New("sample ratio 2 is out of range [0, 1]"))
+	errs.Addf("", "admin API requires token")
+
+	err := errs.Err()
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrInvalid)
+	var validationErr *Error
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []Problem{
+		{Field: "rabbitmqconfig.url", Message: "is required"},
+		{Field: "rabbitmqconfig.tls.minversion", Message: "unsupported version \"1.0\""},
+		{Field: "tracingconfig", Message: "sample ratio 2 is out of range [0, 1]"},
+		{Message: "admin API requires token"},
+	}, validationErr.Problems)
+	assert.Equal(t, `invalid configuration: rabbitmqconfig.url: is required; rabbitmqconfig.tls.minversion: unsupported version "1.0"; `+
+		`tracingconfig: sample ratio 2 is out of range [0, 1]; admin API requires token`, err.Error())
+
+	assert.NoError(t, (&Errors{}).Err())
+}